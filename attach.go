@@ -0,0 +1,167 @@
+package godev
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/moby/term"
+)
+
+// resizePollInterval is how often an attached TTY checks the local terminal size.
+// Polling rather than SIGWINCH keeps resize handling the same on every platform.
+var resizePollInterval = 250 * time.Millisecond
+
+// attachSession streams process stdio to and from a container attached before it starts.
+type attachSession struct {
+	resp    types.HijackedResponse // resp is the hijacked attach connection.
+	tty     bool                   // tty reports whether the container has a pseudo-TTY.
+	stdout  io.Writer              // stdout receives container output.
+	output  chan struct{}          // output is closed once container output has been copied.
+	stdin   io.Reader              // stdin feeds the container until the session closes.
+	restore func()                 // restore returns a raw terminal to its previous state.
+	stop    chan struct{}          // stop ends the resize loop and the stdin copy.
+	input   chan struct{}          // input is closed once the stdin copy has returned.
+	once    sync.Once              // once guards Close.
+}
+
+// attachContainer attaches stdin, stdout, and stderr to containerID and starts copying.
+// A terminal stdin is switched to raw mode for TTY containers so keystrokes reach the container unmodified.
+func attachContainer(ctx context.Context, cli *client.Client, containerID string, options startOptions) (*attachSession, error) {
+	stdin, stdout, stderr := options.Stdin, options.Stdout, options.Stderr
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	resp, err := cli.ContainerAttach(ctx, containerID, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	session := &attachSession{
+		resp:    resp,
+		tty:     options.TTY,
+		stdout:  stdout,
+		stdin:   stdin,
+		output:  make(chan struct{}),
+		restore: func() {},
+		stop:    make(chan struct{}),
+		input:   make(chan struct{}),
+	}
+	if options.TTY {
+		if fd, isTerminal := term.GetFdInfo(stdin); isTerminal {
+			if state, err := term.SetRawTerminal(fd); err == nil {
+				session.restore = func() {
+					_ = term.RestoreTerminal(fd, state)
+				}
+			}
+		}
+	}
+	go func() {
+		defer close(session.output)
+		if options.TTY {
+			_, _ = io.Copy(stdout, resp.Reader)
+			return
+		}
+		_, _ = stdcopy.StdCopy(stdout, stderr, resp.Reader)
+	}()
+	go session.copyStdin()
+	return session, nil
+}
+
+// copyStdin forwards stdin to the container until stdin ends or the session closes. Input read after Close is
+// dropped rather than sent to the detached connection.
+func (s *attachSession) copyStdin() {
+	defer close(s.input)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.stdin.Read(buf)
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if n > 0 {
+			if _, writeErr := s.resp.Conn.Write(buf[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			_ = s.resp.CloseWrite()
+			return
+		}
+	}
+}
+
+// resizeTTY keeps the container TTY at the local terminal size until the session closes.
+// It does nothing when the container has no TTY or stdout is not a terminal.
+func (s *attachSession) resizeTTY(ctx context.Context, cli *client.Client, containerID string) {
+	if !s.tty {
+		return
+	}
+	fd, isTerminal := term.GetFdInfo(s.stdout)
+	if !isTerminal {
+		return
+	}
+	var last term.Winsize
+	resize := func() {
+		size, err := term.GetWinsize(fd)
+		if err != nil || size.Height == 0 || size.Width == 0 || *size == last {
+			return
+		}
+		last = *size
+		_ = cli.ContainerResize(ctx, containerID, container.ResizeOptions{Height: uint(size.Height), Width: uint(size.Width)})
+	}
+	resize()
+	go func() {
+		ticker := time.NewTicker(resizePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				resize()
+			}
+		}
+	}()
+}
+
+// drain waits briefly for output still in flight after the container exits.
+func (s *attachSession) drain() {
+	select {
+	case <-s.output:
+	case <-time.After(time.Second):
+	}
+}
+
+// Close detaches from the container, restores the terminal, and stops the stdin copy. A stdin that supports read
+// deadlines, such as a pipe, is unblocked at once; any other stdin read still pending returns at its next input.
+func (s *attachSession) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		s.resp.Close()
+		s.restore()
+		if deadline, ok := s.stdin.(interface{ SetReadDeadline(time.Time) error }); ok && deadline.SetReadDeadline(time.Now()) == nil {
+			<-s.input
+			_ = deadline.SetReadDeadline(time.Time{})
+		}
+	})
+}
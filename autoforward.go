@@ -0,0 +1,144 @@
+package godev
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+// autoForwardPollInterval is how often WithAutoForwardPorts rescans while StartDevcontainer waits for exit.
+var autoForwardPollInterval = 2 * time.Second
+
+// tcpListenState is the st column value for LISTEN sockets in /proc/net/tcp.
+const tcpListenState = "0A"
+
+// portWatcher reports container ports that start listening, once each.
+type portWatcher struct {
+	cli         *client.Client   // cli runs the scan exec.
+	containerID string           // containerID is the container being scanned.
+	forwarded   map[int]struct{} // forwarded holds TCP ports already published at create time.
+	logger      *slog.Logger     // logger receives one record per newly seen port.
+	mu          sync.Mutex       // mu guards seen and detected.
+	seen        map[int]struct{} // seen holds every port already reported.
+	detected    []int            // detected lists listening ports that are not published, in discovery order.
+}
+
+func newPortWatcher(cli *client.Client, containerID string, exposedPorts nat.PortSet, logger *slog.Logger) *portWatcher {
+	forwarded := make(map[int]struct{}, len(exposedPorts))
+	for port := range exposedPorts {
+		if port.Proto() == "tcp" {
+			forwarded[port.Int()] = struct{}{}
+		}
+	}
+	return &portWatcher{
+		cli:         cli,
+		containerID: containerID,
+		forwarded:   forwarded,
+		logger:      logger,
+		seen:        make(map[int]struct{}),
+	}
+}
+
+// scan reads the container's listening TCP ports and logs any not seen before.
+func (w *portWatcher) scan(ctx context.Context) error {
+	ports, err := containerListeningPorts(ctx, w.cli, w.containerID)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, port := range ports {
+		if _, ok := w.seen[port]; ok {
+			continue
+		}
+		w.seen[port] = struct{}{}
+		if _, ok := w.forwarded[port]; ok {
+			w.logger.Debug("forwarded port is listening", "port", port)
+			continue
+		}
+		w.detected = append(w.detected, port)
+		w.logger.Info("detected listening port that is not forwarded", "port", port, "hint", "add it to forwardPorts or WithExtraPublish and recreate the container")
+	}
+	return nil
+}
+
+// watch rescans until ctx is done; scan errors are skipped since the container may be stopping.
+func (w *portWatcher) watch(ctx context.Context) {
+	ticker := time.NewTicker(autoForwardPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = w.scan(ctx)
+		}
+	}
+}
+
+func (w *portWatcher) ports() []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]int(nil), w.detected...)
+}
+
+// containerListeningPorts execs cat on /proc/net/tcp and /proc/net/tcp6 and returns the listening ports.
+// A missing tcp6 table makes cat fail, so the exit code is ignored and whatever was printed is parsed.
+func containerListeningPorts(ctx context.Context, cli *client.Client, containerID string) ([]int, error) {
+	execResp, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          []string{"cat", "/proc/net/tcp", "/proc/net/tcp6"},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		return nil, err
+	}
+	return parseListeningPorts(stdout.String()), nil
+}
+
+// parseListeningPorts returns the sorted, unique local ports of LISTEN sockets in /proc/net/tcp{,6} output.
+func parseListeningPorts(data string) []int {
+	seen := make(map[int]struct{})
+	var ports []int
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != tcpListenState {
+			continue
+		}
+		colon := strings.LastIndex(fields[1], ":")
+		if colon < 0 {
+			continue
+		}
+		port, err := strconv.ParseInt(fields[1][colon+1:], 16, 32)
+		if err != nil || port == 0 {
+			continue
+		}
+		if _, ok := seen[int(port)]; ok {
+			continue
+		}
+		seen[int(port)] = struct{}{}
+		ports = append(ports, int(port))
+	}
+	sort.Ints(ports)
+	return ports
+}
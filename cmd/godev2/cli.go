@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	devcontainer "github.com/0x5341/godev"
@@ -16,12 +21,14 @@ import (
 type StartFunc func(context.Context, startConfig, []devcontainer.StartOption) (string, error)
 type StopFunc func(context.Context, stopConfig) error
 type DownFunc func(context.Context, downConfig) error
+type ReadConfigFunc func(context.Context, readConfig) (*devcontainer.DevcontainerConfig, error)
 
 // startConfig holds CLI flag values for devcontainer start.
 type startConfig struct {
-	ConfigPath   string        // ConfigPath is the devcontainer.json path override.
+	ConfigPaths  []string      // ConfigPaths are devcontainer.json files merged in order; the first sets the workspace and the base for relative paths.
 	Detach       bool          // Detach controls whether to run in the background.
 	TTY          bool          // TTY controls whether to allocate a TTY.
+	Interactive  bool          // Interactive attaches stdio to the container and waits for it to exit.
 	RemoveOnStop bool          // RemoveOnStop removes the container when it stops.
 	Timeout      time.Duration // Timeout sets the start operation deadline.
 	Workdir      string        // Workdir overrides the container working directory.
@@ -30,7 +37,17 @@ type startConfig struct {
 	Publishes    []string      // Publishes holds extra port publish mappings.
 	Mounts       []string      // Mounts holds extra Docker --mount specs.
 	Labels       []string      // Labels holds extra Docker labels.
+	LabelFiles   []string      // LabelFiles holds paths to KEY=VALUE label files.
+	ArgFiles     []string      // ArgFiles holds paths to KEY=VALUE build argument files.
 	RunArgs      []string      // RunArgs holds extra docker run arguments.
+	Name         string        // Name overrides the container or compose project name.
+	Platform     string        // Platform selects the image platform.
+	NoCache      bool          // NoCache rebuilds images without the layer cache.
+	Pull         bool          // Pull refreshes the Dockerfile base image before building.
+	Hostname     string        // Hostname sets the container hostname.
+	KeepOverride bool          // KeepOverride keeps the generated compose override file and logs its path.
+	Quiet        bool          // Quiet suppresses build, pull, and compose progress output.
+	Progress     io.Writer     // Progress receives logs and build output; it is stderr unless Quiet is set.
 }
 
 // stopConfig holds CLI flag values for devcontainer stop.
@@ -41,13 +58,33 @@ type stopConfig struct {
 
 // downConfig holds CLI flag values for devcontainer down.
 type downConfig struct {
-	ContainerID string // ContainerID is the target container.
+	ContainerID   string // ContainerID is the target container.
+	RemoveVolumes bool   // RemoveVolumes also deletes compose volumes.
+	IgnoreMissing bool   // IgnoreMissing treats an already removed container as success.
+}
+
+// readConfig holds CLI flag values for devcontainer read-configuration.
+type readConfig struct {
+	ConfigPath string // ConfigPath is the devcontainer.json path override.
+}
+
+// validateConfig holds CLI flag values for devcontainer validate.
+type validateConfig struct {
+	ConfigPath string // ConfigPath is the devcontainer.json path override.
 }
 
 var errUsage = errors.New("usage error")
 
-func run(args []string, start StartFunc, stop StopFunc, down DownFunc, stdout, stderr io.Writer) int {
-	cmd := newRootCommand(start, stop, down)
+// errInvalidConfig reports that devcontainer validate already printed the problems it found.
+var errInvalidConfig = errors.New("invalid devcontainer configuration")
+
+// notifyInterrupt derives a context canceled on SIGINT or SIGTERM; tests replace it.
+var notifyInterrupt = func(ctx context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+}
+
+func run(args []string, start StartFunc, stop StopFunc, down DownFunc, read ReadConfigFunc, stdout, stderr io.Writer) int {
+	cmd := newRootCommand(start, stop, down, read)
 	cmd.SetOut(stdout)
 	cmd.SetErr(stderr)
 	cmd.SetArgs(args)
@@ -55,6 +92,9 @@ func run(args []string, start StartFunc, stop StopFunc, down DownFunc, stdout, s
 		if errors.Is(err, pflag.ErrHelp) {
 			return 0
 		}
+		if errors.Is(err, errInvalidConfig) {
+			return 2
+		}
 		if errors.Is(err, errUsage) || isUnknownCommandError(err) {
 			_ = cmd.Usage()
 			return 2
@@ -74,7 +114,7 @@ func run(args []string, start StartFunc, stop StopFunc, down DownFunc, stdout, s
 	return 0
 }
 
-func newRootCommand(start StartFunc, stop StopFunc, down DownFunc) *cobra.Command {
+func newRootCommand(start StartFunc, stop StopFunc, down DownFunc, read ReadConfigFunc) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:           "godev",
 		SilenceUsage:  true,
@@ -83,11 +123,11 @@ func newRootCommand(start StartFunc, stop StopFunc, down DownFunc) *cobra.Comman
 			return errUsage
 		},
 	}
-	cmd.AddCommand(newDevcontainerCommand(start, stop, down))
+	cmd.AddCommand(newDevcontainerCommand(start, stop, down, read))
 	return cmd
 }
 
-func newDevcontainerCommand(start StartFunc, stop StopFunc, down DownFunc) *cobra.Command {
+func newDevcontainerCommand(start StartFunc, stop StopFunc, down DownFunc, read ReadConfigFunc) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "devcontainer",
 		Short: "Devcontainer commands",
@@ -95,27 +135,44 @@ func newDevcontainerCommand(start StartFunc, stop StopFunc, down DownFunc) *cobr
 			return errUsage
 		},
 	}
-	cmd.AddCommand(newStartCommand(start))
+	cmd.AddCommand(newStartCommand(start, stop, down))
 	cmd.AddCommand(newStopCommand(stop))
 	cmd.AddCommand(newDownCommand(down))
+	cmd.AddCommand(newReadConfigurationCommand(read))
+	cmd.AddCommand(newValidateCommand())
 	return cmd
 }
 
-func newStartCommand(start StartFunc) *cobra.Command {
+func newStartCommand(start StartFunc, stop StopFunc, down DownFunc) *cobra.Command {
 	cfg := startConfig{
 		Detach: true,
 		TTY:    true,
 	}
 	cmd := &cobra.Command{
-		Use:   "start",
-		Short: "Start a devcontainer",
+		Use:     "start",
+		Aliases: []string{"up"},
+		Short:   "Start a devcontainer",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cfg.Quiet {
+				cfg.Progress = cmd.ErrOrStderr()
+			}
 			options, err := buildStartOptions(cfg)
 			if err != nil {
 				return err
 			}
-			containerID, err := start(cmd.Context(), cfg, options)
+			ctx := cmd.Context()
+			if !cfg.Detach || cfg.Interactive {
+				interruptCtx, cancel := notifyInterrupt(ctx)
+				defer cancel()
+				ctx = interruptCtx
+			}
+			containerID, err := start(ctx, cfg, options)
 			if err != nil {
+				if containerID != "" && ctx.Err() != nil && cmd.Context().Err() == nil {
+					if cleanupErr := cleanupInterrupted(context.WithoutCancel(ctx), cfg, containerID, stop, down); cleanupErr != nil {
+						return errors.Join(err, cleanupErr)
+					}
+				}
 				return err
 			}
 			_, err = fmt.Fprintln(cmd.OutOrStdout(), containerID)
@@ -123,10 +180,11 @@ func newStartCommand(start StartFunc) *cobra.Command {
 		},
 	}
 	flags := cmd.Flags()
-	flags.StringVar(&cfg.ConfigPath, "config", "", "Path to devcontainer.json")
+	flags.StringArrayVar(&cfg.ConfigPaths, "config", nil, "Path to devcontainer.json; repeat to merge later files onto earlier ones, whose relative paths resolve against the first file's directory")
 	flags.BoolVar(&cfg.Detach, "detach", true, "Run container in background")
 	flags.BoolVar(&cfg.TTY, "tty", true, "Allocate a TTY")
-	flags.BoolVar(&cfg.RemoveOnStop, "rm", false, "Remove container when it stops")
+	flags.BoolVarP(&cfg.Interactive, "interactive", "i", false, "Attach stdin, stdout, and stderr and wait for the container to exit")
+	flags.BoolVar(&cfg.RemoveOnStop, "rm", false, "Remove container when it stops or when an attached start is interrupted")
 	flags.DurationVar(&cfg.Timeout, "timeout", 0, "Timeout for starting container")
 	flags.StringVar(&cfg.Workdir, "workdir", "", "Override container working directory")
 	flags.StringVar(&cfg.Network, "network", "", "Override container network")
@@ -134,10 +192,27 @@ func newStartCommand(start StartFunc) *cobra.Command {
 	flags.StringArrayVar(&cfg.Publishes, "publish", nil, "Extra port publish (e.g. 3000:3000)")
 	flags.StringArrayVar(&cfg.Mounts, "mount", nil, "Extra mount (Docker --mount syntax)")
 	flags.StringArrayVar(&cfg.Labels, "label", nil, "Extra label (KEY=VALUE)")
+	flags.StringArrayVar(&cfg.LabelFiles, "label-file", nil, "Read labels from a file of KEY=VALUE lines")
+	flags.StringArrayVar(&cfg.ArgFiles, "build-arg-file", nil, "Read build arguments from a file of KEY=VALUE lines")
 	flags.StringArrayVar(&cfg.RunArgs, "run-arg", nil, "Extra docker run argument")
+	flags.StringVar(&cfg.Name, "name", "", "Override container or compose project name")
+	flags.StringVar(&cfg.Platform, "platform", "", "Image platform (e.g. linux/arm64)")
+	flags.BoolVar(&cfg.NoCache, "no-cache", false, "Build images without using the layer cache")
+	flags.BoolVar(&cfg.Pull, "pull", false, "Pull newer versions of the Dockerfile base image before building")
+	flags.StringVar(&cfg.Hostname, "hostname", "", "Container hostname")
+	flags.BoolVar(&cfg.KeepOverride, "keep-override", false, "Keep the generated compose override file and log its path")
+	flags.BoolVarP(&cfg.Quiet, "quiet", "q", false, "Suppress build, pull, and compose progress on stderr")
 	return cmd
 }
 
+// cleanupInterrupted stops, or with --rm removes, a container whose attached start was interrupted.
+func cleanupInterrupted(ctx context.Context, cfg startConfig, containerID string, stop StopFunc, down DownFunc) error {
+	if cfg.RemoveOnStop {
+		return down(ctx, downConfig{ContainerID: containerID})
+	}
+	return stop(ctx, stopConfig{ContainerID: containerID})
+}
+
 func startWithConfig(ctx context.Context, cfg startConfig, options []devcontainer.StartOption) (string, error) {
 	return devcontainer.StartDevcontainer(ctx, options...)
 }
@@ -147,14 +222,37 @@ func stopWithConfig(ctx context.Context, cfg stopConfig) error {
 }
 
 func downWithConfig(ctx context.Context, cfg downConfig) error {
-	return devcontainer.RemoveDevcontainer(ctx, cfg.ContainerID)
+	var options []devcontainer.RemoveOption
+	if cfg.RemoveVolumes {
+		options = append(options, devcontainer.WithRemoveVolumes())
+	}
+	if !cfg.IgnoreMissing {
+		options = append(options, devcontainer.WithRequireExisting())
+	}
+	return devcontainer.RemoveDevcontainer(ctx, cfg.ContainerID, options...)
 }
 
-func buildStartOptions(cfg startConfig) ([]devcontainer.StartOption, error) {
-	options := make([]devcontainer.StartOption, 0, 8)
+func readConfigWithConfig(ctx context.Context, cfg readConfig) (*devcontainer.DevcontainerConfig, error) {
+	var options []devcontainer.StartOption
 	if cfg.ConfigPath != "" {
 		options = append(options, devcontainer.WithConfigPath(cfg.ConfigPath))
 	}
+	return devcontainer.EffectiveConfig(options...)
+}
+
+func buildStartOptions(cfg startConfig) ([]devcontainer.StartOption, error) {
+	options := make([]devcontainer.StartOption, 0, 8)
+	for i, path := range cfg.ConfigPaths {
+		if i == 0 {
+			options = append(options, devcontainer.WithConfigPath(path))
+			continue
+		}
+		overlay, err := devcontainer.LoadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		options = append(options, devcontainer.WithMergeConfig(overlay))
+	}
 	for _, env := range cfg.Envs {
 		key, value, err := splitKeyValue(env)
 		if err != nil {
@@ -179,6 +277,12 @@ func buildStartOptions(cfg startConfig) ([]devcontainer.StartOption, error) {
 		}
 		options = append(options, devcontainer.WithLabel(key, value))
 	}
+	for _, path := range cfg.LabelFiles {
+		options = append(options, devcontainer.WithLabelFile(path))
+	}
+	for _, path := range cfg.ArgFiles {
+		options = append(options, devcontainer.WithBuildArgFile(path))
+	}
 	for _, arg := range cfg.RunArgs {
 		options = append(options, devcontainer.WithRunArg(arg))
 	}
@@ -191,6 +295,9 @@ func buildStartOptions(cfg startConfig) ([]devcontainer.StartOption, error) {
 	if !cfg.TTY {
 		options = append(options, devcontainer.WithTTYValue(false))
 	}
+	if cfg.Interactive {
+		options = append(options, devcontainer.WithInteractive())
+	}
 	if cfg.Timeout > 0 {
 		options = append(options, devcontainer.WithTimeout(cfg.Timeout))
 	}
@@ -200,6 +307,31 @@ func buildStartOptions(cfg startConfig) ([]devcontainer.StartOption, error) {
 	if cfg.Network != "" {
 		options = append(options, devcontainer.WithNetwork(cfg.Network))
 	}
+	if cfg.Name != "" {
+		options = append(options, devcontainer.WithName(cfg.Name))
+	}
+	if cfg.NoCache {
+		options = append(options, devcontainer.WithNoBuildCache())
+	}
+	if cfg.Pull {
+		options = append(options, devcontainer.WithPullBuildBase())
+	}
+	if cfg.Hostname != "" {
+		options = append(options, devcontainer.WithHostname(cfg.Hostname))
+	}
+	if cfg.Platform != "" {
+		options = append(options, devcontainer.WithPlatform(cfg.Platform))
+	}
+	if cfg.KeepOverride {
+		options = append(options, devcontainer.WithKeepComposeOverride())
+	}
+	if cfg.Progress != nil {
+		options = append(options,
+			devcontainer.WithLogger(slog.New(slog.NewTextHandler(cfg.Progress, nil))),
+			devcontainer.WithBuildOutput(cfg.Progress),
+			devcontainer.WithComposeOutput(cfg.Progress),
+		)
+	}
 	return options, nil
 }
 
@@ -222,7 +354,7 @@ func newStopCommand(stop StopFunc) *cobra.Command {
 }
 
 func newDownCommand(down DownFunc) *cobra.Command {
-	cfg := downConfig{}
+	cfg := downConfig{IgnoreMissing: true}
 	cmd := &cobra.Command{
 		Use:   "down <container-id>",
 		Short: "Remove a devcontainer",
@@ -234,6 +366,62 @@ func newDownCommand(down DownFunc) *cobra.Command {
 			return down(cmd.Context(), cfg)
 		},
 	}
+	flags := cmd.Flags()
+	flags.BoolVar(&cfg.RemoveVolumes, "volumes", false, "Remove compose volumes")
+	flags.BoolVar(&cfg.IgnoreMissing, "ignore-missing", true, "Succeed when the container no longer exists")
+	return cmd
+}
+
+func newReadConfigurationCommand(read ReadConfigFunc) *cobra.Command {
+	cfg := readConfig{}
+	cmd := &cobra.Command{
+		Use:   "read-configuration",
+		Short: "Print the effective devcontainer configuration as JSON",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return errUsage
+			}
+			effective, err := read(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(effective)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&cfg.ConfigPath, "config", "", "Path to devcontainer.json")
+	return cmd
+}
+
+func newValidateCommand() *cobra.Command {
+	cfg := validateConfig{}
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a devcontainer configuration without Docker",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return errUsage
+			}
+			var options []devcontainer.StartOption
+			if cfg.ConfigPath != "" {
+				options = append(options, devcontainer.WithConfigPath(cfg.ConfigPath))
+			}
+			if err := devcontainer.ValidateConfig(cmd.Context(), options...); err != nil {
+				for _, line := range strings.Split(err.Error(), "\n") {
+					if _, writeErr := fmt.Fprintf(cmd.ErrOrStderr(), "error: %s\n", line); writeErr != nil {
+						return writeErr
+					}
+				}
+				return errInvalidConfig
+			}
+			_, err := fmt.Fprintln(cmd.OutOrStdout(), "configuration is valid")
+			return err
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&cfg.ConfigPath, "config", "", "Path to devcontainer.json")
 	return cmd
 }
 
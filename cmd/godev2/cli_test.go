@@ -3,8 +3,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,8 +31,11 @@ func TestStartCommand_ParsesFlagsAndCallsStart(t *testing.T) {
 	downFn := func(ctx context.Context, _ downConfig) error {
 		return nil
 	}
+	readFn := func(ctx context.Context, _ readConfig) (*devcontainer.DevcontainerConfig, error) {
+		return nil, nil
+	}
 
-	cmd := newRootCommand(startFn, stopFn, downFn)
+	cmd := newRootCommand(startFn, stopFn, downFn, readFn)
 	stdout := &bytes.Buffer{}
 	cmd.SetOut(stdout)
 	cmd.SetErr(io.Discard)
@@ -39,6 +48,8 @@ func TestStartCommand_ParsesFlagsAndCallsStart(t *testing.T) {
 		"--publish", "3000:3000",
 		"--mount", "type=bind,source=/tmp,target=/work",
 		"--label", "team=dev",
+		"--label-file", "ci.labels",
+		"--build-arg-file", "ci.args",
 		"--run-arg", "--cap-add=SYS_PTRACE",
 		"--rm",
 		"--detach=false",
@@ -46,6 +57,12 @@ func TestStartCommand_ParsesFlagsAndCallsStart(t *testing.T) {
 		"--timeout", "2s",
 		"--workdir", "/work",
 		"--network", "host",
+		"--name", "stack",
+		"--platform", "linux/arm64",
+		"--no-cache",
+		"--pull",
+		"--hostname", "devbox",
+		"--keep-override",
 	})
 
 	if err := cmd.Execute(); err != nil {
@@ -54,8 +71,8 @@ func TestStartCommand_ParsesFlagsAndCallsStart(t *testing.T) {
 	if !called {
 		t.Fatal("start function was not called")
 	}
-	if got.ConfigPath != "devcontainer.json" {
-		t.Fatalf("expected config path, got %q", got.ConfigPath)
+	if !reflect.DeepEqual(got.ConfigPaths, []string{"devcontainer.json"}) {
+		t.Fatalf("expected config path, got %q", got.ConfigPaths)
 	}
 	if got.Detach {
 		t.Fatalf("expected detach false")
@@ -75,6 +92,24 @@ func TestStartCommand_ParsesFlagsAndCallsStart(t *testing.T) {
 	if got.Network != "host" {
 		t.Fatalf("expected network host, got %q", got.Network)
 	}
+	if got.Name != "stack" {
+		t.Fatalf("expected name stack, got %q", got.Name)
+	}
+	if got.Platform != "linux/arm64" {
+		t.Fatalf("expected platform linux/arm64, got %q", got.Platform)
+	}
+	if !got.NoCache {
+		t.Fatalf("expected no-cache true")
+	}
+	if !got.Pull {
+		t.Fatalf("expected pull true")
+	}
+	if got.Hostname != "devbox" {
+		t.Fatalf("expected hostname devbox, got %q", got.Hostname)
+	}
+	if !got.KeepOverride {
+		t.Fatalf("expected keep-override true")
+	}
 	if !reflect.DeepEqual(got.Envs, []string{"FOO=bar", "BAZ=qux"}) {
 		t.Fatalf("unexpected envs: %#v", got.Envs)
 	}
@@ -87,6 +122,12 @@ func TestStartCommand_ParsesFlagsAndCallsStart(t *testing.T) {
 	if !reflect.DeepEqual(got.Labels, []string{"team=dev"}) {
 		t.Fatalf("unexpected labels: %#v", got.Labels)
 	}
+	if !reflect.DeepEqual(got.LabelFiles, []string{"ci.labels"}) {
+		t.Fatalf("unexpected label files: %#v", got.LabelFiles)
+	}
+	if !reflect.DeepEqual(got.ArgFiles, []string{"ci.args"}) {
+		t.Fatalf("unexpected build arg files: %#v", got.ArgFiles)
+	}
 	if !reflect.DeepEqual(got.RunArgs, []string{"--cap-add=SYS_PTRACE"}) {
 		t.Fatalf("unexpected run args: %#v", got.RunArgs)
 	}
@@ -107,8 +148,11 @@ func TestStartCommand_InvalidEnv(t *testing.T) {
 	downFn := func(ctx context.Context, _ downConfig) error {
 		return nil
 	}
+	readFn := func(ctx context.Context, _ readConfig) (*devcontainer.DevcontainerConfig, error) {
+		return nil, nil
+	}
 
-	cmd := newRootCommand(startFn, stopFn, downFn)
+	cmd := newRootCommand(startFn, stopFn, downFn, readFn)
 	cmd.SetOut(io.Discard)
 	cmd.SetErr(io.Discard)
 	cmd.SetArgs([]string{"devcontainer", "start", "--env", "INVALID"})
@@ -135,8 +179,11 @@ func TestStopCommand_ParsesFlagsAndCallsStop(t *testing.T) {
 	downFn := func(ctx context.Context, _ downConfig) error {
 		return nil
 	}
+	readFn := func(ctx context.Context, _ readConfig) (*devcontainer.DevcontainerConfig, error) {
+		return nil, nil
+	}
 
-	cmd := newRootCommand(startFn, stopFn, downFn)
+	cmd := newRootCommand(startFn, stopFn, downFn, readFn)
 	cmd.SetOut(io.Discard)
 	cmd.SetErr(io.Discard)
 	cmd.SetArgs([]string{"devcontainer", "stop", "--timeout", "3s", "container-123"})
@@ -169,11 +216,14 @@ func TestDownCommand_CallsDown(t *testing.T) {
 		got = cfg
 		return nil
 	}
+	readFn := func(ctx context.Context, _ readConfig) (*devcontainer.DevcontainerConfig, error) {
+		return nil, nil
+	}
 
-	cmd := newRootCommand(startFn, stopFn, downFn)
+	cmd := newRootCommand(startFn, stopFn, downFn, readFn)
 	cmd.SetOut(io.Discard)
 	cmd.SetErr(io.Discard)
-	cmd.SetArgs([]string{"devcontainer", "down", "container-123"})
+	cmd.SetArgs([]string{"devcontainer", "down", "--volumes", "container-123"})
 
 	if err := cmd.Execute(); err != nil {
 		t.Fatalf("execute: %v", err)
@@ -184,4 +234,331 @@ func TestDownCommand_CallsDown(t *testing.T) {
 	if got.ContainerID != "container-123" {
 		t.Fatalf("expected container ID, got %q", got.ContainerID)
 	}
+	if !got.RemoveVolumes {
+		t.Fatal("expected remove volumes")
+	}
+	if !got.IgnoreMissing {
+		t.Fatal("expected down to ignore missing containers by default")
+	}
+}
+
+func TestUpCommand_MatchesStart(t *testing.T) {
+	execute := func(command string) (startConfig, int, string) {
+		var got startConfig
+		var optionCount int
+		startFn := func(ctx context.Context, cfg startConfig, options []devcontainer.StartOption) (string, error) {
+			got = cfg
+			optionCount = len(options)
+			return "container-123", nil
+		}
+		stopFn := func(ctx context.Context, _ stopConfig) error {
+			return nil
+		}
+		downFn := func(ctx context.Context, _ downConfig) error {
+			return nil
+		}
+		readFn := func(ctx context.Context, _ readConfig) (*devcontainer.DevcontainerConfig, error) {
+			return nil, nil
+		}
+
+		cmd := newRootCommand(startFn, stopFn, downFn, readFn)
+		stdout := &bytes.Buffer{}
+		cmd.SetOut(stdout)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs([]string{
+			"devcontainer",
+			command,
+			"--config", "devcontainer.json",
+			"--env", "FOO=bar",
+			"--detach=false",
+		})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("execute %s: %v", command, err)
+		}
+		return got, optionCount, stdout.String()
+	}
+
+	startCfg, startOptions, startOut := execute("start")
+	upCfg, upOptions, upOut := execute("up")
+	if !reflect.DeepEqual(startCfg, upCfg) {
+		t.Fatalf("up config differs from start: %#v vs %#v", upCfg, startCfg)
+	}
+	if startOptions != upOptions {
+		t.Fatalf("up options differ from start: %d vs %d", upOptions, startOptions)
+	}
+	if startOut != upOut {
+		t.Fatalf("up output differs from start: %q vs %q", upOut, startOut)
+	}
+}
+
+func TestReadConfigurationCommand_PrintsJSON(t *testing.T) {
+	var got readConfig
+	startFn := func(ctx context.Context, cfg startConfig, _ []devcontainer.StartOption) (string, error) {
+		return "", nil
+	}
+	stopFn := func(ctx context.Context, _ stopConfig) error {
+		return nil
+	}
+	downFn := func(ctx context.Context, _ downConfig) error {
+		return nil
+	}
+	readFn := func(ctx context.Context, cfg readConfig) (*devcontainer.DevcontainerConfig, error) {
+		got = cfg
+		return &devcontainer.DevcontainerConfig{Name: "example", Image: "alpine:3.19"}, nil
+	}
+
+	cmd := newRootCommand(startFn, stopFn, downFn, readFn)
+	stdout := &bytes.Buffer{}
+	cmd.SetOut(stdout)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"devcontainer", "read-configuration", "--config", "devcontainer.json"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got.ConfigPath != "devcontainer.json" {
+		t.Fatalf("expected config path, got %q", got.ConfigPath)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &decoded); err != nil {
+		t.Fatalf("unmarshal output: %v: %s", err, stdout.String())
+	}
+	if decoded["name"] != "example" || decoded["image"] != "alpine:3.19" {
+		t.Fatalf("unexpected output: %s", stdout.String())
+	}
+}
+
+func TestStartCommand_InterruptCleansUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantStop bool
+		wantDown bool
+	}{
+		{name: "stop", args: []string{"--detach=false"}, wantStop: true},
+		{name: "rm", args: []string{"--detach=false", "--rm"}, wantDown: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interrupt := make(chan struct{})
+			original := notifyInterrupt
+			notifyInterrupt = func(ctx context.Context) (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(ctx)
+				go func() {
+					<-interrupt
+					cancel()
+				}()
+				return ctx, cancel
+			}
+			t.Cleanup(func() { notifyInterrupt = original })
+
+			startFn := func(ctx context.Context, _ startConfig, _ []devcontainer.StartOption) (string, error) {
+				close(interrupt)
+				<-ctx.Done()
+				return "container-123", ctx.Err()
+			}
+			var stopped, downed string
+			stopFn := func(ctx context.Context, cfg stopConfig) error {
+				if ctx.Err() != nil {
+					t.Fatalf("cleanup context canceled: %v", ctx.Err())
+				}
+				stopped = cfg.ContainerID
+				return nil
+			}
+			downFn := func(ctx context.Context, cfg downConfig) error {
+				downed = cfg.ContainerID
+				return nil
+			}
+			readFn := func(ctx context.Context, _ readConfig) (*devcontainer.DevcontainerConfig, error) {
+				return nil, nil
+			}
+
+			args := append([]string{"devcontainer", "start"}, tt.args...)
+			code := run(args, startFn, stopFn, downFn, readFn, io.Discard, io.Discard)
+			if code != 1 {
+				t.Fatalf("expected exit code 1, got %d", code)
+			}
+			if (stopped == "container-123") != tt.wantStop {
+				t.Fatalf("unexpected stop call: %q", stopped)
+			}
+			if (downed == "container-123") != tt.wantDown {
+				t.Fatalf("unexpected down call: %q", downed)
+			}
+		})
+	}
+}
+
+func TestValidateCommand_ReportsProblems(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "devcontainer.json")
+	config := `{
+  "name": "broken",
+  "runArgs": ["--no-such-flag"],
+  "forwardPorts": ["not-a-port"],
+  "features": {"./missing-feature": {}}
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	code := run([]string{"devcontainer", "validate", "--config", configPath}, nil, nil, nil, nil, stdout, stderr)
+
+	if code != 2 {
+		t.Fatalf("expected exit code 2, got %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"error: devcontainer.json must specify image or build",
+		"error: unsupported runArg",
+		"error: invalid port",
+		"error: feature ./missing-feature:",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("expected %q in stderr, got:\n%s", want, stderr.String())
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no stdout, got %q", stdout.String())
+	}
+}
+
+func TestValidateCommand_AcceptsValidConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "devcontainer.json")
+	config := `{"image": "alpine:3.19", "forwardPorts": [3000], "features": {"ghcr.io/devcontainers/features/go:1": {}}}`
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	code := run([]string{"devcontainer", "validate", "--config", configPath}, nil, nil, nil, nil, stdout, stderr)
+
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "configuration is valid") {
+		t.Fatalf("unexpected stdout: %q", stdout.String())
+	}
+}
+
+func TestStartCommand_ProgressGoesToStderr(t *testing.T) {
+	// A docker stub stands in for the compose CLI and a fake API answers the inspect of the started container.
+	stubDir := t.TempDir()
+	stub := "#!/bin/sh\ncase \"$*\" in\n*\" up \"*) echo 'Container app-1  Started' >&2 ;;\n*\" ps -q \"*) echo container-123 ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(stubDir, "docker"), []byte(stub), 0o755); err != nil {
+		t.Fatalf("write docker stub: %v", err)
+	}
+	t.Setenv("PATH", stubDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.49")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/container-123/json"):
+			_, _ = w.Write([]byte(`{"Id":"container-123","Name":"/app-1"}`))
+		case strings.HasSuffix(r.URL.Path, "/build"):
+			_, _ = io.Copy(io.Discard, r.Body)
+			_, _ = w.Write([]byte(`{"stream":"Step 1/2 : FROM alpine:3.19\n"}` + "\n" + `{"error":"build stopped"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DOCKER_HOST", "tcp://"+strings.TrimPrefix(server.URL, "http://"))
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "compose.yml"), []byte("services:\n  app:\n    image: alpine:3.19\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	configPath := filepath.Join(dir, "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"dockerComposeFile":"compose.yml","service":"app"}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	execute := func(args ...string) (string, string) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		code := run(append([]string{"devcontainer", "start", "--config", configPath}, args...), startWithConfig, nil, nil, nil, stdout, stderr)
+		if code != 0 {
+			t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+		}
+		return stdout.String(), stderr.String()
+	}
+
+	stdout, stderr := execute()
+	if stdout != "container-123\n" {
+		t.Fatalf("expected only the container ID on stdout, got %q", stdout)
+	}
+	if !strings.Contains(stderr, "Container app-1  Started") {
+		t.Fatalf("expected compose progress on stderr, got %q", stderr)
+	}
+
+	stdout, stderr = execute("--quiet")
+	if stdout != "container-123\n" || stderr != "" {
+		t.Fatalf("expected --quiet to suppress progress, got stdout %q stderr %q", stdout, stderr)
+	}
+
+	// A Dockerfile build streams its steps before the build error is reported.
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM alpine:3.19\n"), 0o644); err != nil {
+		t.Fatalf("write Dockerfile: %v", err)
+	}
+	buildPath := filepath.Join(dir, "build.json")
+	if err := os.WriteFile(buildPath, []byte(`{"build":{"dockerfile":"Dockerfile"}}`), 0o644); err != nil {
+		t.Fatalf("write build config: %v", err)
+	}
+	for _, quiet := range []bool{false, true} {
+		args := []string{"devcontainer", "start", "--config", buildPath}
+		if quiet {
+			args = append(args, "--quiet")
+		}
+		stderr := &bytes.Buffer{}
+		if code := run(args, startWithConfig, nil, nil, nil, io.Discard, stderr); code != 1 || !strings.Contains(stderr.String(), "build stopped") {
+			t.Fatalf("quiet=%v: expected the build error, got %d: %s", quiet, code, stderr.String())
+		}
+		if got := strings.Contains(stderr.String(), "Step 1/2 : FROM alpine:3.19"); got == quiet {
+			t.Fatalf("quiet=%v: unexpected build progress on stderr:\n%s", quiet, stderr.String())
+		}
+	}
+}
+
+func TestStartCommand_MergesRepeatedConfigs(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	overlay := filepath.Join(dir, "ci.json")
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(base, []byte(`{"image":"alpine:3.19","runArgs":["--init"],"containerEnv":{"MODE":"dev"}}`), 0o644); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	if err := os.WriteFile(overlay, []byte(`{"runArgs":["--cap-add=SYS_PTRACE"],"containerEnv":{"MODE":"ci"}}`), 0o644); err != nil {
+		t.Fatalf("write overlay: %v", err)
+	}
+	var effective *devcontainer.DevcontainerConfig
+	startFn := func(ctx context.Context, cfg startConfig, options []devcontainer.StartOption) (string, error) {
+		var err error
+		effective, err = devcontainer.EffectiveConfig(options...)
+		return "container-123", err
+	}
+	stderr := &bytes.Buffer{}
+	code := run([]string{"devcontainer", "start", "--quiet", "--config", base, "--config", overlay}, startFn, nil, nil, nil, io.Discard, stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	if effective.Image != "alpine:3.19" || effective.ContainerEnv["MODE"] != "ci" {
+		t.Fatalf("unexpected effective config: %#v", effective)
+	}
+	if !reflect.DeepEqual(effective.RunArgs, []string{"--init", "--cap-add=SYS_PTRACE"}) {
+		t.Fatalf("unexpected merged runArgs: %#v", effective.RunArgs)
+	}
+
+	code = run([]string{"devcontainer", "start", "--config", base, "--config", filepath.Join(dir, "missing.json")}, startFn, nil, nil, nil, io.Discard, stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "missing.json") {
+		t.Fatalf("expected missing overlay to fail, got %d: %s", code, stderr.String())
+	}
+
+	help := &bytes.Buffer{}
+	if code := run([]string{"devcontainer", "start", "--help"}, startFn, nil, nil, nil, help, io.Discard); code != 0 {
+		t.Fatalf("unexpected help exit code %d", code)
+	}
+	if !strings.Contains(help.String(), "relative paths resolve against the first file's directory") {
+		t.Fatalf("expected --config help to explain overlay paths:\n%s", help.String())
+	}
 }
@@ -3,5 +3,5 @@ package main
 import "os"
 
 func main() {
-	os.Exit(run(os.Args[1:], startWithConfig, stopWithConfig, downWithConfig, os.Stdout, os.Stderr))
+	os.Exit(run(os.Args[1:], startWithConfig, stopWithConfig, downWithConfig, readConfigWithConfig, os.Stdout, os.Stderr))
 }
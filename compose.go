@@ -6,23 +6,33 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 )
 
 func isComposeConfig(cfg *DevcontainerConfig) bool {
 	return len(cfg.DockerComposeFile) > 0 || cfg.Service != ""
 }
 
+var errComposeFileRequired = errors.New("dockerComposeFile is required when using docker compose")
+
+var composeOverrideCandidates = []string{
+	"compose.override.yaml",
+	"compose.override.yml",
+	"docker-compose.override.yaml",
+	"docker-compose.override.yml",
+}
+
 func validateConfig(cfg *DevcontainerConfig) error {
 	if isComposeConfig(cfg) {
-		if len(cfg.DockerComposeFile) == 0 {
-			return errors.New("dockerComposeFile is required when using docker compose")
-		}
 		if cfg.Service == "" {
 			return errors.New("service is required when using docker compose")
 		}
 		if cfg.Image != "" || cfg.Build != nil {
 			return errors.New("dockerComposeFile cannot be combined with image or build")
 		}
+		if len(cfg.DockerComposeFile) == 0 {
+			return errComposeFileRequired
+		}
 		return nil
 	}
 	if cfg.Image == "" && cfg.Build == nil {
@@ -62,16 +72,55 @@ func resolveComposeWorkspacePaths(configPath string, cfg *DevcontainerConfig) (s
 
 func resolveComposeFiles(configPath string, cfg *DevcontainerConfig) ([]string, error) {
 	if len(cfg.DockerComposeFile) == 0 {
-		return nil, errors.New("dockerComposeFile is required when using docker compose")
+		return nil, errComposeFileRequired
+	}
+	return resolveComposeFileList(filepath.Dir(configPath), cfg.DockerComposeFile)
+}
+
+// discoverComposeFiles resolves compose files like resolveComposeFiles and additionally
+// falls back to COMPOSE_FILE and auto-includes a sibling compose override file.
+func discoverComposeFiles(configPath, workspaceRoot string, cfg *DevcontainerConfig, env map[string]string) ([]string, error) {
+	var (
+		files []string
+		err   error
+	)
+	if len(cfg.DockerComposeFile) > 0 {
+		files, err = resolveComposeFiles(configPath, cfg)
+	} else {
+		entries := splitComposeFileEnv(env["COMPOSE_FILE"], env["COMPOSE_PATH_SEPARATOR"])
+		if len(entries) == 0 {
+			return nil, errComposeFileRequired
+		}
+		files, err = resolveComposeFileList(workspaceRoot, entries)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range composeOverrideCandidates {
+		override := filepath.Join(filepath.Dir(files[0]), candidate)
+		stat, err := os.Stat(override)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		if !containsString(files, override) {
+			files = append(files, override)
+		}
+		break
 	}
-	configDir := filepath.Dir(configPath)
-	files := make([]string, 0, len(cfg.DockerComposeFile))
-	for _, file := range cfg.DockerComposeFile {
+	return files, nil
+}
+
+func resolveComposeFileList(baseDir string, entries []string) ([]string, error) {
+	files := make([]string, 0, len(entries))
+	for _, file := range entries {
 		if file == "" {
 			return nil, errors.New("dockerComposeFile entry cannot be empty")
 		}
-		abs := filepath.Clean(filepath.Join(configDir, file))
-		abs, err := filepath.Abs(abs)
+		abs := file
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(baseDir, file)
+		}
+		abs, err := filepath.Abs(filepath.Clean(abs))
 		if err != nil {
 			return nil, err
 		}
@@ -86,3 +135,30 @@ func resolveComposeFiles(configPath string, cfg *DevcontainerConfig) ([]string,
 	}
 	return files, nil
 }
+
+func splitComposeFileEnv(value, separator string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	if separator == "" {
+		separator = string(os.PathListSeparator)
+	}
+	var entries []string
+	for _, entry := range strings.Split(value, separator) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
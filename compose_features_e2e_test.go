@@ -30,7 +30,7 @@ func TestDockerCompose_FeaturesImage(t *testing.T) {
 	if err != nil {
 		t.Fatalf("resolveComposeWorkspacePaths: %v", err)
 	}
-	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg)
+	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg, nil, "", "")
 	if err != nil {
 		t.Fatalf("resolveFeatures: %v", err)
 	}
@@ -41,7 +41,7 @@ func TestDockerCompose_FeaturesImage(t *testing.T) {
 	if err != nil {
 		t.Fatalf("resolveComposeFiles: %v", err)
 	}
-	projectName := resolveComposeProjectName(cfg, workspaceRoot, vars["devcontainerId"])
+	projectName := resolveComposeProjectName(cfg, workspaceRoot, vars["devcontainerId"], "")
 
 	inspectCtx, cancelInspect := context.WithTimeout(context.Background(), 10*time.Second)
 	if _, err := cli.ImageInspect(inspectCtx, baseImage); err != nil {
@@ -52,7 +52,7 @@ func TestDockerCompose_FeaturesImage(t *testing.T) {
 	t.Cleanup(func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 		defer cancel()
-		_ = composeDown(ctx, workspaceRoot, projectName, composeFiles)
+		_ = composeDown(ctx, workspaceRoot, projectName, composeFiles, "", true)
 		cleanupContainer(t, cli, containerID)
 		cleanupImage(t, cli, featuresImage)
 		if removeBaseImage {
@@ -79,7 +79,7 @@ func TestDockerCompose_FeaturesImage(t *testing.T) {
 
 	downCtx, cancelDown := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancelDown()
-	if err := composeDown(downCtx, workspaceRoot, projectName, composeFiles); err != nil {
+	if err := composeDown(downCtx, workspaceRoot, projectName, composeFiles, "", true); err != nil {
 		t.Fatalf("compose down: %v", err)
 	}
 	containerID = ""
@@ -5,67 +5,114 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/compose-spec/compose-go/loader"
 	"github.com/compose-spec/compose-go/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
 	"gopkg.in/yaml.v3"
 )
 
-func startComposeDevcontainer(ctx context.Context, configPath string, cfg *DevcontainerConfig, options startOptions) (string, error) {
+func startComposeDevcontainer(ctx context.Context, configPath string, cfg *DevcontainerConfig, options startOptions) (result *StartResult, err error) {
 	if err := validateComposeOptions(options); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	workspaceRoot, workspaceFolder, vars, err := resolveComposeWorkspacePaths(configPath, cfg)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	features, err := resolveFeatures(ctx, configPath, workspaceRoot, cfg)
+	overrideDevcontainerID(vars, options.DevcontainerID)
+	lock, err := acquireStartLock(ctx, vars["devcontainerId"], options.logger())
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	defer lock.release()
+	features, err := resolveStartFeatures(ctx, configPath, workspaceRoot, cfg, vars, options)
+	if err != nil {
+		return nil, err
+	}
+	defer features.removeTempDirs()
+	if err := verifyFeatureVersions(features, options.StrictFeatures); err != nil {
+		return nil, err
+	}
+	if options.NoPrivileged {
+		if err := checkPrivilegedConfig(cfg, features); err != nil {
+			return nil, err
+		}
 	}
 	baseEnv := cfg.ContainerEnv
 	if features != nil && len(features.ContainerEnv) > 0 {
 		baseEnv, err = mergeEnvMaps(features.ContainerEnv, baseEnv, vars)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 	envMap, err := mergeEnvMaps(baseEnv, options.Env, vars)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if err := runLifecycleCommands(ctx, "initializeCommand", cfg.InitializeCommand, hostLifecycleRunner(workspaceRoot, vars, envMap)); err != nil {
-		return "", err
+	passthroughEnv(envMap, options.PassEnv, options.Env)
+	if err := runLifecycleCommands(ctx, "initializeCommand", cfg.InitializeCommand, observeLifecycle(redactLifecycle(hostLifecycleRunner(workspaceRoot, vars, envMap), options.secretRedactor()), options.OnLifecycle)); err != nil {
+		return nil, err
 	}
-	composeFiles, err := resolveComposeFiles(configPath, cfg)
+	composeEnv, err := loadComposeEnvironment(workspaceRoot)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	var composeFiles []string
+	if options.ComposeDiscovery {
+		composeFiles, err = discoverComposeFiles(configPath, workspaceRoot, cfg, composeEnv)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		composeFiles, err = resolveComposeFiles(configPath, cfg)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	projectName := resolveComposeProjectName(cfg, workspaceRoot, vars["devcontainerId"])
+	nameOverride := options.Name
+	if nameOverride == "" {
+		nameOverride = composeEnv["COMPOSE_PROJECT_NAME"]
+	}
+	projectName := resolveComposeProjectName(cfg, workspaceRoot, vars["devcontainerId"], nameOverride)
 	project, err := loadComposeProject(ctx, composeFiles, workspaceRoot, projectName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	labels := mergeLabels(options.Labels, nil)
-	labels["devcontainer.config_path"] = configPath
+	labels, err := containerLabels(options, nil, configPath, workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
+	labels[composeFilesLabel] = strings.Join(composeFiles, string(os.PathListSeparator))
 
 	service, err := findComposeService(project, cfg.Service)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if options.NoPrivileged {
+		if err := checkPrivilegedService(service); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateHookServices(options.HookServices, project, cfg.RunServices); err != nil {
+		return nil, err
 	}
 	cli, err := newDockerClient()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer func() {
 		_ = cli.Close()
@@ -74,55 +121,89 @@ func startComposeDevcontainer(ctx context.Context, configPath string, cfg *Devco
 	if features != nil {
 		baseImage := strings.TrimSpace(service.Image)
 		if baseImage == "" {
-			return "", errors.New("docker compose features require service.image")
+			return nil, errors.New("docker compose features require service.image")
+		}
+		platform, err := parsePlatform(service.Platform)
+		if err != nil {
+			return nil, err
 		}
-		if err := pullImage(ctx, cli, baseImage); err != nil {
-			return "", err
+		if err := pullImage(ctx, cli, baseImage, service.Platform, options); err != nil {
+			return nil, err
 		}
-		baseUser, err := imageDefaultUser(ctx, cli, baseImage)
+		baseUser, err := imageDefaultUser(ctx, cli, baseImage, platform)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		featureImage, err = buildFeaturesImage(ctx, cli, baseImage, baseUser, workspaceRoot, vars["devcontainerId"], cfg, features.Order, vars)
+		featureImage, err = buildFeaturesImage(ctx, cli, baseImage, baseUser, workspaceRoot, vars["devcontainerId"], cfg, features.Order, vars, options)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+		features.removeTempDirs()
 	}
-	override, err := buildComposeOverride(cfg, envMap, labels, workspaceFolder, service, features, featureImage)
-	if err != nil {
-		return "", err
+	var featureCommand []string
+	if featureImage != "" && hasFeatureEntrypoints(features.Order) {
+		featureCommand, err = composeServiceCommand(ctx, cli, service, featureImage)
+		if err != nil {
+			return nil, err
+		}
 	}
-	overrideFile, err := writeComposeOverride(override)
+	override, err := buildComposeOverride(cfg, options, envMap, labels, workspaceFolder, service, features, featureImage, featureCommand)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if overrideFile != "" {
-		defer func() {
-			_ = os.Remove(overrideFile)
-		}()
+	persistentPath := persistentComposeOverridePath(workspaceRoot, project.Name)
+	if options.PersistOverride && len(override) > 0 {
+		// The label lives in the override itself, so it is only added once there is a file to record.
+		labels[composeOverrideLabel] = persistentPath
+		override, err = buildComposeOverride(cfg, options, envMap, labels, workspaceFolder, service, features, featureImage, featureCommand)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if err := composeUp(ctx, workspaceRoot, project.Name, composeFiles, overrideFile, cfg.RunServices); err != nil {
-		return "", err
+	var overrideFile string
+	if options.PersistOverride {
+		overrideFile, err = writeComposeOverrideFile(persistentPath, override)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		overrideFile, err = writeComposeOverride(override)
+		if err != nil {
+			return nil, err
+		}
+		if overrideFile != "" && !options.KeepOverride {
+			defer func() {
+				_ = os.Remove(overrideFile)
+			}()
+		}
 	}
-	containerID, err := composePrimaryContainerID(ctx, workspaceRoot, project.Name, composeFiles, overrideFile, cfg.Service)
-	if err != nil {
-		return "", err
+	if overrideFile != "" && options.KeepOverride {
+		options.logger().Info("kept compose override", "path", overrideFile)
 	}
-	lifecycleEnv, err := buildLifecycleEnv(envMap, cfg.RemoteEnv, vars)
+	existed, err := composeProjectExists(ctx, workspaceRoot, project.Name, composeFiles, overrideFile)
 	if err != nil {
-		return containerID, err
+		return nil, err
 	}
-	remoteUser := cfg.RemoteUser
-	if remoteUser == "" {
-		remoteUser = cfg.ContainerUser
+	redactor := options.secretRedactor()
+	output, flush := redactWriter(options.ComposeOutput, redactor)
+	err = composeUp(ctx, workspaceRoot, project.Name, composeFiles, overrideFile, cfg.RunServices, composeSecretEnv(options, envMap), output)
+	flush()
+	if err != nil {
+		return nil, redactError(err, redactor)
 	}
-	runner := containerLifecycleRunner(cli, containerID, workspaceFolder, remoteUser, vars, envMap, envMapToSlice(lifecycleEnv))
-	if features != nil {
-		rootRunner := containerLifecycleRunner(cli, containerID, workspaceFolder, "root", vars, envMap, envMapToSlice(lifecycleEnv))
-		if err := runFeatureEntrypoints(ctx, features.Order, vars, rootRunner); err != nil {
-			return containerID, err
+	// A stack this call created that failed to become ready, including on ctx cancel, is brought down again so it is
+	// not left running half-initialized; volumes are kept. A stack that compose up only reused is left running.
+	ready := false
+	defer func() {
+		if err == nil || ready || existed || options.KeepOnFailure {
+			return
 		}
-	}
+		if downErr := composeDown(context.WithoutCancel(ctx), workspaceRoot, project.Name, composeFiles, overrideFile, false); downErr != nil {
+			err = errors.Join(err, fmt.Errorf("bring down compose stack: %w", downErr))
+			return
+		}
+		result = nil
+	}()
 	userHooks := map[string]*LifecycleCommands{
 		"onCreateCommand":      cfg.OnCreateCommand,
 		"updateContentCommand": cfg.UpdateContentCommand,
@@ -130,21 +211,117 @@ func startComposeDevcontainer(ctx context.Context, configPath string, cfg *Devco
 		"postStartCommand":     cfg.PostStartCommand,
 		"postAttachCommand":    cfg.PostAttachCommand,
 	}
-	if err := runLifecycleWithFeatures(ctx, features, userHooks, runner); err != nil {
-		return containerID, err
+	lookupContainer := composePrimaryContainerID
+	if hasLifecycleCommands(features, userHooks) {
+		lookupContainer = composeSingleContainerID
 	}
+	containerID, err := lookupContainer(ctx, workspaceRoot, project.Name, composeFiles, overrideFile, cfg.Service)
+	if err != nil {
+		return nil, err
+	}
+	result = &StartResult{ContainerID: containerID, BaseImage: strings.TrimSpace(service.Image), FeatureImage: featureImage}
+	if options.PersistOverride || options.KeepOverride {
+		result.ComposeOverride = overrideFile
+	}
+	if err := describeContainer(ctx, cli, result); err != nil {
+		return result, err
+	}
+	if options.HealthyTimeout > 0 {
+		if err := waitForHealthy(ctx, cli, containerID, options.HealthyTimeout); err != nil {
+			return result, err
+		}
+	}
+	if options.WorkspaceWait > 0 {
+		if err := waitForWorkspace(ctx, cli, containerID, workspaceFolder, options.WorkspaceMarker, options.WorkspaceWait); err != nil {
+			return result, err
+		}
+	}
+	expansionEnv := seedImageEnv(ctx, cli, containerID, composeExpansionEnv(service, envMap), options.logger())
+	lifecycleEnv, err := buildLifecycleEnv(expansionEnv, cfg.RemoteEnv, vars)
+	if err != nil {
+		return result, err
+	}
+	remoteUser := cfg.RemoteUser
+	if remoteUser == "" {
+		remoteUser = cfg.ContainerUser
+	}
+	runner := observeLifecycle(redactLifecycle(containerLifecycleRunner(cli, containerID, workspaceFolder, remoteUser, options.StageUsers, vars, expansionEnv, envMapToSlice(lifecycleEnv)), redactor), options.OnLifecycle)
+	serviceRunners := make(map[string][]lifecycleRunner, len(options.HookServices))
+	for hook, services := range options.HookServices {
+		for _, serviceName := range services {
+			if serviceName == cfg.Service {
+				continue
+			}
+			serviceID, err := composeSingleContainerID(ctx, workspaceRoot, project.Name, composeFiles, overrideFile, serviceName)
+			if err != nil {
+				return result, err
+			}
+			serviceRunner := redactLifecycle(containerLifecycleRunner(cli, serviceID, "", "", options.StageUsers, vars, envMap, nil), redactor)
+			serviceRunners[hook] = append(serviceRunners[hook], observeLifecycle(serviceRunner, options.OnLifecycle))
+		}
+	}
+	if err := runLifecycleWithFeatures(ctx, features, userHooks, runner, serviceRunners); err != nil {
+		return result, err
+	}
+	ready = true
+	lock.release()
 	if !options.Detach {
 		if err := waitForContainerExit(ctx, containerID); err != nil {
-			return containerID, err
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// composeFilesLabel records the resolved compose files so stop/down reuse the same set.
+const composeFilesLabel = "devcontainer.compose_files"
+
+func composeFilesFromLabels(labels map[string]string) ([]string, error) {
+	value := labels[composeFilesLabel]
+	if value == "" {
+		return nil, nil
+	}
+	files := strings.Split(value, string(os.PathListSeparator))
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			return nil, fmt.Errorf("docker compose file not found: %s", file)
 		}
 	}
-	return containerID, nil
+	return files, nil
+}
+
+const (
+	// composeOverrideLabel records the persistent override path so stop/down reuse it.
+	composeOverrideLabel = "devcontainer.compose_override"
+	// composeOverrideFilePrefix names the persistent overrides written under .devcontainer.
+	composeOverrideFilePrefix = ".godev2-compose-override-"
+)
+
+// persistentComposeOverridePath returns the persistent override for projectName, so stacks of one workspace
+// started under different project names keep separate files.
+func persistentComposeOverridePath(workspaceRoot, projectName string) string {
+	return filepath.Join(workspaceRoot, ".devcontainer", composeOverrideFilePrefix+projectName+".yml")
+}
+
+// composeOverrideFromLabels returns the recorded override path when the file still exists.
+func composeOverrideFromLabels(labels map[string]string) string {
+	path := labels[composeOverrideLabel]
+	if path == "" {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
 }
 
 func validateComposeOptions(options startOptions) error {
 	if len(options.ExtraPublish) > 0 {
 		return errors.New("compose does not support extra publishes")
 	}
+	if options.EphemeralPorts {
+		return errors.New("compose does not support ephemeral app ports")
+	}
 	if len(options.ExtraMounts) > 0 {
 		return errors.New("compose does not support extra mounts")
 	}
@@ -157,13 +334,46 @@ func validateComposeOptions(options startOptions) error {
 	if options.Workdir != "" {
 		return errors.New("compose does not support workdir override")
 	}
-	if options.Resources.CPUQuota != 0 || options.Resources.Memory != "" {
+	if options.Entrypoint != nil {
+		return errors.New("compose does not support entrypoint override; set entrypoint on the service")
+	}
+	if options.WorkspaceMount != "" || options.NoWorkspaceMount {
+		return errors.New("compose does not support workspace mount override; mount the workspace in the service")
+	}
+	if options.Resources != (ResourceLimits{}) {
 		return errors.New("compose does not support resource limits")
 	}
+	if options.ValidateBinds {
+		return errors.New("compose does not support bind source validation")
+	}
+	if options.ReadOnlyRootfs {
+		return errors.New("compose does not support read-only rootfs; set read_only on the service")
+	}
+	if options.NoNewPrivileges {
+		return errors.New("compose does not support no-new-privileges; set security_opt on the service")
+	}
+	if options.Platform != "" {
+		return errors.New("compose does not support platform override; set platform on the service")
+	}
+	if options.BuildContext != "" {
+		return errors.New("compose does not support build context override; set build.context on the service")
+	}
+	if options.ImageTag != "" {
+		return errors.New("compose does not support image tagging; tag the service image after start")
+	}
+	if options.AutoForwardPorts {
+		return errors.New("compose does not support auto-forward port detection")
+	}
+	if options.Interactive {
+		return errors.New("compose does not support interactive attach; use docker compose attach")
+	}
 	return nil
 }
 
-func resolveComposeProjectName(cfg *DevcontainerConfig, workspaceRoot, devcontainerID string) string {
+func resolveComposeProjectName(cfg *DevcontainerConfig, workspaceRoot, devcontainerID, override string) string {
+	if name := sanitizeName(override); name != "" {
+		return name
+	}
 	if cfg.Name != "" {
 		return sanitizeName(cfg.Name)
 	}
@@ -174,6 +384,19 @@ func resolveComposeProjectName(cfg *DevcontainerConfig, workspaceRoot, devcontai
 	return fmt.Sprintf("godev-%s-%s", base, devcontainerID)
 }
 
+// composeTargetProjectName prefers the project label written by docker compose so stop/down
+// address the same project that was started, even when it was named via WithName or env.
+func composeTargetProjectName(labels map[string]string, cfg *DevcontainerConfig, workspaceRoot, devcontainerID string) (string, error) {
+	if name := labels["com.docker.compose.project"]; name != "" {
+		return name, nil
+	}
+	env, err := loadComposeEnvironment(workspaceRoot)
+	if err != nil {
+		return "", err
+	}
+	return resolveComposeProjectName(cfg, workspaceRoot, devcontainerID, env["COMPOSE_PROJECT_NAME"]), nil
+}
+
 func loadComposeProject(ctx context.Context, composeFiles []string, workingDir, projectName string) (*types.Project, error) {
 	configFiles := make([]types.ConfigFile, 0, len(composeFiles))
 	for _, file := range composeFiles {
@@ -219,23 +442,55 @@ func findComposeService(project *types.Project, serviceName string) (*types.Serv
 	return nil, fmt.Errorf("service %s not found in compose project", serviceName)
 }
 
-func buildComposeOverride(cfg *DevcontainerConfig, envMap map[string]string, labels map[string]string, workspaceFolder string, service *types.ServiceConfig, features *ResolvedFeatures, featureImage string) ([]byte, error) {
+// composeServiceCommand returns the command the service would run without a devcontainer override,
+// so it can follow the feature entrypoint wrapper.
+func composeServiceCommand(ctx context.Context, cli *client.Client, service *types.ServiceConfig, image string) ([]string, error) {
+	if len(service.Entrypoint) > 0 {
+		return append(append([]string{}, service.Entrypoint...), service.Command...), nil
+	}
+	entrypoint, cmd, err := imageCommand(ctx, cli, image)
+	if err != nil {
+		return nil, err
+	}
+	if len(service.Command) > 0 {
+		cmd = service.Command
+	}
+	return append(append([]string{}, entrypoint...), cmd...), nil
+}
+
+// buildComposeOverride renders the override file applied on top of the project's compose files.
+// featureCommand is used as the service command behind the feature entrypoint wrapper unless overrideCommand is set.
+func buildComposeOverride(cfg *DevcontainerConfig, options startOptions, envMap map[string]string, labels map[string]string, workspaceFolder string, service *types.ServiceConfig, features *ResolvedFeatures, featureImage string, featureCommand []string) ([]byte, error) {
 	serviceOverride := make(map[string]any)
 	if len(envMap) > 0 {
-		serviceOverride["environment"] = envMap
+		serviceOverride["environment"] = composeEnvironment(envMap, options.SecretEnv)
 	}
 	if len(labels) > 0 {
-		serviceOverride["labels"] = labels
+		serviceOverride["labels"] = escapeComposeValues(labels)
 	}
 	if cfg.ContainerUser != "" {
 		serviceOverride["user"] = cfg.ContainerUser
 	}
+	if options.Hostname != "" {
+		serviceOverride["hostname"] = options.Hostname
+	}
+	if options.StopSignal != "" {
+		serviceOverride["stop_signal"] = options.StopSignal
+	}
+	if options.StopTimeout > 0 {
+		serviceOverride["stop_grace_period"] = options.StopTimeout.String()
+	}
 	overrideCommand := false
 	if cfg.OverrideCommand != nil {
 		overrideCommand = *cfg.OverrideCommand
 	}
 	if overrideCommand {
-		serviceOverride["command"] = []string{"/bin/sh", "-c", "while sleep 1000; do :; done"}
+		serviceOverride["command"] = options.KeepAlive
+		if len(options.KeepAlive) == 0 {
+			// Compose creates the container, so the shell cannot be checked first; images without /bin/sh need
+			// WithKeepAliveCommand.
+			serviceOverride["command"] = keepAliveCommand(defaultKeepAliveShell)
+		}
 	}
 	if workspaceFolder != "" && service.WorkingDir == "" {
 		serviceOverride["working_dir"] = workspaceFolder
@@ -243,6 +498,12 @@ func buildComposeOverride(cfg *DevcontainerConfig, envMap map[string]string, lab
 	if featureImage != "" {
 		serviceOverride["image"] = featureImage
 	}
+	if features != nil && hasFeatureEntrypoints(features.Order) {
+		serviceOverride["entrypoint"] = []string{featureEntrypointWrapper}
+		if !overrideCommand && len(featureCommand) > 0 {
+			serviceOverride["command"] = featureCommand
+		}
+	}
 	if features != nil {
 		if features.Privileged {
 			serviceOverride["privileged"] = true
@@ -255,6 +516,11 @@ func buildComposeOverride(cfg *DevcontainerConfig, envMap map[string]string, lab
 			merged = appendUnique(merged, features.CapAdd...)
 			serviceOverride["cap_add"] = merged
 		}
+		if len(features.CapDrop) > 0 {
+			merged := appendUnique(nil, service.CapDrop...)
+			merged = appendUnique(merged, features.CapDrop...)
+			serviceOverride["cap_drop"] = merged
+		}
 		if len(features.SecurityOpt) > 0 {
 			merged := appendUnique(nil, service.SecurityOpt...)
 			merged = appendUnique(merged, features.SecurityOpt...)
@@ -281,6 +547,42 @@ func buildComposeOverride(cfg *DevcontainerConfig, envMap map[string]string, lab
 	return yaml.Marshal(override)
 }
 
+// composeEnvironment renders the service environment. Secret keys are written without a value so docker compose
+// takes them from its own process environment, which composeSecretEnv fills, and they never reach the override file.
+func composeEnvironment(envMap map[string]string, secretKeys []string) map[string]any {
+	environment := make(map[string]any, len(envMap))
+	for key, value := range escapeComposeValues(envMap) {
+		environment[key] = value
+	}
+	for _, key := range secretKeys {
+		if _, ok := environment[key]; ok {
+			environment[key] = nil
+		}
+	}
+	return environment
+}
+
+// composeSecretEnv returns the KEY=value entries for the WithSecretEnv keys in envMap, passed to docker compose up.
+func composeSecretEnv(options startOptions, envMap map[string]string) []string {
+	var env []string
+	for _, key := range options.SecretEnv {
+		if value, ok := envMap[key]; ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// escapeComposeValues doubles "$" in already expanded values so docker compose
+// does not interpolate them a second time against the project environment.
+func escapeComposeValues(values map[string]string) map[string]string {
+	escaped := make(map[string]string, len(values))
+	for key, value := range values {
+		escaped[key] = strings.ReplaceAll(value, "$", "$$")
+	}
+	return escaped
+}
+
 func composeVolumeSpecs(mounts []MountSpec) ([]string, error) {
 	if len(mounts) == 0 {
 		return nil, nil
@@ -343,46 +645,130 @@ func writeComposeOverride(content []byte) (string, error) {
 	return file.Name(), nil
 }
 
-func composeUp(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile string, services []string) error {
+func writeComposeOverrideFile(path string, content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// composeUp starts the project; env adds variables to the docker compose process environment.
+func composeUp(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile string, services []string, env []string, output io.Writer) error {
 	args := composeBaseArgs(projectDir, projectName, composeFiles, overrideFile)
 	args = append(args, "up", "-d")
 	if len(services) > 0 {
 		args = append(args, services...)
 	}
-	_, err := runDockerCompose(ctx, projectDir, args)
+	_, err := runDockerComposeEnv(ctx, projectDir, args, env, output)
 	return err
 }
 
-func composeStop(ctx context.Context, projectDir, projectName string, composeFiles []string, timeout time.Duration) error {
-	args := composeBaseArgs(projectDir, projectName, composeFiles, "")
+func composeStop(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile string, timeout time.Duration) error {
+	args := composeBaseArgs(projectDir, projectName, composeFiles, overrideFile)
 	args = append(args, "stop")
 	if timeout > 0 {
 		args = append(args, "--timeout", fmt.Sprintf("%d", int(timeout.Seconds())))
 	}
-	_, err := runDockerCompose(ctx, projectDir, args)
+	_, err := runDockerCompose(ctx, projectDir, args, nil)
 	return err
 }
 
-func composeDown(ctx context.Context, projectDir, projectName string, composeFiles []string) error {
-	args := composeBaseArgs(projectDir, projectName, composeFiles, "")
-	args = append(args, "down", "--volumes", "--remove-orphans")
-	_, err := runDockerCompose(ctx, projectDir, args)
+func composeDown(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile string, removeVolumes bool) error {
+	args := composeBaseArgs(projectDir, projectName, composeFiles, overrideFile)
+	args = append(args, "down", "--remove-orphans")
+	if removeVolumes {
+		args = append(args, "--volumes")
+	}
+	_, err := runDockerCompose(ctx, projectDir, args, nil)
 	return err
 }
 
+// composeProjectExists reports whether the project already has containers, running or stopped.
+func composeProjectExists(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile string) (bool, error) {
+	args := composeBaseArgs(projectDir, projectName, composeFiles, overrideFile)
+	args = append(args, "ps", "-a", "-q")
+	output, err := runDockerCompose(ctx, projectDir, args, nil)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// composePrimaryContainerID returns the service container with the lowest name, which is replica 1
+// when the service is scaled, so repeated lookups agree.
 func composePrimaryContainerID(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile, serviceName string) (string, error) {
+	ids, err := composeServiceContainerIDs(ctx, projectDir, projectName, composeFiles, overrideFile, serviceName)
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// composeSingleContainerID returns the service's container for running lifecycle hooks and rejects a
+// scaled service, since hooks would only reach one of its replicas.
+func composeSingleContainerID(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile, serviceName string) (string, error) {
+	ids, err := composeServiceContainerIDs(ctx, projectDir, projectName, composeFiles, overrideFile, serviceName)
+	if err != nil {
+		return "", err
+	}
+	if len(ids) > 1 {
+		return "", fmt.Errorf("service %s runs %d containers; lifecycle hooks need a single container, so scale it to 1", serviceName, len(ids))
+	}
+	return ids[0], nil
+}
+
+// composeServiceContainerIDs lists the service's container IDs ordered by container name.
+func composeServiceContainerIDs(ctx context.Context, projectDir, projectName string, composeFiles []string, overrideFile, serviceName string) ([]string, error) {
 	args := composeBaseArgs(projectDir, projectName, composeFiles, overrideFile)
 	args = append(args, "ps", "-q", serviceName)
-	output, err := runDockerCompose(ctx, projectDir, args)
+	output, err := runDockerCompose(ctx, projectDir, args, nil)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	ids := strings.Fields(output)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("primary service container not found: %s", serviceName)
 	}
-	id := strings.TrimSpace(output)
-	if id == "" {
-		return "", fmt.Errorf("primary service container not found: %s", serviceName)
+	if len(ids) == 1 {
+		return ids, nil
 	}
-	lines := strings.Split(id, "\n")
-	return strings.TrimSpace(lines[0]), nil
+	names, err := dockerContainerNames(ctx, projectDir, ids)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return names[ids[i]] < names[ids[j]]
+	})
+	return ids, nil
+}
+
+// dockerContainerNames maps each container ID to its name using docker inspect.
+func dockerContainerNames(ctx context.Context, projectDir string, ids []string) (map[string]string, error) {
+	args := append([]string{"inspect", "--format", "{{.Name}}"}, ids...)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Dir = projectDir
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, formatComposeError("docker", args, stdout.String(), stderr.String(), err)
+	}
+	lines := strings.Fields(stdout.String())
+	if len(lines) != len(ids) {
+		return nil, fmt.Errorf("docker inspect returned %d names for %d containers", len(lines), len(ids))
+	}
+	names := make(map[string]string, len(ids))
+	for i, id := range ids {
+		names[id] = strings.TrimPrefix(lines[i], "/")
+	}
+	return names, nil
 }
 
 func composeBaseArgs(projectDir, projectName string, composeFiles []string, overrideFile string) []string {
@@ -397,21 +783,137 @@ func composeBaseArgs(projectDir, projectName string, composeFiles []string, over
 	return args
 }
 
-func runDockerCompose(ctx context.Context, projectDir string, args []string) (string, error) {
-	cmd := exec.CommandContext(ctx, "docker", args...)
+// composeOutputTailLines bounds how much stdout is quoted in compose errors.
+const composeOutputTailLines = 20
+
+// runDockerCompose runs docker with args and returns stdout. When output is set,
+// stdout and stderr are also streamed to it while the command runs.
+func runDockerCompose(ctx context.Context, projectDir string, args []string, output io.Writer) (string, error) {
+	return runDockerComposeEnv(ctx, projectDir, args, nil, output)
+}
+
+// composeCommandEnv returns the inherited process environment followed by env, or nil so the command inherits the
+// environment unchanged when env is empty.
+func composeCommandEnv(env []string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), env...)
+}
+
+// runDockerComposeEnv is runDockerCompose with env appended to the inherited process environment.
+func runDockerComposeEnv(ctx context.Context, projectDir string, args []string, env []string, output io.Writer) (string, error) {
+	mode, err := detectComposeMode(ctx)
+	if err != nil {
+		return "", err
+	}
+	binary, commandArgs := composeInvocation(mode, args)
+	cmd := exec.CommandContext(ctx, binary, commandArgs...)
 	cmd.Dir = projectDir
+	cmd.Env = composeCommandEnv(env)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if output != nil {
+		shared := &syncWriter{w: output}
+		cmd.Stdout = io.MultiWriter(&stdout, shared)
+		cmd.Stderr = io.MultiWriter(&stderr, shared)
+	}
 	if err := cmd.Run(); err != nil {
-		message := strings.TrimSpace(stderr.String())
+		return "", formatComposeError(binary, commandArgs, stdout.String(), stderr.String(), err)
+	}
+	return stdout.String(), nil
+}
+
+// syncWriter serializes writes so the stdout and stderr copy goroutines of a command can share one writer.
+type syncWriter struct {
+	mu sync.Mutex // mu guards w.
+	w  io.Writer  // w receives the combined output.
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// composeMode identifies which docker compose implementation is invoked.
+type composeMode int
+
+const (
+	composeModeV2 composeMode = iota // composeModeV2 runs the "docker compose" plugin.
+	composeModeV1                    // composeModeV1 runs the legacy "docker-compose" binary.
+)
+
+// composeModeResult is a cached compose detection outcome.
+type composeModeResult struct {
+	mode composeMode // mode is the detected compose implementation.
+	err  error       // err is set when no implementation is available.
+}
+
+var (
+	composeModeMu    sync.Mutex
+	composeModeCache = map[string]composeModeResult{}
+)
+
+// detectComposeMode probes for docker compose v2 and falls back to docker-compose v1.
+// Results are cached per PATH so the probe runs once for a given environment.
+func detectComposeMode(ctx context.Context) (composeMode, error) {
+	key := os.Getenv("PATH")
+	composeModeMu.Lock()
+	defer composeModeMu.Unlock()
+	if result, ok := composeModeCache[key]; ok {
+		return result.mode, result.err
+	}
+	result := composeModeResult{mode: composeModeV2}
+	if err := exec.CommandContext(ctx, "docker", "compose", "version").Run(); err != nil {
+		if _, lookErr := exec.LookPath("docker-compose"); lookErr == nil {
+			result.mode = composeModeV1
+		} else {
+			result.err = errors.New("docker compose is not available: install the docker compose plugin or docker-compose")
+		}
+	}
+	if ctx.Err() != nil {
+		return result.mode, ctx.Err()
+	}
+	composeModeCache[key] = result
+	return result.mode, result.err
+}
+
+// composeInvocation maps docker compose v2 style args onto the binary for mode.
+func composeInvocation(mode composeMode, args []string) (string, []string) {
+	if mode == composeModeV1 && len(args) > 0 && args[0] == "compose" {
+		return "docker-compose", append([]string{}, args[1:]...)
+	}
+	return "docker", append([]string{}, args...)
+}
+
+func formatComposeError(binary string, args []string, stdout, stderr string, err error) error {
+	command := fmt.Sprintf("%s %s", binary, strings.Join(args, " "))
+	message := strings.TrimSpace(stderr)
+	if message == "" {
+		message = outputTail(stdout, composeOutputTailLines)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
 		if message == "" {
-			message = err.Error()
+			return fmt.Errorf("%s: exit code %d", command, exitErr.ExitCode())
 		}
-		return "", fmt.Errorf("docker %s: %s", strings.Join(args, " "), message)
+		return fmt.Errorf("%s: exit code %d: %s", command, exitErr.ExitCode(), message)
 	}
-	return stdout.String(), nil
+	if message == "" {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return fmt.Errorf("%s: %w: %s", command, err, message)
+}
+
+func outputTail(output string, maxLines int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
 }
 
 func waitForContainerExit(ctx context.Context, containerID string) error {
@@ -453,6 +955,22 @@ func loadComposeEnvironment(workingDir string) (map[string]string, error) {
 	return env, nil
 }
 
+// composeExpansionEnv returns the environment ${containerEnv:...} expands against in the compose service:
+// the service environment, which the compose loader has already merged with its env_file entries,
+// overlaid with the devcontainer containerEnv godev adds through the override.
+func composeExpansionEnv(service *types.ServiceConfig, envMap map[string]string) map[string]string {
+	env := make(map[string]string, len(service.Environment)+len(envMap))
+	for key, value := range service.Environment {
+		if value != nil {
+			env[key] = *value
+		}
+	}
+	for key, value := range envMap {
+		env[key] = value
+	}
+	return env
+}
+
 func envFromOS() map[string]string {
 	env := make(map[string]string)
 	for _, item := range os.Environ() {
@@ -1,12 +1,18 @@
 package godev
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/compose-spec/compose-go/types"
 	"gopkg.in/yaml.v3"
@@ -21,6 +27,8 @@ type composeServiceOverride struct {
 	Labels      map[string]string `yaml:"labels"`
 	User        string            `yaml:"user"`
 	Command     []string          `yaml:"command"`
+	Entrypoint  []string          `yaml:"entrypoint"`
+	Hostname    string            `yaml:"hostname"`
 	WorkingDir  string            `yaml:"working_dir"`
 	Image       string            `yaml:"image"`
 	Volumes     []string          `yaml:"volumes"`
@@ -41,7 +49,7 @@ func TestBuildComposeOverride_PopulatesFields(t *testing.T) {
 	workspaceFolder := "/workspace"
 	service := &types.ServiceConfig{Name: "app"}
 
-	override, err := buildComposeOverride(cfg, envMap, labels, workspaceFolder, service, nil, "")
+	override, err := buildComposeOverride(cfg, defaultStartOptions(), envMap, labels, workspaceFolder, service, nil, "", nil)
 	if err != nil {
 		t.Fatalf("buildComposeOverride: %v", err)
 	}
@@ -75,6 +83,74 @@ func TestBuildComposeOverride_PopulatesFields(t *testing.T) {
 	}
 }
 
+func TestBuildComposeOverride_EscapesDollarForCompose(t *testing.T) {
+	dir := t.TempDir()
+	composeFile := filepath.Join(dir, "compose.yml")
+	if err := os.WriteFile(composeFile, []byte("services:\n  app:\n    image: alpine\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	cfg := &DevcontainerConfig{Service: "app"}
+	envMap := map[string]string{"PRICE": "$5", "TEMPLATE": "${HOME}/x"}
+	override, err := buildComposeOverride(cfg, defaultStartOptions(), envMap, nil, "", &types.ServiceConfig{Name: "app"}, nil, "", nil)
+	if err != nil {
+		t.Fatalf("buildComposeOverride: %v", err)
+	}
+	overrideFile := filepath.Join(dir, "override.yml")
+	if err := os.WriteFile(overrideFile, override, 0o644); err != nil {
+		t.Fatalf("write override: %v", err)
+	}
+
+	project, err := loadComposeProject(context.Background(), []string{composeFile, overrideFile}, dir, "escape")
+	if err != nil {
+		t.Fatalf("loadComposeProject: %v", err)
+	}
+	service, err := findComposeService(project, "app")
+	if err != nil {
+		t.Fatalf("findComposeService: %v", err)
+	}
+	for key, want := range envMap {
+		got := service.Environment[key]
+		if got == nil || *got != want {
+			t.Fatalf("environment %s: expected %q, got %v", key, want, got)
+		}
+	}
+}
+
+func TestBuildComposeOverride_KeepsSecretEnvOutOfFile(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithSecretEnv("TOKEN", "s3cr3t-value"), WithEnv("PLAIN", "visible")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	cfg := &DevcontainerConfig{Service: "app"}
+	override, err := buildComposeOverride(cfg, options, options.Env, nil, "", &types.ServiceConfig{Name: "app"}, nil, "", nil)
+	if err != nil {
+		t.Fatalf("buildComposeOverride: %v", err)
+	}
+	if bytes.Contains(override, []byte("s3cr3t-value")) {
+		t.Fatalf("secret written to override:\n%s", override)
+	}
+	var parsed map[string]map[string]map[string]map[string]*string
+	if err := yaml.Unmarshal(override, &parsed); err != nil {
+		t.Fatalf("unmarshal override: %v", err)
+	}
+	environment := parsed["services"]["app"]["environment"]
+	if value, ok := environment["TOKEN"]; !ok || value != nil {
+		t.Fatalf("expected TOKEN without a value, got %#v", environment)
+	}
+	if value := environment["PLAIN"]; value == nil || *value != "visible" {
+		t.Fatalf("expected PLAIN in override, got %#v", environment)
+	}
+
+	installDockerStub(t, "echo \"TOKEN=$TOKEN\"\n")
+	var streamed bytes.Buffer
+	if err := composeUp(context.Background(), t.TempDir(), "secret", nil, "", nil, composeSecretEnv(options, options.Env), &streamed); err != nil {
+		t.Fatalf("composeUp: %v", err)
+	}
+	if strings.TrimSpace(streamed.String()) != "TOKEN=s3cr3t-value" {
+		t.Fatalf("expected secret in the compose process env, got %q", streamed.String())
+	}
+}
+
 func TestBuildComposeOverride_NoOverrides(t *testing.T) {
 	cfg := &DevcontainerConfig{
 		Service: "app",
@@ -84,7 +160,7 @@ func TestBuildComposeOverride_NoOverrides(t *testing.T) {
 		WorkingDir: "/already-set",
 	}
 
-	override, err := buildComposeOverride(cfg, nil, nil, "/workspace", service, nil, "")
+	override, err := buildComposeOverride(cfg, defaultStartOptions(), nil, nil, "/workspace", service, nil, "", nil)
 	if err != nil {
 		t.Fatalf("buildComposeOverride: %v", err)
 	}
@@ -142,7 +218,7 @@ func TestBuildComposeOverride_Features(t *testing.T) {
 		SecurityOpt: []string{"label:role:ROLE"},
 	}
 
-	override, err := buildComposeOverride(cfg, envMap, labels, workspaceFolder, service, features, "feature-image:latest")
+	override, err := buildComposeOverride(cfg, defaultStartOptions(), envMap, labels, workspaceFolder, service, features, "feature-image:latest", nil)
 	if err != nil {
 		t.Fatalf("buildComposeOverride: %v", err)
 	}
@@ -254,6 +330,42 @@ func TestLoadComposeEnvironment_NoEnvFile(t *testing.T) {
 	}
 }
 
+func TestComposeExpansionEnv_IncludesServiceEnvFile(t *testing.T) {
+	root := t.TempDir()
+	composeDir := filepath.Join(root, ".devcontainer")
+	if err := os.MkdirAll(composeDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "app.env"), []byte("DB_HOST=db.internal\nAPP_MODE=file\n"), 0o644); err != nil {
+		t.Fatalf("write env_file: %v", err)
+	}
+	composeFile := filepath.Join(composeDir, "compose.yml")
+	compose := "services:\n  app:\n    image: alpine:3.19\n    env_file: app.env\n"
+	if err := os.WriteFile(composeFile, []byte(compose), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+
+	project, err := loadComposeProject(context.Background(), []string{composeFile}, root, "demo")
+	if err != nil {
+		t.Fatalf("loadComposeProject: %v", err)
+	}
+	service, err := findComposeService(project, "app")
+	if err != nil {
+		t.Fatalf("findComposeService: %v", err)
+	}
+	env := composeExpansionEnv(service, map[string]string{"APP_MODE": "devcontainer"})
+	lifecycleEnv, err := buildLifecycleEnv(env, map[string]string{"DB_URL": "postgres://${containerEnv:DB_HOST}/app"}, nil)
+	if err != nil {
+		t.Fatalf("buildLifecycleEnv: %v", err)
+	}
+	if lifecycleEnv["DB_URL"] != "postgres://db.internal/app" {
+		t.Fatalf("expected env_file value in expansion, got %q", lifecycleEnv["DB_URL"])
+	}
+	if lifecycleEnv["APP_MODE"] != "devcontainer" {
+		t.Fatalf("expected containerEnv to win over env_file, got %q", lifecycleEnv["APP_MODE"])
+	}
+}
+
 func TestValidateComposeOptions(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -269,6 +381,11 @@ func TestValidateComposeOptions(t *testing.T) {
 			options: startOptions{ExtraPublish: []string{"3000:3000"}},
 			wantErr: true,
 		},
+		{
+			name:    "ephemeral ports",
+			options: startOptions{EphemeralPorts: true},
+			wantErr: true,
+		},
 		{
 			name:    "extra mounts",
 			options: startOptions{ExtraMounts: []Mount{{Source: "/tmp", Target: "/data"}}},
@@ -289,6 +406,16 @@ func TestValidateComposeOptions(t *testing.T) {
 			options: startOptions{Workdir: "/work"},
 			wantErr: true,
 		},
+		{
+			name:    "build context override",
+			options: startOptions{BuildContext: "/src"},
+			wantErr: true,
+		},
+		{
+			name:    "bind source validation",
+			options: startOptions{ValidateBinds: true},
+			wantErr: true,
+		},
 		{
 			name:    "resource limits",
 			options: startOptions{Resources: ResourceLimits{CPUQuota: 1000, Memory: "512m"}},
@@ -311,12 +438,12 @@ func TestValidateComposeOptions(t *testing.T) {
 
 func TestResolveComposeProjectName(t *testing.T) {
 	cfg := &DevcontainerConfig{Name: "My App"}
-	if got := resolveComposeProjectName(cfg, "/workspaces/demo", "deadbeef"); got != "My-App" {
+	if got := resolveComposeProjectName(cfg, "/workspaces/demo", "deadbeef", ""); got != "My-App" {
 		t.Fatalf("unexpected project name: %s", got)
 	}
 
 	cfg = &DevcontainerConfig{}
-	if got := resolveComposeProjectName(cfg, "/workspaces/demo", "deadbeef"); got != "godev-demo-deadbeef" {
+	if got := resolveComposeProjectName(cfg, "/workspaces/demo", "deadbeef", ""); got != "godev-demo-deadbeef" {
 		t.Fatalf("unexpected project name: %s", got)
 	}
 }
@@ -351,3 +478,594 @@ func TestFindComposeService_NotFound(t *testing.T) {
 func boolPtr(value bool) *bool {
 	return &value
 }
+
+func TestResolveComposeProjectName_OverrideFlowsToArgs(t *testing.T) {
+	cfg := &DevcontainerConfig{Name: "My App"}
+	projectName := resolveComposeProjectName(cfg, "/workspaces/demo", "deadbeef", "stack two")
+	if projectName != "stack-two" {
+		t.Fatalf("unexpected project name: %s", projectName)
+	}
+	args := composeBaseArgs("/workspaces/demo", projectName, []string{"/workspaces/demo/compose.yml"}, "")
+	want := []string{"compose", "-f", "/workspaces/demo/compose.yml", "--project-directory", "/workspaces/demo", "-p", "stack-two"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestComposeTargetProjectName(t *testing.T) {
+	root := t.TempDir()
+	cfg := &DevcontainerConfig{Name: "My App"}
+
+	got, err := composeTargetProjectName(map[string]string{"com.docker.compose.project": "stack-two"}, cfg, root, "deadbeef")
+	if err != nil {
+		t.Fatalf("composeTargetProjectName label: %v", err)
+	}
+	if got != "stack-two" {
+		t.Fatalf("expected label project name, got %s", got)
+	}
+
+	t.Setenv("COMPOSE_PROJECT_NAME", "from-env")
+	got, err = composeTargetProjectName(map[string]string{}, cfg, root, "deadbeef")
+	if err != nil {
+		t.Fatalf("composeTargetProjectName env: %v", err)
+	}
+	if got != "from-env" {
+		t.Fatalf("expected env project name, got %s", got)
+	}
+}
+
+func installDockerStub(t *testing.T, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docker")
+	stub := "#!/bin/sh\nif [ \"$1 $2\" = \"compose version\" ]; then exit 0; fi\n" + script
+	if err := os.WriteFile(path, []byte(stub), 0o755); err != nil {
+		t.Fatalf("write docker stub: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRunDockerCompose_FailureIncludesExitCodeAndStdout(t *testing.T) {
+	installDockerStub(t, "echo 'pulling app'\necho 'service app failed to start'\nexit 3\n")
+
+	_, err := runDockerCompose(context.Background(), t.TempDir(), []string{"compose", "up", "-d"}, nil)
+	if err == nil {
+		t.Fatal("expected error from failing compose")
+	}
+	message := err.Error()
+	if !strings.Contains(message, "docker compose up -d") {
+		t.Fatalf("expected command in error: %s", message)
+	}
+	if !strings.Contains(message, "exit code 3") {
+		t.Fatalf("expected exit code in error: %s", message)
+	}
+	if !strings.Contains(message, "service app failed to start") {
+		t.Fatalf("expected stdout tail in error: %s", message)
+	}
+}
+
+func TestRunDockerCompose_FailurePrefersStderr(t *testing.T) {
+	installDockerStub(t, "echo 'progress'\necho 'no such service' >&2\nexit 1\n")
+
+	_, err := runDockerCompose(context.Background(), t.TempDir(), []string{"compose", "ps"}, nil)
+	if err == nil {
+		t.Fatal("expected error from failing compose")
+	}
+	if !strings.Contains(err.Error(), "no such service") || strings.Contains(err.Error(), "progress") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestRunDockerCompose_StreamsOutput(t *testing.T) {
+	installDockerStub(t, "echo 'container started'\necho 'pulling' >&2\n")
+
+	var streamed strings.Builder
+	stdout, err := runDockerCompose(context.Background(), t.TempDir(), []string{"compose", "up", "-d"}, &streamed)
+	if err != nil {
+		t.Fatalf("runDockerCompose: %v", err)
+	}
+	if strings.TrimSpace(stdout) != "container started" {
+		t.Fatalf("unexpected stdout: %q", stdout)
+	}
+	if !strings.Contains(streamed.String(), "container started") || !strings.Contains(streamed.String(), "pulling") {
+		t.Fatalf("unexpected streamed output: %q", streamed.String())
+	}
+}
+
+func TestComposeCommandEnv(t *testing.T) {
+	t.Setenv("GODEV_TEST_INHERITED", "host")
+	if got := composeCommandEnv(nil); got != nil {
+		t.Fatalf("expected the environment to be inherited unchanged, got %d entries", len(got))
+	}
+	env := []string{"GODEV_TEST_SECRET=s3cret", "GODEV_TEST_OTHER=1"}
+	got := composeCommandEnv(env)
+	if want := append(os.Environ(), env...); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the process environment followed by env once, got %d entries, want %d", len(got), len(want))
+	}
+
+	installDockerStub(t, "env | grep '^GODEV_TEST_'\n")
+	stdout, err := runDockerComposeEnv(context.Background(), t.TempDir(), []string{"compose", "config"}, env, nil)
+	if err != nil {
+		t.Fatalf("runDockerComposeEnv: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	sort.Strings(lines)
+	if want := []string{"GODEV_TEST_INHERITED=host", "GODEV_TEST_OTHER=1", "GODEV_TEST_SECRET=s3cret"}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("unexpected compose environment: %#v", lines)
+	}
+}
+
+func TestComposeInvocation(t *testing.T) {
+	args := composeBaseArgs("/project", "godev-project", []string{"/project/compose.yml"}, "")
+	args = append(args, "up", "-d")
+
+	binary, got := composeInvocation(composeModeV2, args)
+	if binary != "docker" || !reflect.DeepEqual(got, args) {
+		t.Fatalf("unexpected v2 invocation: %s %#v", binary, got)
+	}
+
+	binary, got = composeInvocation(composeModeV1, args)
+	want := []string{"-f", "/project/compose.yml", "--project-directory", "/project", "-p", "godev-project", "up", "-d"}
+	if binary != "docker-compose" || !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected v1 invocation: %s %#v", binary, got)
+	}
+}
+
+func TestDetectComposeMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    composeMode
+		wantErr bool
+	}{
+		{
+			name:  "v2 plugin",
+			files: map[string]string{"docker": "exit 0\n"},
+			want:  composeModeV2,
+		},
+		{
+			name: "v1 fallback",
+			files: map[string]string{
+				"docker":         "exit 1\n",
+				"docker-compose": "exit 0\n",
+			},
+			want: composeModeV1,
+		},
+		{
+			name:    "none available",
+			files:   map[string]string{"docker": "exit 1\n"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, script := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+					t.Fatalf("write stub: %v", err)
+				}
+			}
+			t.Setenv("PATH", dir)
+			got, err := detectComposeMode(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected detection error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("detectComposeMode: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("unexpected mode: %d", got)
+			}
+		})
+	}
+}
+
+func TestWriteComposeOverrideFile_PersistentPathReused(t *testing.T) {
+	workspaceRoot := t.TempDir()
+	path := persistentComposeOverridePath(workspaceRoot, "app")
+	if path != filepath.Join(workspaceRoot, ".devcontainer", ".godev2-compose-override-app.yml") {
+		t.Fatalf("unexpected override path: %s", path)
+	}
+	if other := persistentComposeOverridePath(workspaceRoot, "app-ci"); other == path {
+		t.Fatalf("expected a separate override per project, got %s", other)
+	}
+
+	first, err := writeComposeOverrideFile(path, []byte("services: {}\n"))
+	if err != nil {
+		t.Fatalf("writeComposeOverrideFile: %v", err)
+	}
+	if first != path {
+		t.Fatalf("unexpected written path: %s", first)
+	}
+	second, err := writeComposeOverrideFile(path, []byte("services:\n  app: {}\n"))
+	if err != nil {
+		t.Fatalf("writeComposeOverrideFile: %v", err)
+	}
+	if second != first {
+		t.Fatalf("expected override path reuse, got %s and %s", first, second)
+	}
+	content, err := os.ReadFile(second)
+	if err != nil {
+		t.Fatalf("read override: %v", err)
+	}
+	if string(content) != "services:\n  app: {}\n" {
+		t.Fatalf("unexpected override content: %q", content)
+	}
+
+	labels := map[string]string{composeOverrideLabel: path}
+	if got := composeOverrideFromLabels(labels); got != path {
+		t.Fatalf("unexpected override from labels: %q", got)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove override: %v", err)
+	}
+	if got := composeOverrideFromLabels(labels); got != "" {
+		t.Fatalf("expected missing override to be ignored, got %q", got)
+	}
+}
+
+func TestComposePrimaryContainerID_ScaledService(t *testing.T) {
+	installDockerStub(t, `if [ "$1" = "inspect" ]; then
+  shift 3
+  for id in "$@"; do
+    case "$id" in
+      aaa) echo /demo-app-3 ;;
+      bbb) echo /demo-app-1 ;;
+      ccc) echo /demo-app-2 ;;
+    esac
+  done
+  exit 0
+fi
+printf 'aaa\nbbb\nccc\n'
+`)
+	projectDir := t.TempDir()
+	composeFiles := []string{filepath.Join(projectDir, "compose.yml")}
+	ctx := context.Background()
+
+	for range 3 {
+		id, err := composePrimaryContainerID(ctx, projectDir, "demo", composeFiles, "", "app")
+		if err != nil {
+			t.Fatalf("composePrimaryContainerID: %v", err)
+		}
+		if id != "bbb" {
+			t.Fatalf("expected replica 1 (bbb), got %s", id)
+		}
+	}
+	_, err := composeSingleContainerID(ctx, projectDir, "demo", composeFiles, "", "app")
+	if err == nil || !strings.Contains(err.Error(), "service app runs 3 containers") {
+		t.Fatalf("expected scaled service error, got %v", err)
+	}
+}
+
+func TestComposeSingleContainerID_OneReplica(t *testing.T) {
+	installDockerStub(t, "echo abc\n")
+
+	id, err := composeSingleContainerID(context.Background(), t.TempDir(), "demo", nil, "", "app")
+	if err != nil {
+		t.Fatalf("composeSingleContainerID: %v", err)
+	}
+	if id != "abc" {
+		t.Fatalf("unexpected container id: %s", id)
+	}
+}
+
+func TestComposeStopDown_UseSameFilesAsUp(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "calls.log")
+	installDockerStub(t, "echo \"$@\" >> "+logFile+"\ncase \"$*\" in *\" ps -q \"*) echo container-123 ;; esac\n")
+	configDir := filepath.Join(t.TempDir(), ".devcontainer")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	composeFile := filepath.Join(configDir, "compose.yml")
+	if err := os.WriteFile(composeFile, []byte("services:\n  app:\n    image: alpine:3.19\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	configPath := filepath.Join(configDir, "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"dockerComposeFile":"compose.yml","service":"app"}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	// The container carries the labels compose applied from the override written by start, plus compose's own
+	// project label.
+	var labels map[string]string
+	daemon := newFakeDaemon(t)
+	daemon.handle(http.MethodGet, "/containers/container-123/json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"Id": "container-123", "Name": "/app-1", "Config": map[string]any{"Labels": labels}})
+	})
+	daemon.useAsDockerHost()
+
+	ctx := context.Background()
+	if _, err := StartDevcontainer(ctx, WithConfigPath(configPath), WithName("godev-project"), WithPersistentComposeOverride()); err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	overrideFile := filepath.Join(configDir, ".godev2-compose-override-godev-project.yml")
+	content, err := os.ReadFile(overrideFile)
+	if err != nil {
+		t.Fatalf("read override: %v", err)
+	}
+	var override composeOverride
+	if err := yaml.Unmarshal(content, &override); err != nil {
+		t.Fatalf("decode override: %v", err)
+	}
+	labels = override.Services["app"].Labels
+	labels["com.docker.compose.project"] = "godev-project"
+	if err := StopDevcontainer(ctx, "container-123", time.Second); err != nil {
+		t.Fatalf("StopDevcontainer: %v", err)
+	}
+	if err := RemoveDevcontainer(ctx, "container-123"); err != nil {
+		t.Fatalf("RemoveDevcontainer: %v", err)
+	}
+
+	content, err = os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("read calls: %v", err)
+	}
+	files := make(map[string][]string)
+	projects := make(map[string]string)
+	for _, call := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		args := strings.Fields(call)
+		var callFiles []string
+		project := ""
+		for i := 0; i+1 < len(args); i++ {
+			switch args[i] {
+			case "-f":
+				callFiles = append(callFiles, args[i+1])
+			case "-p":
+				project = args[i+1]
+			}
+		}
+		for _, command := range []string{"up", "stop", "down"} {
+			if containsString(args, command) {
+				files[command] = callFiles
+				projects[command] = project
+			}
+		}
+	}
+	want := []string{composeFile, overrideFile}
+	for _, command := range []string{"up", "stop", "down"} {
+		if !reflect.DeepEqual(files[command], want) || projects[command] != "godev-project" {
+			t.Fatalf("expected compose %s to use %v in godev-project, got %v in %q\n%s", command, want, files[command], projects[command], content)
+		}
+	}
+}
+
+func TestComposeDown_RemovesVolumesOnlyWhenRequested(t *testing.T) {
+	tests := []struct {
+		name          string
+		removeVolumes bool
+	}{
+		{name: "keep volumes", removeVolumes: false},
+		{name: "remove volumes", removeVolumes: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "calls.log")
+			installDockerStub(t, "echo \"$@\" >> "+logFile+"\n")
+
+			projectDir := t.TempDir()
+			if err := composeDown(context.Background(), projectDir, "godev-project", []string{filepath.Join(projectDir, "compose.yml")}, "", tt.removeVolumes); err != nil {
+				t.Fatalf("composeDown: %v", err)
+			}
+			content, err := os.ReadFile(logFile)
+			if err != nil {
+				t.Fatalf("read calls: %v", err)
+			}
+			args := strings.Fields(string(content))
+			if got := containsString(args, "--volumes"); got != tt.removeVolumes {
+				t.Fatalf("unexpected --volumes presence %v in %q", got, content)
+			}
+			if !containsString(args, "--remove-orphans") {
+				t.Fatalf("expected --remove-orphans in %q", content)
+			}
+		})
+	}
+}
+
+func TestBuildComposeOverride_Hostname(t *testing.T) {
+	options := defaultStartOptions()
+	WithHostname("devbox")(&options)
+	override, err := buildComposeOverride(&DevcontainerConfig{Service: "app"}, options, nil, nil, "", &types.ServiceConfig{Name: "app"}, nil, "", nil)
+	if err != nil {
+		t.Fatalf("buildComposeOverride: %v", err)
+	}
+	var parsed composeOverride
+	if err := yaml.Unmarshal(override, &parsed); err != nil {
+		t.Fatalf("unmarshal override: %v", err)
+	}
+	if parsed.Services["app"].Hostname != "devbox" {
+		t.Fatalf("unexpected hostname: %#v", parsed.Services["app"])
+	}
+}
+
+func TestBuildComposeOverride_ChainsFeatureEntrypoints(t *testing.T) {
+	features := &ResolvedFeatures{Order: []*ResolvedFeature{
+		{Metadata: FeatureMetadata{ID: "docker-in-docker", Entrypoint: "/usr/local/share/docker-init.sh"}},
+	}}
+	service := &types.ServiceConfig{Name: "app"}
+	command := []string{"node", "server.js"}
+
+	override, err := buildComposeOverride(&DevcontainerConfig{Service: "app"}, defaultStartOptions(), nil, nil, "", service, features, "feature-image:latest", command)
+	if err != nil {
+		t.Fatalf("buildComposeOverride: %v", err)
+	}
+	var parsed composeOverride
+	if err := yaml.Unmarshal(override, &parsed); err != nil {
+		t.Fatalf("unmarshal override: %v", err)
+	}
+	serviceOverride := parsed.Services["app"]
+	if !reflect.DeepEqual(serviceOverride.Entrypoint, []string{featureEntrypointWrapper}) {
+		t.Fatalf("unexpected entrypoint: %#v", serviceOverride.Entrypoint)
+	}
+	if !reflect.DeepEqual(serviceOverride.Command, command) {
+		t.Fatalf("unexpected command: %#v", serviceOverride.Command)
+	}
+}
+
+func TestStartComposeDevcontainer_BringsStackDownWhenLifecycleFails(t *testing.T) {
+	daemon := newFakeDaemon(t)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","Name":"/app-1"}`)
+	daemon.handle(http.MethodPost, "/containers/container-123/exec", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message":"exec refused"}`))
+	})
+	daemon.useAsDockerHost()
+
+	for _, keep := range []bool{false, true} {
+		logFile := filepath.Join(t.TempDir(), "compose.log")
+		installDockerStub(t, "echo \"$@\" >> "+logFile+"\ncase \"$*\" in *\" ps -q \"*) echo container-123 ;; esac\n")
+		projectDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(projectDir, "compose.yml"), []byte("services:\n  app:\n    image: alpine:3.19\n"), 0o644); err != nil {
+			t.Fatalf("write compose file: %v", err)
+		}
+		configPath := filepath.Join(projectDir, "devcontainer.json")
+		config := `{"dockerComposeFile":"compose.yml","service":"app","postCreateCommand":"echo hi"}`
+		if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		opts := []StartOption{WithConfigPath(configPath), WithName("godev-project")}
+		if keep {
+			opts = append(opts, WithKeepOnFailure())
+		}
+
+		result, err := StartDevcontainerResult(context.Background(), opts...)
+		if err == nil || !strings.Contains(err.Error(), "exec refused") {
+			t.Fatalf("keep=%v: expected lifecycle error, got %v", keep, err)
+		}
+		data, err := os.ReadFile(logFile)
+		if err != nil {
+			t.Fatalf("read log: %v", err)
+		}
+		wentDown := strings.Contains(string(data), " down --remove-orphans")
+		if wentDown == keep {
+			t.Fatalf("keep=%v: unexpected compose calls:\n%s", keep, data)
+		}
+		if strings.Contains(string(data), "--volumes") {
+			t.Fatalf("keep=%v: expected volumes to be kept:\n%s", keep, data)
+		}
+		if keep != (result != nil && result.ContainerID == "container-123") {
+			t.Fatalf("keep=%v: unexpected result %#v", keep, result)
+		}
+	}
+
+	logFile := filepath.Join(t.TempDir(), "compose.log")
+	installDockerStub(t, "echo \"$@\" >> "+logFile+"\ncase \"$*\" in *\" ps -q \"*|*\" ps -a -q\") echo container-123 ;; esac\n")
+	projectDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectDir, "compose.yml"), []byte("services:\n  app:\n    image: alpine:3.19\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	configPath := filepath.Join(projectDir, "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"dockerComposeFile":"compose.yml","service":"app","postStartCommand":"echo hi"}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	result, err := StartDevcontainerResult(context.Background(), WithConfigPath(configPath), WithName("godev-project"))
+	if err == nil || !strings.Contains(err.Error(), "exec refused") {
+		t.Fatalf("existing stack: expected lifecycle error, got %v", err)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if strings.Contains(string(data), " down ") {
+		t.Fatalf("existing stack: expected a reused stack to stay up:\n%s", data)
+	}
+	if result == nil || result.ContainerID != "container-123" {
+		t.Fatalf("existing stack: unexpected result %#v", result)
+	}
+}
+
+func TestStartComposeDevcontainer_KeepComposeOverride(t *testing.T) {
+	daemon := newFakeDaemon(t)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","Name":"/app-1"}`)
+	daemon.useAsDockerHost()
+	installDockerStub(t, "case \"$*\" in *\" ps -q \"*) echo container-123 ;; esac\n")
+
+	for _, keep := range []bool{false, true} {
+		tempDir := t.TempDir()
+		t.Setenv("TMPDIR", tempDir)
+		projectDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(projectDir, "compose.yml"), []byte("services:\n  app:\n    image: alpine:3.19\n"), 0o644); err != nil {
+			t.Fatalf("write compose file: %v", err)
+		}
+		configPath := filepath.Join(projectDir, "devcontainer.json")
+		if err := os.WriteFile(configPath, []byte(`{"dockerComposeFile":"compose.yml","service":"app"}`), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		var logs bytes.Buffer
+		opts := []StartOption{WithConfigPath(configPath), WithName("godev-project"), WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))}
+		if keep {
+			opts = append(opts, WithKeepComposeOverride())
+		}
+
+		result, err := StartDevcontainerResult(context.Background(), opts...)
+		if err != nil {
+			t.Fatalf("keep=%v: StartDevcontainerResult: %v", keep, err)
+		}
+		matches, err := filepath.Glob(filepath.Join(tempDir, "godev-compose-override-*.yml"))
+		if err != nil {
+			t.Fatalf("glob: %v", err)
+		}
+		if !keep {
+			if len(matches) != 0 || result.ComposeOverride != "" {
+				t.Fatalf("expected the override to be removed, got %v and %q", matches, result.ComposeOverride)
+			}
+			continue
+		}
+		if len(matches) != 1 || result.ComposeOverride != matches[0] {
+			t.Fatalf("expected the kept override to be reported, got %v and %q", matches, result.ComposeOverride)
+		}
+		content, err := os.ReadFile(matches[0])
+		if err != nil || !strings.Contains(string(content), "services:") {
+			t.Fatalf("expected override YAML, got %q (%v)", content, err)
+		}
+		if !strings.Contains(logs.String(), "kept compose override") || !strings.Contains(logs.String(), matches[0]) {
+			t.Fatalf("expected the override path to be logged:\n%s", logs.String())
+		}
+	}
+}
+
+func TestStartComposeDevcontainer_HookServicesReportLifecycleEvents(t *testing.T) {
+	installDockerStub(t, "case \"$*\" in\n*\" ps -q db\"*) echo db-456 ;;\n*\" ps -q \"*) echo container-123 ;;\nesac\n")
+	daemon := newFakeDaemon(t)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","Name":"/app-1"}`)
+	daemon.respond(http.MethodPost, "/containers/container-123/exec", http.StatusCreated, `{"Id":"exec-app"}`)
+	daemon.handle(http.MethodPost, "/exec/exec-app/start", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+		_ = conn.Close()
+	})
+	daemon.respond(http.MethodGet, "/exec/exec-app/json", http.StatusOK, `{"ExitCode":0}`)
+	daemon.handle(http.MethodPost, "/containers/db-456/exec", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message":"db exec refused"}`))
+	})
+	daemon.useAsDockerHost()
+
+	projectDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectDir, "compose.yml"), []byte("services:\n  app:\n    image: alpine:3.19\n  db:\n    image: postgres:16\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	configPath := filepath.Join(projectDir, "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"dockerComposeFile":"compose.yml","service":"app","postStartCommand":"echo hi"}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	var events []string
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithName("godev-project"),
+		WithHookServices("postStartCommand", "db"),
+		WithOnLifecycleEvent(func(stage, status string) { events = append(events, stage+":"+status) }))
+	if err == nil || !strings.Contains(err.Error(), "db exec refused") {
+		t.Fatalf("expected the db hook to fail, got %v", err)
+	}
+	want := []string{"postStartCommand:start", "postStartCommand:success", "postStartCommand:start", "postStartCommand:failure"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("unexpected lifecycle events: %#v", events)
+	}
+}
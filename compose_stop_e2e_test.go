@@ -32,7 +32,7 @@ func TestDockerCompose_StopRemove_Dispatcher(t *testing.T) {
 	if err != nil {
 		t.Fatalf("resolveComposeFiles: %v", err)
 	}
-	projectName := resolveComposeProjectName(cfg, workspaceRoot, vars["devcontainerId"])
+	projectName := resolveComposeProjectName(cfg, workspaceRoot, vars["devcontainerId"], "")
 
 	inspectCtx, cancelInspect := context.WithTimeout(context.Background(), 10*time.Second)
 	if _, err := cli.ImageInspect(inspectCtx, baseImage); err != nil {
@@ -43,7 +43,7 @@ func TestDockerCompose_StopRemove_Dispatcher(t *testing.T) {
 	t.Cleanup(func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 		defer cancel()
-		_ = composeDown(ctx, workspaceRoot, projectName, composeFiles)
+		_ = composeDown(ctx, workspaceRoot, projectName, composeFiles, "", true)
 		cleanupContainer(t, cli, containerID)
 		if removeBaseImage {
 			cleanupImage(t, cli, baseImage)
@@ -87,7 +87,7 @@ func TestDockerCompose_StopRemove_Dispatcher(t *testing.T) {
 		t.Fatalf("db container still running")
 	}
 
-	if err := RemoveDevcontainer(context.Background(), containerID); err != nil {
+	if err := RemoveDevcontainer(context.Background(), containerID, WithRemoveVolumes()); err != nil {
 		t.Fatalf("RemoveDevcontainer: %v", err)
 	}
 	containerID = ""
@@ -95,7 +95,7 @@ func TestDockerCompose_StopRemove_Dispatcher(t *testing.T) {
 	defer cancelCheck()
 	args := composeBaseArgs(workspaceRoot, projectName, composeFiles, "")
 	args = append(args, "ps", "-q")
-	output, err := runDockerCompose(checkCtx, workspaceRoot, args)
+	output, err := runDockerCompose(checkCtx, workspaceRoot, args, nil)
 	if err != nil {
 		t.Fatalf("compose ps: %v", err)
 	}
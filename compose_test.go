@@ -1,8 +1,10 @@
 package godev
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -164,3 +166,96 @@ func TestValidateConfig_ComposeRejectsBuild(t *testing.T) {
 		t.Fatalf("expected error for build with compose")
 	}
 }
+
+func TestDiscoverComposeFiles_IncludesOverride(t *testing.T) {
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "compose", "discovery")
+	configDir := filepath.Join(root, ".devcontainer")
+	configPath := filepath.Join(configDir, "devcontainer.json")
+	base := filepath.Join(configDir, "docker-compose.yml")
+	override := filepath.Join(configDir, "docker-compose.override.yml")
+
+	cfg := &DevcontainerConfig{
+		DockerComposeFile: StringSlice{"docker-compose.yml"},
+		Service:           "app",
+	}
+	files, err := resolveComposeFiles(configPath, cfg)
+	if err != nil {
+		t.Fatalf("resolveComposeFiles: %v", err)
+	}
+	if len(files) != 1 || files[0] != base {
+		t.Fatalf("expected override to be excluded without discovery: %#v", files)
+	}
+
+	files, err = discoverComposeFiles(configPath, root, cfg, nil)
+	if err != nil {
+		t.Fatalf("discoverComposeFiles: %v", err)
+	}
+	if len(files) != 2 || files[0] != base || files[1] != override {
+		t.Fatalf("unexpected compose files: %#v", files)
+	}
+
+	cfg.DockerComposeFile = StringSlice{"docker-compose.yml", "docker-compose.override.yml"}
+	files, err = discoverComposeFiles(configPath, root, cfg, nil)
+	if err != nil {
+		t.Fatalf("discoverComposeFiles listed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected override not to be duplicated: %#v", files)
+	}
+}
+
+func TestDiscoverComposeFiles_ComposeFileEnv(t *testing.T) {
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "compose", "discovery")
+	configDir := filepath.Join(root, ".devcontainer")
+	configPath := filepath.Join(configDir, "devcontainer.json")
+
+	cfg := &DevcontainerConfig{Service: "app"}
+	if err := validateConfig(cfg); !errors.Is(err, errComposeFileRequired) {
+		t.Fatalf("expected errComposeFileRequired, got %v", err)
+	}
+	if _, err := discoverComposeFiles(configPath, root, cfg, map[string]string{}); err == nil {
+		t.Fatalf("expected error without COMPOSE_FILE")
+	}
+
+	env := map[string]string{
+		"COMPOSE_FILE":           ".devcontainer/docker-compose.yml;.devcontainer/docker-compose.override.yml",
+		"COMPOSE_PATH_SEPARATOR": ";",
+	}
+	files, err := discoverComposeFiles(configPath, root, cfg, env)
+	if err != nil {
+		t.Fatalf("discoverComposeFiles: %v", err)
+	}
+	expected := []string{
+		filepath.Join(configDir, "docker-compose.yml"),
+		filepath.Join(configDir, "docker-compose.override.yml"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("unexpected compose files: %#v", files)
+	}
+}
+
+func TestComposeFilesFromLabels(t *testing.T) {
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "compose", "discovery")
+	base := filepath.Join(root, ".devcontainer", "docker-compose.yml")
+	override := filepath.Join(root, ".devcontainer", "docker-compose.override.yml")
+
+	files, err := composeFilesFromLabels(map[string]string{
+		composeFilesLabel: base + string(os.PathListSeparator) + override,
+	})
+	if err != nil {
+		t.Fatalf("composeFilesFromLabels: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{base, override}) {
+		t.Fatalf("unexpected compose files: %#v", files)
+	}
+	files, err = composeFilesFromLabels(map[string]string{})
+	if err != nil || files != nil {
+		t.Fatalf("expected no files, got %#v (%v)", files, err)
+	}
+	if _, err := composeFilesFromLabels(map[string]string{composeFilesLabel: filepath.Join(root, "missing.yml")}); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
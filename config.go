@@ -10,46 +10,47 @@ import (
 
 // DevcontainerConfig represents the decoded devcontainer.json configuration.
 type DevcontainerConfig struct {
-	Name                        string             `json:"name"`                        // Name is an optional container name override.
-	Image                       string             `json:"image"`                       // Image is the base image reference when not building.
-	Build                       *DevcontainerBuild `json:"build"`                       // Build describes Docker build settings for the devcontainer.
-	DockerComposeFile           StringSlice        `json:"dockerComposeFile"`           // DockerComposeFile lists compose files for Docker Compose mode.
-	Service                     string             `json:"service"`                     // Service selects the primary compose service.
-	RunServices                 []string           `json:"runServices"`                 // RunServices lists additional compose services to start.
-	ShutdownAction              string             `json:"shutdownAction"`              // ShutdownAction controls container shutdown behavior.
-	ForwardPorts                PortList           `json:"forwardPorts"`                // ForwardPorts lists ports to forward from the container.
-	AppPort                     PortList           `json:"appPort"`                     // AppPort lists application ports for devcontainer tooling.
-	ContainerEnv                map[string]string  `json:"containerEnv"`                // ContainerEnv defines environment variables set in the container.
-	Mounts                      []MountSpec        `json:"mounts"`                      // Mounts defines additional mounts for the container.
-	WorkspaceMount              string             `json:"workspaceMount"`              // WorkspaceMount overrides the workspace mount spec.
-	WorkspaceFolder             string             `json:"workspaceFolder"`             // WorkspaceFolder sets the workspace path inside the container.
-	RunArgs                     []string           `json:"runArgs"`                     // RunArgs lists extra docker run arguments.
-	Privileged                  bool               `json:"privileged"`                  // Privileged requests privileged container mode.
-	CapAdd                      []string           `json:"capAdd"`                      // CapAdd adds Linux capabilities.
-	SecurityOpt                 []string           `json:"securityOpt"`                 // SecurityOpt supplies security options to Docker.
-	Init                        *bool              `json:"init"`                        // Init controls Docker init usage.
-	ContainerUser               string             `json:"containerUser"`               // ContainerUser sets the user for the container process.
-	RemoteUser                  string             `json:"remoteUser"`                  // RemoteUser sets the default user for lifecycle commands.
-	RemoteEnv                   map[string]string  `json:"remoteEnv"`                   // RemoteEnv defines environment variables for remote commands.
-	Features                    FeatureSet         `json:"features"`                    // Features declares requested devcontainer features.
-	OverrideFeatureInstallOrder []string           `json:"overrideFeatureInstallOrder"` // OverrideFeatureInstallOrder forces feature install order.
-	OverrideCommand             *bool              `json:"overrideCommand"`             // OverrideCommand controls entrypoint override behavior.
-	InitializeCommand           *LifecycleCommands `json:"initializeCommand"`           // InitializeCommand runs on the host before container create.
-	OnCreateCommand             *LifecycleCommands `json:"onCreateCommand"`             // OnCreateCommand runs after the container is created.
-	UpdateContentCommand        *LifecycleCommands `json:"updateContentCommand"`        // UpdateContentCommand runs after content updates.
-	PostCreateCommand           *LifecycleCommands `json:"postCreateCommand"`           // PostCreateCommand runs after creation tasks.
-	PostStartCommand            *LifecycleCommands `json:"postStartCommand"`            // PostStartCommand runs after the container starts.
-	PostAttachCommand           *LifecycleCommands `json:"postAttachCommand"`           // PostAttachCommand runs after attaching to the container.
+	Name                        string             `json:"name,omitempty"`                        // Name is an optional container name override.
+	Image                       string             `json:"image,omitempty"`                       // Image is the base image reference when not building.
+	Build                       *DevcontainerBuild `json:"build,omitempty"`                       // Build describes Docker build settings for the devcontainer.
+	DockerComposeFile           StringSlice        `json:"dockerComposeFile,omitempty"`           // DockerComposeFile lists compose files for Docker Compose mode.
+	Service                     string             `json:"service,omitempty"`                     // Service selects the primary compose service.
+	RunServices                 []string           `json:"runServices,omitempty"`                 // RunServices lists additional compose services to start.
+	ShutdownAction              string             `json:"shutdownAction,omitempty"`              // ShutdownAction controls container shutdown behavior.
+	ForwardPorts                PortList           `json:"forwardPorts,omitempty"`                // ForwardPorts lists ports to forward from the container.
+	AppPort                     PortList           `json:"appPort,omitempty"`                     // AppPort lists application ports for devcontainer tooling.
+	ContainerEnv                map[string]string  `json:"containerEnv,omitempty"`                // ContainerEnv defines environment variables set in the container.
+	Mounts                      []MountSpec        `json:"mounts,omitempty"`                      // Mounts defines additional mounts for the container.
+	WorkspaceMount              string             `json:"workspaceMount,omitempty"`              // WorkspaceMount overrides the workspace mount spec.
+	WorkspaceFolder             string             `json:"workspaceFolder,omitempty"`             // WorkspaceFolder sets the workspace path inside the container.
+	RunArgs                     []string           `json:"runArgs,omitempty"`                     // RunArgs lists extra docker run arguments.
+	Privileged                  bool               `json:"privileged,omitempty"`                  // Privileged requests privileged container mode.
+	CapAdd                      []string           `json:"capAdd,omitempty"`                      // CapAdd adds Linux capabilities.
+	CapDrop                     []string           `json:"capDrop,omitempty"`                     // CapDrop drops Linux capabilities.
+	SecurityOpt                 []string           `json:"securityOpt,omitempty"`                 // SecurityOpt supplies security options to Docker.
+	Init                        *bool              `json:"init,omitempty"`                        // Init controls Docker init usage.
+	ContainerUser               string             `json:"containerUser,omitempty"`               // ContainerUser sets the user for the container process.
+	RemoteUser                  string             `json:"remoteUser,omitempty"`                  // RemoteUser sets the default user for lifecycle commands.
+	RemoteEnv                   map[string]string  `json:"remoteEnv,omitempty"`                   // RemoteEnv defines environment variables for remote commands.
+	Features                    FeatureSet         `json:"features,omitempty"`                    // Features declares requested devcontainer features.
+	OverrideFeatureInstallOrder []string           `json:"overrideFeatureInstallOrder,omitempty"` // OverrideFeatureInstallOrder forces feature install order.
+	OverrideCommand             *bool              `json:"overrideCommand,omitempty"`             // OverrideCommand controls entrypoint override behavior.
+	InitializeCommand           *LifecycleCommands `json:"initializeCommand,omitempty"`           // InitializeCommand runs on the host before container create, with localWorkspaceFolder and the other workspace variables set in its environment.
+	OnCreateCommand             *LifecycleCommands `json:"onCreateCommand,omitempty"`             // OnCreateCommand runs after the container is created.
+	UpdateContentCommand        *LifecycleCommands `json:"updateContentCommand,omitempty"`        // UpdateContentCommand runs after content updates.
+	PostCreateCommand           *LifecycleCommands `json:"postCreateCommand,omitempty"`           // PostCreateCommand runs after creation tasks.
+	PostStartCommand            *LifecycleCommands `json:"postStartCommand,omitempty"`            // PostStartCommand runs after the container starts.
+	PostAttachCommand           *LifecycleCommands `json:"postAttachCommand,omitempty"`           // PostAttachCommand runs after attaching to the container.
 }
 
 // DevcontainerBuild describes Docker build settings from devcontainer.json.
 type DevcontainerBuild struct {
-	Dockerfile string            `json:"dockerfile"` // Dockerfile is the path to the Dockerfile.
-	Context    string            `json:"context"`    // Context is the build context directory.
-	Args       map[string]string `json:"args"`       // Args holds Docker build arguments.
-	Target     string            `json:"target"`     // Target selects a specific build stage.
-	CacheFrom  StringSlice       `json:"cacheFrom"`  // CacheFrom lists cache sources.
-	Options    []string          `json:"options"`    // Options carries additional build options.
+	Dockerfile string            `json:"dockerfile,omitempty"` // Dockerfile is the path to the Dockerfile.
+	Context    string            `json:"context,omitempty"`    // Context is the build context directory.
+	Args       map[string]string `json:"args,omitempty"`       // Args holds Docker build arguments.
+	Target     string            `json:"target,omitempty"`     // Target selects a specific build stage.
+	CacheFrom  StringSlice       `json:"cacheFrom,omitempty"`  // CacheFrom lists cache sources.
+	Options    []string          `json:"options,omitempty"`    // Options carries additional build options.
 }
 
 type StringSlice []string
@@ -183,8 +184,30 @@ func (m *MountSpec) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON encodes MountSpec in the same string or object form accepted by UnmarshalJSON.
+// Impact: String-form mounts are written back as strings, and object-form mounts omit empty fields.
+// Example:
+//
+//	data, err := json.Marshal(devcontainer.MountSpec{Type: "volume", Source: "data", Target: "/data"})
+//
+// Similar: UnmarshalJSON performs the reverse conversion from devcontainer.json.
+func (m MountSpec) MarshalJSON() ([]byte, error) {
+	if m.Raw != "" {
+		return json.Marshal(m.Raw)
+	}
+	return json.Marshal(struct {
+		Type   string `json:"type"`
+		Source string `json:"source,omitempty"`
+		Target string `json:"target"`
+	}{
+		Type:   m.Type,
+		Source: m.Source,
+		Target: m.Target,
+	})
+}
+
 // LoadConfig reads devcontainer.json, strips comments, and decodes it into DevcontainerConfig.
-// Impact: It performs file I/O and returns errors for invalid JSON or spec violations.
+// Impact: It performs file I/O and returns errors for invalid JSON or spec violations; a missing file matches ErrConfigNotFound.
 // Example:
 //
 //	cfg, err := devcontainer.LoadConfig("./.devcontainer/devcontainer.json")
@@ -192,6 +215,9 @@ func (m *MountSpec) UnmarshalJSON(data []byte) error {
 // Similar: FindConfigPath only searches for the file path without decoding it.
 func LoadConfig(path string) (*DevcontainerConfig, error) {
 	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, classifyError(ErrConfigNotFound, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -206,8 +232,33 @@ func LoadConfig(path string) (*DevcontainerConfig, error) {
 	return &cfg, nil
 }
 
+// localConfigFileName is the gitignored overlay merged onto the config in the same directory.
+const localConfigFileName = "devcontainer.local.json"
+
+// loadConfigWithLocal loads path and, unless skipLocal is set, merges devcontainer.local.json
+// from the same directory on top when it exists.
+func loadConfigWithLocal(path string, skipLocal bool) (*DevcontainerConfig, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil || skipLocal {
+		return cfg, err
+	}
+	localPath := filepath.Join(filepath.Dir(path), localConfigFileName)
+	if filepath.Clean(localPath) == filepath.Clean(path) {
+		return cfg, nil
+	}
+	local, err := LoadConfig(localPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", localPath, err)
+	}
+	return MergeConfig(cfg, local), nil
+}
+
 // FindConfigPath searches baseDir for devcontainer.json and returns the first match.
-// Impact: It checks filesystem paths and returns an error when no config is found.
+// Impact: It checks .devcontainer/devcontainer.json, devcontainer.json, then the .devcontainer.json dotfile, and returns
+// an error matching ErrConfigNotFound when no config is found.
 // Example:
 //
 //	path, err := devcontainer.FindConfigPath(".")
@@ -217,11 +268,12 @@ func FindConfigPath(baseDir string) (string, error) {
 	candidates := []string{
 		filepath.Join(baseDir, ".devcontainer", "devcontainer.json"),
 		filepath.Join(baseDir, "devcontainer.json"),
+		filepath.Join(baseDir, ".devcontainer.json"),
 	}
 	for _, candidate := range candidates {
 		if stat, err := os.Stat(candidate); err == nil && !stat.IsDir() {
 			return candidate, nil
 		}
 	}
-	return "", fmt.Errorf("devcontainer.json not found in %s", baseDir)
+	return "", classifyError(ErrConfigNotFound, fmt.Errorf("devcontainer.json not found in %s", baseDir))
 }
@@ -39,6 +39,7 @@ func MergeConfig(base, overlay *DevcontainerConfig) *DevcontainerConfig {
 	merged.RunArgs = append(merged.RunArgs, overlay.RunArgs...)
 	merged.Privileged = merged.Privileged || overlay.Privileged
 	merged.CapAdd = append(merged.CapAdd, overlay.CapAdd...)
+	merged.CapDrop = append(merged.CapDrop, overlay.CapDrop...)
 	merged.SecurityOpt = append(merged.SecurityOpt, overlay.SecurityOpt...)
 	merged.Init = mergeInit(merged.Init, overlay.Init)
 	if overlay.ContainerUser != "" {
@@ -89,6 +90,7 @@ func cloneConfig(cfg *DevcontainerConfig) *DevcontainerConfig {
 	out.Mounts = cloneMounts(cfg.Mounts)
 	out.RunArgs = cloneStrings(cfg.RunArgs)
 	out.CapAdd = cloneStrings(cfg.CapAdd)
+	out.CapDrop = cloneStrings(cfg.CapDrop)
 	out.SecurityOpt = cloneStrings(cfg.SecurityOpt)
 	out.Features = cloneFeatureSet(cfg.Features)
 	out.OverrideFeatureInstallOrder = cloneStrings(cfg.OverrideFeatureInstallOrder)
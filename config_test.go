@@ -1,8 +1,10 @@
 package godev
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -37,6 +39,40 @@ func TestFindConfigPath(t *testing.T) {
 	}
 }
 
+func TestFindConfigPath_Dotfile(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "app")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	dotfile := filepath.Join(root, ".devcontainer.json")
+	writeTestcaseFile(t, dotfile, "config", "basic", "devcontainer.json")
+
+	got, err := FindConfigPath(root)
+	if err != nil {
+		t.Fatalf("FindConfigPath: %v", err)
+	}
+	if got != dotfile {
+		t.Fatalf("expected %s, got %s", dotfile, got)
+	}
+	cfg, err := LoadConfig(got)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	workspaceRoot, workspaceFolder, _, _, err := resolveWorkspacePaths(got, cfg)
+	if err != nil {
+		t.Fatalf("resolveWorkspacePaths: %v", err)
+	}
+	if workspaceRoot != root || workspaceFolder != "/workspaces/app" {
+		t.Fatalf("expected the dotfile directory as workspace, got %s and %s", workspaceRoot, workspaceFolder)
+	}
+
+	plain := filepath.Join(root, "devcontainer.json")
+	writeTestcaseFile(t, plain, "config", "basic", "devcontainer.json")
+	if got, err := FindConfigPath(root); err != nil || got != plain {
+		t.Fatalf("expected devcontainer.json to take precedence, got %s (%v)", got, err)
+	}
+}
+
 func TestLoadConfig_ParsesPortsAndMounts(t *testing.T) {
 	root := t.TempDir()
 	configDir := filepath.Join(root, ".devcontainer")
@@ -157,3 +193,74 @@ func TestLoadConfig_RejectsInvalidFeatureOption(t *testing.T) {
 		t.Fatalf("expected error for invalid feature option")
 	}
 }
+
+func TestEffectiveConfig_RoundTripsJSON(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "devcontainer.json")
+	content := `{
+  "name": "effective",
+  "image": "alpine:3.19",
+  "mounts": ["type=bind,source=/tmp,target=/tmp", {"type": "volume", "source": "data", "target": "/data"}],
+  "features": {"./feature": {"enabled": true, "version": "1.0"}},
+  "postCreateCommand": {"one": "echo one", "two": ["echo", "two"]}
+}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	overlay := &DevcontainerConfig{RemoteUser: "vscode"}
+
+	cfg, err := EffectiveConfig(WithConfigPath(configPath), WithMergeConfig(overlay))
+	if err != nil {
+		t.Fatalf("EffectiveConfig: %v", err)
+	}
+	if cfg.Name != "effective" || cfg.RemoteUser != "vscode" {
+		t.Fatalf("unexpected effective config: %#v", cfg)
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded DevcontainerConfig
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v: %s", err, data)
+	}
+	if !reflect.DeepEqual(&decoded, cfg) {
+		t.Fatalf("round trip mismatch: %#v vs %#v", decoded, cfg)
+	}
+
+	if _, err := EffectiveConfig(WithConfig(&DevcontainerConfig{Name: "invalid"})); err == nil {
+		t.Fatalf("expected validation error")
+	}
+}
+
+func TestEffectiveConfig_MergesLocalConfig(t *testing.T) {
+	root := t.TempDir()
+	configDir := filepath.Join(root, ".devcontainer")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(configDir, "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "local-overlay", "devcontainer.json")
+	writeTestcaseFile(t, filepath.Join(configDir, localConfigFileName), "config", "local-overlay", "devcontainer.local.json")
+
+	cfg, err := EffectiveConfig(WithConfigPath(configPath))
+	if err != nil {
+		t.Fatalf("EffectiveConfig: %v", err)
+	}
+	expectedEnv := map[string]string{"SHARED": "local", "BASE_ONLY": "1", "LOCAL_ONLY": "1"}
+	if !reflect.DeepEqual(cfg.ContainerEnv, expectedEnv) {
+		t.Fatalf("unexpected containerEnv: %#v", cfg.ContainerEnv)
+	}
+	if len(cfg.Mounts) != 2 || cfg.Mounts[1].Raw != "type=bind,source=/tmp,target=/scratch" {
+		t.Fatalf("expected local mount appended: %#v", cfg.Mounts)
+	}
+
+	cfg, err = EffectiveConfig(WithConfigPath(configPath), WithoutLocalConfig())
+	if err != nil {
+		t.Fatalf("EffectiveConfig without local: %v", err)
+	}
+	if cfg.ContainerEnv["SHARED"] != "base" || len(cfg.Mounts) != 1 {
+		t.Fatalf("expected local config to be skipped: %#v", cfg)
+	}
+}
@@ -253,3 +253,134 @@ func TestDockerEngine_LifecycleCommands(t *testing.T) {
 		}
 	}
 }
+
+func TestDockerEngine_AutoForwardPortsDetectsListener(t *testing.T) {
+	cli := requireDocker(t)
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "docker-engine-listener")
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+
+	inspectCtx, cancelInspect := context.WithTimeout(context.Background(), 10*time.Second)
+	removeBaseImage := false
+	if _, err := cli.ImageInspect(inspectCtx, "alpine:3.19"); err != nil {
+		removeBaseImage = true
+	}
+	cancelInspect()
+	if removeBaseImage {
+		t.Cleanup(func() {
+			cleanupImage(t, cli, "alpine:3.19")
+		})
+	}
+
+	startCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	result, err := StartDevcontainerResult(startCtx, WithConfigPath(configPath), WithAutoForwardPorts())
+	if result != nil {
+		t.Cleanup(func() {
+			cleanupContainer(t, cli, result.ContainerID)
+		})
+	}
+	if err != nil {
+		t.Fatalf("StartDevcontainerResult: %v", err)
+	}
+	if len(result.DetectedPorts) != 1 || result.DetectedPorts[0] != 7070 {
+		t.Fatalf("expected only the unpublished listener to be detected, got %v", result.DetectedPorts)
+	}
+}
+
+func TestDockerEngine_BuildImageWithContextOverride(t *testing.T) {
+	cli := requireDocker(t)
+	root := testcasePath(t, "docker-engine-build-context")
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+
+	inspectCtx, cancelInspect := context.WithTimeout(context.Background(), 10*time.Second)
+	removeBaseImage := false
+	if _, err := cli.ImageInspect(inspectCtx, "alpine:3.19"); err != nil {
+		removeBaseImage = true
+	}
+	cancelInspect()
+	if removeBaseImage {
+		t.Cleanup(func() {
+			cleanupImage(t, cli, "alpine:3.19")
+		})
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	workspaceRoot, _, _, vars, err := resolveWorkspacePaths(configPath, cfg)
+	if err != nil {
+		t.Fatalf("resolveWorkspacePaths: %v", err)
+	}
+	expectedTag := imageTagForBuild(workspaceRoot, vars["devcontainerId"])
+	t.Cleanup(func() {
+		cleanupImage(t, cli, expectedTag)
+	})
+
+	buildCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if _, err := BuildImageFromDevcontainer(buildCtx, configPath); err == nil {
+		t.Fatalf("expected error without context override")
+	}
+	imageRef, err := BuildImageFromDevcontainer(buildCtx, configPath, WithBuildContext(root))
+	if err != nil {
+		t.Fatalf("BuildImageFromDevcontainer: %v", err)
+	}
+	if imageRef != expectedTag {
+		t.Fatalf("unexpected image tag: %s", imageRef)
+	}
+	if _, err := cli.ImageInspect(context.Background(), imageRef); err != nil {
+		t.Fatalf("ImageInspect: %v", err)
+	}
+}
+
+func TestDockerEngine_WaitForHealthy(t *testing.T) {
+	cli := requireDocker(t)
+	configPath := testcasePath(t, "docker-engine-healthcheck", ".devcontainer", "devcontainer.json")
+
+	inspectCtx, cancelInspect := context.WithTimeout(context.Background(), 10*time.Second)
+	removeBaseImage := false
+	if _, err := cli.ImageInspect(inspectCtx, "alpine:3.19"); err != nil {
+		removeBaseImage = true
+	}
+	cancelInspect()
+	if removeBaseImage {
+		t.Cleanup(func() {
+			cleanupImage(t, cli, "alpine:3.19")
+		})
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	workspaceRoot, _, _, vars, err := resolveWorkspacePaths(configPath, cfg)
+	if err != nil {
+		t.Fatalf("resolveWorkspacePaths: %v", err)
+	}
+	expectedTag := imageTagForBuild(workspaceRoot, vars["devcontainerId"])
+	t.Cleanup(func() {
+		cleanupImage(t, cli, expectedTag)
+	})
+
+	startCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	containerID, err := StartDevcontainer(startCtx, WithConfigPath(configPath), WithWaitForHealthy(30*time.Second))
+	if containerID != "" {
+		t.Cleanup(func() {
+			cleanupContainer(t, cli, containerID)
+		})
+	}
+	if err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+
+	inspect, err := cli.ContainerInspect(context.Background(), containerID)
+	if err != nil {
+		t.Fatalf("ContainerInspect: %v", err)
+	}
+	if inspect.State == nil || inspect.State.Health == nil || inspect.State.Health.Status != container.Healthy {
+		t.Fatalf("container is not healthy: %#v", inspect.State)
+	}
+}
@@ -0,0 +1,188 @@
+package godev
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// sshDaemonHost is the placeholder host used for requests tunneled through ssh; the dialer ignores it.
+const sshDaemonHost = "http://docker.example.com"
+
+// sshTarget is a parsed ssh://[user@]host[:port] DOCKER_HOST.
+type sshTarget struct {
+	User string // User is the optional login user.
+	Host string // Host is the remote host name or address.
+	Port string // Port is the optional ssh port.
+}
+
+// parseSSHHost validates an ssh:// DOCKER_HOST value.
+func parseSSHHost(value string) (sshTarget, error) {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return sshTarget{}, fmt.Errorf("invalid DOCKER_HOST %s: %w", value, err)
+	}
+	if parsed.Scheme != "ssh" {
+		return sshTarget{}, fmt.Errorf("invalid DOCKER_HOST %s: expected ssh scheme", value)
+	}
+	if parsed.Hostname() == "" {
+		return sshTarget{}, fmt.Errorf("invalid DOCKER_HOST %s: missing host", value)
+	}
+	if _, ok := parsed.User.Password(); ok {
+		return sshTarget{}, fmt.Errorf("invalid DOCKER_HOST %s: passwords are not supported, use an ssh key", value)
+	}
+	if (parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return sshTarget{}, fmt.Errorf("invalid DOCKER_HOST %s: ssh hosts cannot have a path, query, or fragment", value)
+	}
+	return sshTarget{User: parsed.User.Username(), Host: parsed.Hostname(), Port: parsed.Port()}, nil
+}
+
+// args returns the ssh arguments that start `docker system dial-stdio` on the remote host.
+// BatchMode makes a missing or rejected key fail immediately instead of prompting.
+func (t sshTarget) args() []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=30", "-T"}
+	if t.User != "" {
+		args = append(args, "-l", t.User)
+	}
+	if t.Port != "" {
+		args = append(args, "-p", t.Port)
+	}
+	return append(args, "--", t.Host, "docker", "system", "dial-stdio")
+}
+
+// sshClientOptions returns client options that reach the daemon at host through the local ssh binary.
+func sshClientOptions(host string) ([]client.Opt, error) {
+	target, err := parseSSHHost(host)
+	if err != nil {
+		return nil, err
+	}
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return nil, fmt.Errorf("DOCKER_HOST %s requires an ssh client on PATH: %w", host, err)
+	}
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialSSH(sshPath, host, target.args())
+	}
+	return []client.Opt{client.WithHost(sshDaemonHost), client.WithDialContext(dial)}, nil
+}
+
+// dockerEndpoint returns the daemon address to show in errors, preferring an ssh DOCKER_HOST over the tunnel placeholder.
+func dockerEndpoint(cli *client.Client) string {
+	if host := os.Getenv(client.EnvOverrideHost); strings.HasPrefix(host, "ssh://") {
+		return host
+	}
+	return cli.DaemonHost()
+}
+
+func dialSSH(sshPath, host string, args []string) (net.Conn, error) {
+	cmd := exec.Command(sshPath, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	conn := &sshConn{cmd: cmd, host: host, stdin: stdin, stdout: stdout}
+	cmd.Stderr = &conn.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start ssh for %s: %w", host, err)
+	}
+	return conn, nil
+}
+
+// lockedBuffer collects ssh stderr written from exec's copy goroutine.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// sshConn is a net.Conn over the stdin and stdout of an ssh process running docker system dial-stdio.
+type sshConn struct {
+	cmd       *exec.Cmd      // cmd is the running ssh process.
+	host      string         // host is the DOCKER_HOST value used in errors.
+	stdin     io.WriteCloser // stdin carries requests to the remote daemon.
+	stdout    io.ReadCloser  // stdout carries responses from the remote daemon.
+	stderr    lockedBuffer   // stderr collects ssh diagnostics such as auth failures.
+	waitOnce  sync.Once      // waitOnce guards reaping the ssh process.
+	closeOnce sync.Once      // closeOnce guards Close.
+}
+
+func (c *sshConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err != nil {
+		return n, c.processError(err)
+	}
+	return n, nil
+}
+
+func (c *sshConn) Write(p []byte) (int, error) {
+	n, err := c.stdin.Write(p)
+	if err != nil {
+		return n, c.processError(err)
+	}
+	return n, nil
+}
+
+// processError adds ssh's stderr to err once the process has exited, so auth and connection failures are visible.
+func (c *sshConn) processError(err error) error {
+	c.wait()
+	stderr := strings.TrimSpace(c.stderr.String())
+	if stderr == "" {
+		return err
+	}
+	return fmt.Errorf("ssh connection to %s failed: %s: %w", c.host, stderr, err)
+}
+
+func (c *sshConn) wait() {
+	c.waitOnce.Do(func() {
+		_ = c.cmd.Wait()
+	})
+}
+
+func (c *sshConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		if c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill()
+		}
+		c.wait()
+	})
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr              { return sshAddr{} }
+func (c *sshConn) RemoteAddr() net.Addr             { return sshAddr{} }
+func (c *sshConn) SetDeadline(time.Time) error      { return nil }
+func (c *sshConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *sshConn) SetWriteDeadline(time.Time) error { return nil }
+
+type sshAddr struct{}
+
+func (sshAddr) Network() string { return "ssh" }
+func (sshAddr) String() string  { return "ssh" }
+
+var _ net.Conn = (*sshConn)(nil)
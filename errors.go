@@ -0,0 +1,51 @@
+package godev
+
+import "errors"
+
+// ErrConfigNotFound reports that no devcontainer.json exists at the searched or given path.
+var ErrConfigNotFound = errors.New("devcontainer config not found")
+
+// ErrDockerUnavailable reports that the Docker daemon could not be configured or reached.
+var ErrDockerUnavailable = errors.New("docker daemon unavailable")
+
+// FeatureResolutionError reports that features could not be fetched, parsed, or ordered.
+// Impact: Its message is the underlying error's, and errors.As finds it anywhere in a returned error chain.
+// Example:
+//
+//	var featureErr *devcontainer.FeatureResolutionError
+//	if errors.As(err, &featureErr) { /* retry with a registry mirror */ }
+//
+// Similar: ErrConfigNotFound and ErrDockerUnavailable are sentinels matched with errors.Is.
+type FeatureResolutionError struct {
+	Err error // Err is the underlying cause.
+}
+
+func (e *FeatureResolutionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FeatureResolutionError) Unwrap() error {
+	return e.Err
+}
+
+// classifiedError tags an error with a sentinel kind without changing its message.
+type classifiedError struct {
+	kind error // kind is the sentinel matched by errors.Is.
+	err  error // err is the underlying cause.
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// classifyError tags err with kind so errors.Is matches both; a nil err stays nil.
+func classifyError(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{kind: kind, err: err}
+}
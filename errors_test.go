@@ -0,0 +1,55 @@
+package godev
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrConfigNotFound(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := FindConfigPath(dir); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected ErrConfigNotFound from FindConfigPath, got %v", err)
+	}
+	_, err := LoadConfig(filepath.Join(dir, "devcontainer.json"))
+	if !errors.Is(err, ErrConfigNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrConfigNotFound wrapping fs.ErrNotExist, got %v", err)
+	}
+	t.Chdir(dir)
+	if _, err := StartDevcontainer(context.Background(), WithoutPreflight()); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected ErrConfigNotFound from StartDevcontainer, got %v", err)
+	}
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+	if _, err := StartDevcontainer(context.Background()); !errors.Is(err, ErrConfigNotFound) || errors.Is(err, ErrDockerUnavailable) {
+		t.Fatalf("expected a missing config to be reported before an unreachable daemon, got %v", err)
+	}
+	if _, err := BuildImageFromDevcontainer(context.Background(), filepath.Join(dir, "devcontainer.json")); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected ErrConfigNotFound from BuildImageFromDevcontainer, got %v", err)
+	}
+}
+
+func TestErrDockerUnavailable(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+	err := preflightDocker(context.Background(), defaultStartOptions())
+	if !errors.Is(err, ErrDockerUnavailable) {
+		t.Fatalf("expected ErrDockerUnavailable, got %v", err)
+	}
+	if errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("unexpected ErrConfigNotFound match: %v", err)
+	}
+}
+
+func TestFeatureResolutionError(t *testing.T) {
+	root := t.TempDir()
+	cfg := &DevcontainerConfig{Features: FeatureSet{"./missing": FeatureOptions{}}}
+	_, err := resolveFeatures(context.Background(), filepath.Join(root, ".devcontainer", "devcontainer.json"), root, cfg, nil, "", "")
+	var featureErr *FeatureResolutionError
+	if !errors.As(err, &featureErr) {
+		t.Fatalf("expected FeatureResolutionError, got %T: %v", err, err)
+	}
+	if featureErr.Error() != featureErr.Err.Error() {
+		t.Fatalf("expected the underlying message, got %q", featureErr.Error())
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
@@ -56,6 +57,25 @@ func (v *FeatureOptionValue) UnmarshalJSON(data []byte) error {
 	}
 }
 
+// MarshalJSON encodes FeatureOptionValue as a JSON string or boolean.
+// Impact: Whichever of String or Bool is set is written, and an unset value becomes null.
+// Example:
+//
+//	value := "1.0"
+//	data, err := json.Marshal(devcontainer.FeatureOptionValue{String: &value})
+//
+// Similar: StringValue converts to a Go string, while MarshalJSON preserves the JSON type.
+func (v FeatureOptionValue) MarshalJSON() ([]byte, error) {
+	switch {
+	case v.String != nil:
+		return json.Marshal(*v.String)
+	case v.Bool != nil:
+		return json.Marshal(*v.Bool)
+	default:
+		return []byte("null"), nil
+	}
+}
+
 // StringValue converts a FeatureOptionValue to its string representation.
 // Impact: Bool values become "true"/"false", and missing values return an error.
 // Example:
@@ -85,6 +105,8 @@ func (v FeatureOptionValue) matchesType(expected string) bool {
 		return v.String != nil
 	case "boolean":
 		return v.Bool != nil
+	case featureOptionPath, featureOptionFileString:
+		return v.String != nil
 	default:
 		return false
 	}
@@ -163,7 +185,7 @@ func parseFeatureOptions(data []byte) (FeatureOptions, error) {
 
 // FeatureOptionDefinition describes a feature option declared in metadata.
 type FeatureOptionDefinition struct {
-	Type        string             `json:"type"`        // Type is the option type, such as string or boolean.
+	Type        string             `json:"type"`        // Type is the option type: string, boolean, or a workspace path type.
 	Default     FeatureOptionValue `json:"default"`     // Default is the default option value.
 	Enum        []string           `json:"enum"`        // Enum lists allowed values.
 	Proposals   []string           `json:"proposals"`   // Proposals lists suggested values for tooling.
@@ -191,8 +213,10 @@ type FeatureMetadata struct {
 	Privileged           bool                               `json:"privileged"`           // Privileged requests privileged container mode.
 	Init                 *bool                              `json:"init"`                 // Init controls Docker init usage.
 	CapAdd               []string                           `json:"capAdd"`               // CapAdd adds Linux capabilities.
+	CapDrop              []string                           `json:"capDrop"`              // CapDrop drops Linux capabilities.
 	SecurityOpt          []string                           `json:"securityOpt"`          // SecurityOpt supplies security options.
 	Entrypoint           string                             `json:"entrypoint"`           // Entrypoint points to a feature entrypoint script.
+	InstallUser          string                             `json:"installUser"`          // InstallUser runs install.sh as remoteUser, containerUser, or a named user instead of root.
 	Customizations       map[string]any                     `json:"customizations"`       // Customizations exposes editor/tooling settings.
 	DependsOn            FeatureSet                         `json:"dependsOn"`            // DependsOn declares dependent features.
 	InstallsAfter        []string                           `json:"installsAfter"`        // InstallsAfter lists features that should be installed first.
@@ -209,20 +233,33 @@ type FeatureMetadata struct {
 type FeatureSource string
 
 const (
-	FeatureSourceOCI   FeatureSource = "oci"
-	FeatureSourceHTTP  FeatureSource = "http"
-	FeatureSourceLocal FeatureSource = "local"
+	FeatureSourceOCI       FeatureSource = "oci"
+	FeatureSourceOCILayout FeatureSource = "oci-layout"
+	FeatureSourceHTTP      FeatureSource = "http"
+	FeatureSourceLocal     FeatureSource = "local"
+)
+
+// FeatureOrderStrategy selects how resolved features are ordered for installation.
+type FeatureOrderStrategy string
+
+const (
+	// FeatureOrderSpec follows the devcontainer spec: features are installed in rounds that honor dependsOn,
+	// installsAfter and overrideFeatureInstallOrder, and each round is sorted by feature name, tag and options.
+	FeatureOrderSpec FeatureOrderStrategy = "spec"
+	// FeatureOrderTopological installs one feature at a time once its dependsOn features are installed,
+	// preferring overrideFeatureInstallOrder entries and otherwise resolution order; installsAfter is ignored.
+	FeatureOrderTopological FeatureOrderStrategy = "topological"
 )
 
 // FeatureReference describes a parsed feature reference and its source.
 type FeatureReference struct {
 	ID         string        // ID is the raw feature identifier string.
-	Source     FeatureSource // Source indicates OCI, HTTP, or local resolution.
+	Source     FeatureSource // Source indicates OCI, OCI layout, HTTP, or local resolution.
 	Registry   string        // Registry is the OCI registry hostname when Source is OCI.
 	Repository string        // Repository is the OCI repository name when Source is OCI.
 	Reference  string        // Reference is the OCI tag or digest.
 	URL        string        // URL is the HTTP URL when Source is HTTP.
-	LocalPath  string        // LocalPath is the path when Source is local.
+	LocalPath  string        // LocalPath is the path when Source is local or the layout directory when Source is OCI layout.
 }
 
 // ResolvedFeatureOptions holds resolved option values for a feature.
@@ -255,57 +292,117 @@ type ResolvedFeatures struct {
 	Privileged   bool               // Privileged indicates whether privileged mode is required.
 	Init         *bool              // Init reflects merged init settings.
 	CapAdd       []string           // CapAdd is the merged capability list.
+	CapDrop      []string           // CapDrop is the merged dropped capability list.
 	SecurityOpt  []string           // SecurityOpt is the merged security options list.
+	tempDirs     []string           // tempDirs are directories remote features were extracted into.
+}
+
+// removeTempDirs deletes the directories remote features were extracted into. The features image build
+// copies them into its own context, so they are not needed once the build has finished.
+func (f *ResolvedFeatures) removeTempDirs() {
+	if f == nil {
+		return
+	}
+	removeFeatureDirs(f.tempDirs)
+	f.tempDirs = nil
+}
+
+func removeFeatureDirs(dirs []string) {
+	for _, dir := range dirs {
+		_ = os.RemoveAll(dir)
+	}
 }
 
 // featureResolver tracks state while resolving feature references.
 type featureResolver struct {
 	configDir       string                      // configDir is the directory of the devcontainer config.
 	devcontainerDir string                      // devcontainerDir is the workspace .devcontainer directory.
-	resolving       map[string]struct{}         // resolving tracks in-progress dependency keys.
+	resolving       map[string]int              // resolving maps in-progress request keys to their index in chain.
+	chain           []string                    // chain lists the feature IDs currently being resolved, outermost first.
 	resolved        map[string]*ResolvedFeature // resolved caches resolved features by key.
 	features        []*ResolvedFeature          // features is the list of resolved features.
 	registry        *registryClient             // registry provides feature registry access.
+	offline         bool                        // offline skips remote features instead of fetching them.
+	skipped         bool                        // skipped reports whether an offline resolver skipped a remote feature.
+	vars            map[string]string           // vars expands ${...} references in string option values.
+	workspaceRoot   string                      // workspaceRoot contains every path option value.
 }
 
-func resolveFeatures(ctx context.Context, configPath, workspaceRoot string, cfg *DevcontainerConfig) (*ResolvedFeatures, error) {
+// errFeatureOffline reports a remote feature that an offline resolver did not fetch.
+var errFeatureOffline = errors.New("remote feature not fetched offline")
+
+func resolveFeatures(ctx context.Context, configPath, workspaceRoot string, cfg *DevcontainerConfig, vars map[string]string, tempDir string, strategy FeatureOrderStrategy) (*ResolvedFeatures, error) {
 	if len(cfg.Features) == 0 {
 		return nil, nil
 	}
+	features, tempDirs, err := resolveFeatureRequests(ctx, configPath, workspaceRoot, cfg, vars, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	ordered, err := orderFeatures(features, cfg.OverrideFeatureInstallOrder, strategy)
+	if err != nil {
+		removeFeatureDirs(tempDirs)
+		return nil, &FeatureResolutionError{Err: err}
+	}
+	featureConfig := aggregateFeatureConfig(ordered)
+	return &ResolvedFeatures{
+		Order:        ordered,
+		ContainerEnv: featureConfig.containerEnv,
+		Mounts:       featureConfig.mounts,
+		Privileged:   featureConfig.privileged,
+		Init:         featureConfig.init,
+		CapAdd:       featureConfig.capAdd,
+		CapDrop:      featureConfig.capDrop,
+		SecurityOpt:  featureConfig.securityOpt,
+		tempDirs:     tempDirs,
+	}, nil
+}
+
+// resolveStartFeatures resolves features for a start or build, bounded by WithFeatureResolveTimeout when set.
+func resolveStartFeatures(ctx context.Context, configPath, workspaceRoot string, cfg *DevcontainerConfig, vars map[string]string, options startOptions) (*ResolvedFeatures, error) {
+	if options.FeatureTimeout <= 0 {
+		return resolveFeatures(ctx, configPath, workspaceRoot, cfg, vars, options.TempDir, options.FeatureOrder)
+	}
+	resolveCtx, cancel := context.WithTimeout(ctx, options.FeatureTimeout)
+	defer cancel()
+	features, err := resolveFeatures(resolveCtx, configPath, workspaceRoot, cfg, vars, options.TempDir, options.FeatureOrder)
+	if err != nil && ctx.Err() == nil && errors.Is(resolveCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("feature resolution timed out after %s: %w", options.FeatureTimeout, err)
+	}
+	return features, err
+}
+
+// resolveFeatureRequests fetches the requested features and their dependsOn closure in resolution order.
+// Option values are expanded against vars first. Remote features are extracted under tempDir ("" uses the
+// OS default); the returned directories are the caller's to remove and are already removed when an error is returned.
+func resolveFeatureRequests(ctx context.Context, configPath, workspaceRoot string, cfg *DevcontainerConfig, vars map[string]string, tempDir string) ([]*ResolvedFeature, []string, error) {
 	devcontainerDir := filepath.Join(workspaceRoot, ".devcontainer")
 	configDir := filepath.Dir(configPath)
 	resolver := &featureResolver{
 		configDir:       configDir,
 		devcontainerDir: devcontainerDir,
-		resolving:       make(map[string]struct{}),
+		resolving:       make(map[string]int),
 		resolved:        make(map[string]*ResolvedFeature),
-		registry:        newRegistryClient(),
+		registry:        newRegistryClient(tempDir),
+		vars:            vars,
+		workspaceRoot:   workspaceRoot,
 	}
 	ids := make([]string, 0, len(cfg.Features))
 	for id := range cfg.Features {
 		ids = append(ids, id)
 	}
 	sort.Strings(ids)
+	if err := validateFeatureIDCollisions(ids); err != nil {
+		return nil, nil, &FeatureResolutionError{Err: err}
+	}
 	for _, id := range ids {
 		options := cfg.Features[id]
 		if _, err := resolver.resolveRequest(ctx, id, options); err != nil {
-			return nil, err
+			removeFeatureDirs(resolver.registry.extracted)
+			return nil, nil, &FeatureResolutionError{Err: err}
 		}
 	}
-	ordered, err := orderFeatures(resolver.features, cfg.OverrideFeatureInstallOrder)
-	if err != nil {
-		return nil, err
-	}
-	featureConfig := aggregateFeatureConfig(ordered)
-	return &ResolvedFeatures{
-		Order:        ordered,
-		ContainerEnv: featureConfig.containerEnv,
-		Mounts:       featureConfig.mounts,
-		Privileged:   featureConfig.privileged,
-		Init:         featureConfig.init,
-		CapAdd:       featureConfig.capAdd,
-		SecurityOpt:  featureConfig.securityOpt,
-	}, nil
+	return resolver.features, resolver.registry.extracted, nil
 }
 
 func (r *featureResolver) resolveRequest(ctx context.Context, id string, options FeatureOptions) (*ResolvedFeature, error) {
@@ -313,18 +410,27 @@ func (r *featureResolver) resolveRequest(ctx context.Context, id string, options
 	if err != nil {
 		return nil, err
 	}
+	options, err = expandFeatureOptions(options, r.vars)
+	if err != nil {
+		return nil, fmt.Errorf("feature %s: %w", id, err)
+	}
 	reqKey, err := featureRequestKey(reference, options)
 	if err != nil {
 		return nil, err
 	}
-	if _, ok := r.resolving[reqKey]; ok {
-		return nil, fmt.Errorf("feature dependency cycle detected at %s", id)
+	if start, ok := r.resolving[reqKey]; ok {
+		cycle := append(append([]string{}, r.chain[start:]...), id)
+		return nil, fmt.Errorf("feature dependency cycle detected: %s", strings.Join(cycle, " -> "))
 	}
 	if existing, ok := r.resolved[reqKey]; ok {
 		return existing, nil
 	}
-	r.resolving[reqKey] = struct{}{}
-	defer delete(r.resolving, reqKey)
+	r.resolving[reqKey] = len(r.chain)
+	r.chain = append(r.chain, id)
+	defer func() {
+		delete(r.resolving, reqKey)
+		r.chain = r.chain[:len(r.chain)-1]
+	}()
 
 	resolved, err := r.fetchAndParse(ctx, reference, options)
 	if err != nil {
@@ -336,8 +442,16 @@ func (r *featureResolver) resolveRequest(ctx context.Context, id string, options
 	r.resolved[resolved.DependencyKey] = resolved
 	r.features = append(r.features, resolved)
 
-	for depID, depOptions := range resolved.Metadata.DependsOn {
-		dep, err := r.resolveRequest(ctx, depID, depOptions)
+	depIDs := make([]string, 0, len(resolved.Metadata.DependsOn))
+	for depID := range resolved.Metadata.DependsOn {
+		depIDs = append(depIDs, depID)
+	}
+	sort.Strings(depIDs)
+	for _, depID := range depIDs {
+		dep, err := r.resolveRequest(ctx, depID, resolved.Metadata.DependsOn[depID])
+		if errors.Is(err, errFeatureOffline) {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -366,6 +480,10 @@ func (r *featureResolver) fetchAndParse(ctx context.Context, reference FeatureRe
 		baseName = normalizeFeatureID(reference.LocalPath)
 		canonicalID = baseName
 	case FeatureSourceHTTP:
+		if r.offline {
+			r.skipped = true
+			return nil, errFeatureOffline
+		}
 		featureDir, digest, err = r.registry.fetchHTTPFeature(ctx, reference.URL)
 		if err != nil {
 			return nil, err
@@ -373,6 +491,10 @@ func (r *featureResolver) fetchAndParse(ctx context.Context, reference FeatureRe
 		baseName = normalizeFeatureID(reference.URL)
 		canonicalID = fmt.Sprintf("%s@%s", baseName, digest)
 	case FeatureSourceOCI:
+		if r.offline {
+			r.skipped = true
+			return nil, errFeatureOffline
+		}
 		featureDir, digest, err = r.registry.fetchOCIFeature(ctx, reference.Registry, reference.Repository, reference.Reference)
 		if err != nil {
 			return nil, err
@@ -380,6 +502,18 @@ func (r *featureResolver) fetchAndParse(ctx context.Context, reference FeatureRe
 		tag = reference.Reference
 		baseName = fmt.Sprintf("%s/%s", strings.ToLower(reference.Registry), strings.ToLower(reference.Repository))
 		canonicalID = fmt.Sprintf("%s@%s", baseName, digest)
+	case FeatureSourceOCILayout:
+		layoutDir := reference.LocalPath
+		if !filepath.IsAbs(layoutDir) {
+			layoutDir = filepath.Join(r.configDir, layoutDir)
+		}
+		featureDir, digest, err = r.registry.fetchOCILayoutFeature(ctx, layoutDir, reference.Reference)
+		if err != nil {
+			return nil, err
+		}
+		tag = reference.Reference
+		baseName = "oci:" + normalizeFeatureID(filepath.ToSlash(reference.LocalPath))
+		canonicalID = fmt.Sprintf("%s@%s", baseName, digest)
 	default:
 		return nil, fmt.Errorf("unsupported feature source: %s", reference.Source)
 	}
@@ -400,6 +534,9 @@ func (r *featureResolver) fetchAndParse(ctx context.Context, reference FeatureRe
 	if err != nil {
 		return nil, err
 	}
+	if err := resolveFeaturePathOptions(metadata.Options, resolvedOptions, r.workspaceRoot, r.vars); err != nil {
+		return nil, err
+	}
 	dependencyKey := featureEqualityKey(reference.Source, digest, resolvedOptions.Values)
 	return &ResolvedFeature{
 		Reference:     reference,
@@ -413,8 +550,51 @@ func (r *featureResolver) fetchAndParse(ctx context.Context, reference FeatureRe
 	}, nil
 }
 
+// featureMetadataFileNames lists the accepted metadata file names, canonical first; the others appear in older feature repos.
+var featureMetadataFileNames = []string{"devcontainer-feature.json", "devcontainer-features.json"}
+
+// isFeatureMetadataFileName reports whether name is a metadata file name in any casing.
+func isFeatureMetadataFileName(name string) bool {
+	for _, candidate := range featureMetadataFileNames {
+		if strings.EqualFold(name, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// featureMetadataPath returns the metadata file in featureDir, preferring the canonical name and then an
+// exact name over a case variant.
+func featureMetadataPath(featureDir string) (string, error) {
+	entries, err := os.ReadDir(featureDir)
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range featureMetadataFileNames {
+		var variant string
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if entry.Name() == candidate {
+				return filepath.Join(featureDir, candidate), nil
+			}
+			if variant == "" && strings.EqualFold(entry.Name(), candidate) {
+				variant = entry.Name()
+			}
+		}
+		if variant != "" {
+			return filepath.Join(featureDir, variant), nil
+		}
+	}
+	return "", fmt.Errorf("devcontainer-feature.json not found in %s: %w", featureDir, fs.ErrNotExist)
+}
+
 func readFeatureMetadata(featureDir string) (FeatureMetadata, error) {
-	path := filepath.Join(featureDir, "devcontainer-feature.json")
+	path, err := featureMetadataPath(featureDir)
+	if err != nil {
+		return FeatureMetadata{}, err
+	}
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return FeatureMetadata{}, err
@@ -483,10 +663,111 @@ func resolveFeatureOptions(defs map[string]FeatureOptionDefinition, user Feature
 	return resolved, nil
 }
 
+const (
+	// featureOptionPath is an option type whose string value names a file or directory in the workspace.
+	featureOptionPath = "path"
+	// featureOptionFileString is an alias of featureOptionPath used by some published features.
+	featureOptionFileString = "fileString"
+)
+
+// resolveFeaturePathOptions rewrites path-typed option values to absolute host paths. Defaults are expanded against
+// vars like user values already were, relative values are joined to workspaceRoot, and a value that leaves the
+// workspace, including through a symlink, is rejected so a feature cannot be pointed at arbitrary host files.
+// Empty values are left empty.
+func resolveFeaturePathOptions(defs map[string]FeatureOptionDefinition, resolved ResolvedFeatureOptions, workspaceRoot string, vars map[string]string) error {
+	for name, def := range defs {
+		if def.Type != featureOptionPath && def.Type != featureOptionFileString {
+			continue
+		}
+		value := resolved.Values[name]
+		_, fromUser := resolved.UserValues[name]
+		if !fromUser {
+			expanded, err := expandVariables(value, vars, nil)
+			if err != nil {
+				return fmt.Errorf("feature option %s: %w", name, err)
+			}
+			value = expanded
+		}
+		if value == "" {
+			continue
+		}
+		hostPath, err := workspaceContainedPath(workspaceRoot, value)
+		if err != nil {
+			return fmt.Errorf("feature option %s: %w", name, err)
+		}
+		resolved.Values[name] = hostPath
+		if fromUser {
+			resolved.UserValues[name] = hostPath
+		}
+	}
+	return nil
+}
+
+// workspaceContainedPath resolves value against workspaceRoot and fails unless it stays inside the workspace.
+func workspaceContainedPath(workspaceRoot, value string) (string, error) {
+	if workspaceRoot == "" {
+		return "", errors.New("path options need a workspace")
+	}
+	root, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.FromSlash(value)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target = filepath.Clean(target)
+	if !pathWithin(root, target) {
+		return "", fmt.Errorf("path %s is outside the workspace %s", value, root)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return target, nil
+	}
+	if !pathWithin(realRoot, evalExistingSymlinks(target)) {
+		return "", fmt.Errorf("path %s resolves outside the workspace %s", value, root)
+	}
+	return target, nil
+}
+
+// evalExistingSymlinks resolves symlinks in the longest existing prefix of target and keeps the missing rest as is.
+func evalExistingSymlinks(target string) string {
+	missing := ""
+	for current := target; ; current = filepath.Dir(current) {
+		if real, err := filepath.EvalSymlinks(current); err == nil {
+			return filepath.Join(real, missing)
+		}
+		if filepath.Dir(current) == current {
+			return target
+		}
+		missing = filepath.Join(filepath.Base(current), missing)
+	}
+}
+
+// pathWithin reports whether target is root or below it; both must be clean absolute paths.
+func pathWithin(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
+
 func normalizeFeatureID(id string) string {
 	return strings.ToLower(strings.TrimSpace(id))
 }
 
+// validateFeatureIDCollisions rejects requested IDs that differ only in case or surrounding space,
+// since they normalize to the same feature and would be ordered and merged ambiguously.
+func validateFeatureIDCollisions(ids []string) error {
+	seen := make(map[string]string, len(ids))
+	for _, id := range ids {
+		normalized := normalizeFeatureID(id)
+		if previous, ok := seen[normalized]; ok && previous != id {
+			return fmt.Errorf("feature IDs %q and %q refer to the same feature; keep only one", previous, id)
+		}
+		seen[normalized] = id
+	}
+	return nil
+}
+
 func normalizeIDs(ids []string) []string {
 	if len(ids) == 0 {
 		return nil
@@ -510,6 +791,36 @@ func normalizeOptionEnvName(name string) string {
 	return strings.ToUpper(clean)
 }
 
+// verifyFeatureVersions rejects, when strict is set, features sharing a BaseName but resolving to different content.
+// The same feature with different options is allowed; different tags or digests are reported as a conflict.
+func verifyFeatureVersions(features *ResolvedFeatures, strict bool) error {
+	if !strict || features == nil {
+		return nil
+	}
+	seen := make(map[string]*ResolvedFeature, len(features.Order))
+	for _, feature := range features.Order {
+		previous, ok := seen[feature.BaseName]
+		if !ok {
+			seen[feature.BaseName] = feature
+			continue
+		}
+		if previous.CanonicalName != feature.CanonicalName {
+			return fmt.Errorf("conflicting versions of feature %s: %s and %s", feature.BaseName, featureVersionLabel(previous), featureVersionLabel(feature))
+		}
+	}
+	return nil
+}
+
+func featureVersionLabel(feature *ResolvedFeature) string {
+	if feature.Tag != "" {
+		return feature.Tag
+	}
+	if feature.Metadata.Version != "" {
+		return feature.Metadata.Version
+	}
+	return feature.CanonicalName
+}
+
 func featureEqualityKey(source FeatureSource, digest string, options map[string]string) string {
 	hash := hashFeatureOptions(options)
 	switch source {
@@ -522,6 +833,26 @@ func featureEqualityKey(source FeatureSource, digest string, options map[string]
 	}
 }
 
+// expandFeatureOptions returns a copy of options with ${...} references in string values expanded, such as
+// ${localEnv:TOKEN} for a host token, so the feature receives and is keyed by the expanded value.
+func expandFeatureOptions(options FeatureOptions, vars map[string]string) (FeatureOptions, error) {
+	if len(options) == 0 {
+		return options, nil
+	}
+	expanded := make(FeatureOptions, len(options))
+	for key, value := range options {
+		if value.String != nil {
+			text, err := expandVariables(*value.String, vars, nil)
+			if err != nil {
+				return nil, fmt.Errorf("option %s: %w", key, err)
+			}
+			value = FeatureOptionValue{String: &text}
+		}
+		expanded[key] = value
+	}
+	return expanded, nil
+}
+
 func featureRequestKey(ref FeatureReference, options FeatureOptions) (string, error) {
 	values := make(map[string]string, len(options))
 	for key, value := range options {
@@ -565,6 +896,7 @@ type featureConfig struct {
 	privileged   bool              // privileged indicates privileged mode is required.
 	init         *bool             // init holds merged init preference.
 	capAdd       []string          // capAdd is the merged capability list.
+	capDrop      []string          // capDrop is the merged dropped capability list.
 	securityOpt  []string          // securityOpt is the merged security options list.
 }
 
@@ -590,6 +922,7 @@ func aggregateFeatureConfig(features []*ResolvedFeature) featureConfig {
 			cfg.init = feature.Metadata.Init
 		}
 		cfg.capAdd = appendUnique(cfg.capAdd, feature.Metadata.CapAdd...)
+		cfg.capDrop = appendUnique(cfg.capDrop, feature.Metadata.CapDrop...)
 		cfg.securityOpt = appendUnique(cfg.securityOpt, feature.Metadata.SecurityOpt...)
 	}
 	return cfg
@@ -613,21 +946,14 @@ func appendUnique(items []string, values ...string) []string {
 	return items
 }
 
-func orderFeatures(features []*ResolvedFeature, override []string) ([]*ResolvedFeature, error) {
+func orderFeatures(features []*ResolvedFeature, override []string, strategy FeatureOrderStrategy) ([]*ResolvedFeature, error) {
 	if len(features) == 0 {
 		return nil, nil
 	}
-	baseNameToKeys := make(map[string][]string)
-	for _, feature := range features {
-		baseNameToKeys[feature.BaseName] = append(baseNameToKeys[feature.BaseName], feature.DependencyKey)
-	}
-	for _, feature := range features {
-		for _, id := range feature.InstallsAfterIDs {
-			if keys, ok := baseNameToKeys[id]; ok {
-				feature.InstallsAfterKeys = append(feature.InstallsAfterKeys, keys...)
-			}
-		}
+	if strategy == FeatureOrderTopological {
+		return orderFeaturesTopologically(features, override)
 	}
+	linkInstallsAfter(features)
 	nodes := make(map[string]*ResolvedFeature, len(features))
 	for _, feature := range features {
 		nodes[feature.DependencyKey] = feature
@@ -647,17 +973,17 @@ func orderFeatures(features []*ResolvedFeature, override []string) ([]*ResolvedF
 			}
 		}
 		if len(round) == 0 {
-			return nil, errors.New("feature dependency cycle detected")
+			return nil, unresolvableFeaturesError(nodes, remaining, order)
 		}
 		maxPriority := 0
 		for _, node := range round {
-			if value, ok := priority[node.BaseName]; ok && value > maxPriority {
+			if value := featurePriority(priority, node.BaseName); value > maxPriority {
 				maxPriority = value
 			}
 		}
 		var commit []*ResolvedFeature
 		for _, node := range round {
-			if priority[node.BaseName] == maxPriority {
+			if featurePriority(priority, node.BaseName) == maxPriority {
 				commit = append(commit, node)
 			}
 		}
@@ -675,6 +1001,101 @@ func orderFeatures(features []*ResolvedFeature, override []string) ([]*ResolvedF
 	return order, nil
 }
 
+// orderFeaturesTopologically installs one feature at a time: among those whose dependsOn features are installed,
+// the highest overrideFeatureInstallOrder entry, otherwise the first in resolution order (config IDs sorted, each
+// followed by its dependencies).
+func orderFeaturesTopologically(features []*ResolvedFeature, override []string) ([]*ResolvedFeature, error) {
+	priority := computeOverridePriority(override)
+	if err := validateOverrideUsage(priority, features); err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*ResolvedFeature, len(features))
+	remaining := make(map[string]struct{}, len(features))
+	for _, feature := range features {
+		nodes[feature.DependencyKey] = feature
+		remaining[feature.DependencyKey] = struct{}{}
+	}
+	installed := make(map[string]struct{}, len(features))
+	order := make([]*ResolvedFeature, 0, len(features))
+	for len(remaining) > 0 {
+		var next *ResolvedFeature
+		for _, feature := range features {
+			if _, ok := remaining[feature.DependencyKey]; !ok || !dependenciesInstalled(feature, installed) {
+				continue
+			}
+			if next == nil || featurePriority(priority, feature.BaseName) > featurePriority(priority, next.BaseName) {
+				next = feature
+			}
+		}
+		if next == nil {
+			return nil, unresolvableFeaturesError(nodes, remaining, order)
+		}
+		order = append(order, next)
+		installed[next.DependencyKey] = struct{}{}
+		delete(remaining, next.DependencyKey)
+	}
+	return order, nil
+}
+
+func dependenciesInstalled(feature *ResolvedFeature, installed map[string]struct{}) bool {
+	for _, dep := range feature.DependsOnKeys {
+		if _, ok := installed[dep]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// unresolvableFeaturesError names each feature that could not be installed and the dependencies it still waits on.
+func unresolvableFeaturesError(nodes map[string]*ResolvedFeature, remaining map[string]struct{}, installed []*ResolvedFeature) error {
+	installedSet := make(map[string]struct{}, len(installed))
+	for _, feature := range installed {
+		installedSet[feature.DependencyKey] = struct{}{}
+	}
+	name := func(key string) string {
+		if node, ok := nodes[key]; ok && node.BaseName != "" {
+			return node.BaseName
+		}
+		return key
+	}
+	keys := make([]string, 0, len(remaining))
+	for key := range remaining {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return name(keys[i]) < name(keys[j])
+	})
+	details := make([]string, 0, len(keys))
+	for _, key := range keys {
+		node := nodes[key]
+		var waiting []string
+		for _, dep := range append(append([]string{}, node.DependsOnKeys...), node.InstallsAfterKeys...) {
+			if _, ok := installedSet[dep]; ok || dep == "" {
+				continue
+			}
+			waiting = append(waiting, name(dep))
+		}
+		details = append(details, fmt.Sprintf("%s waits on %s", name(key), strings.Join(sortedUnique(waiting), ", ")))
+	}
+	return fmt.Errorf("feature dependency cycle detected: %s", strings.Join(details, "; "))
+}
+
+// linkInstallsAfter resolves each feature's installsAfter IDs to the dependency keys of resolved features.
+func linkInstallsAfter(features []*ResolvedFeature) {
+	baseNameToKeys := make(map[string][]string)
+	for _, feature := range features {
+		baseNameToKeys[feature.BaseName] = append(baseNameToKeys[feature.BaseName], feature.DependencyKey)
+	}
+	for _, feature := range features {
+		feature.InstallsAfterKeys = nil
+		for _, id := range feature.InstallsAfterIDs {
+			if keys, ok := baseNameToKeys[id]; ok {
+				feature.InstallsAfterKeys = append(feature.InstallsAfterKeys, keys...)
+			}
+		}
+	}
+}
+
 func computeOverridePriority(ids []string) map[string]int {
 	if len(ids) == 0 {
 		return map[string]int{}
@@ -691,6 +1112,28 @@ func computeOverridePriority(ids []string) map[string]int {
 	return priority
 }
 
+// featurePriority returns the override priority for baseName. An exact entry wins;
+// otherwise the highest-priority glob entry (e.g. "ghcr.io/devcontainers/features/*") that matches applies.
+func featurePriority(priority map[string]int, baseName string) int {
+	if value, ok := priority[baseName]; ok {
+		return value
+	}
+	best := 0
+	for pattern, value := range priority {
+		if !isOverridePattern(pattern) || value <= best {
+			continue
+		}
+		if matched, _ := path.Match(pattern, baseName); matched {
+			best = value
+		}
+	}
+	return best
+}
+
+func isOverridePattern(id string) bool {
+	return strings.ContainsAny(id, "*?[")
+}
+
 func validateOverrideUsage(priority map[string]int, features []*ResolvedFeature) error {
 	if len(priority) == 0 {
 		return nil
@@ -700,8 +1143,24 @@ func validateOverrideUsage(priority map[string]int, features []*ResolvedFeature)
 		known[feature.BaseName] = struct{}{}
 	}
 	for id := range priority {
-		if _, ok := known[id]; !ok {
-			return fmt.Errorf("overrideFeatureInstallOrder includes unknown feature: %s", id)
+		if !isOverridePattern(id) {
+			if _, ok := known[id]; !ok {
+				return fmt.Errorf("overrideFeatureInstallOrder includes unknown feature: %s", id)
+			}
+			continue
+		}
+		if _, err := path.Match(id, ""); err != nil {
+			return fmt.Errorf("overrideFeatureInstallOrder includes invalid pattern %s: %w", id, err)
+		}
+		matched := false
+		for baseName := range known {
+			if ok, _ := path.Match(id, baseName); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("overrideFeatureInstallOrder pattern matches no feature: %s", id)
 		}
 	}
 	return nil
@@ -854,6 +1313,9 @@ func parseFeatureReference(id string) (FeatureReference, error) {
 	if trimmed == "" {
 		return FeatureReference{}, errors.New("feature id cannot be empty")
 	}
+	if layout, ok := strings.CutPrefix(trimmed, "oci:"); ok {
+		return parseOCILayoutReference(trimmed, layout)
+	}
 	normalized := normalizeFeatureID(trimmed)
 	if strings.HasPrefix(normalized, "http://") || strings.HasPrefix(normalized, "https://") {
 		return FeatureReference{ID: trimmed, Source: FeatureSourceHTTP, URL: trimmed}, nil
@@ -874,6 +1336,21 @@ func parseFeatureReference(id string) (FeatureReference, error) {
 	}, nil
 }
 
+// parseOCILayoutReference parses the part of an "oci:" feature ID after the prefix: a layout directory, relative
+// to the devcontainer.json directory, optionally followed by ":tag" or "@digest". The tag defaults to "latest".
+func parseOCILayoutReference(id, layout string) (FeatureReference, error) {
+	reference := "latest"
+	if dir, digest, ok := strings.Cut(layout, "@"); ok {
+		layout, reference = dir, digest
+	} else if idx := strings.LastIndex(layout, ":"); idx > strings.LastIndexAny(layout, `/\`) {
+		layout, reference = layout[:idx], layout[idx+1:]
+	}
+	if layout == "" || reference == "" {
+		return FeatureReference{}, fmt.Errorf("invalid OCI layout feature reference: %s", id)
+	}
+	return FeatureReference{ID: id, Source: FeatureSourceOCILayout, LocalPath: layout, Reference: reference}, nil
+}
+
 func parseOCIReference(id string) (string, string, string, error) {
 	parts := strings.Split(id, "/")
 	if len(parts) < 2 {
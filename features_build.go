@@ -4,33 +4,102 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 const featureImageBaseDir = "/usr/local/share/devcontainer/features"
 
-func buildFeaturesImage(ctx context.Context, cli *client.Client, baseImage, baseUser, workspaceRoot, devcontainerID string, cfg *DevcontainerConfig, features []*ResolvedFeature, vars map[string]string) (string, error) {
+// featureEntrypointWrapper chains feature entrypoints before the container command.
+const featureEntrypointWrapper = featureImageBaseDir + "/entrypoint.sh"
+
+// featuresCacheLabel records the build input hash on a feature image so an unchanged feature set skips the build.
+const featuresCacheLabel = "devcontainer.features_cache_key"
+
+func buildFeaturesImage(ctx context.Context, cli *client.Client, baseImage, baseUser, workspaceRoot, devcontainerID string, cfg *DevcontainerConfig, features []*ResolvedFeature, vars map[string]string, options startOptions) (string, error) {
 	if len(features) == 0 {
 		return baseImage, nil
 	}
-	contextDir, err := os.MkdirTemp("", "godev-features-build-*")
+	contextDir, err := os.MkdirTemp(options.TempDir, "godev-features-build-*")
 	if err != nil {
 		return "", err
 	}
 	defer func() {
 		_ = os.RemoveAll(contextDir)
 	}()
+	if err := writeFeatureBuildContext(contextDir, baseImage, baseUser, cfg, features, vars, options); err != nil {
+		return "", err
+	}
+	tag := featuresImageTag(workspaceRoot, devcontainerID, features)
+	if options.SharedFeatures {
+		tag = sharedFeaturesImageTag(baseImage, baseUser, cfg, features)
+	}
+	cacheKey, err := featuresCacheKey(ctx, cli, baseImage, contextDir)
+	if err != nil {
+		options.logger().Debug("feature image cache disabled", "error", err)
+		cacheKey = ""
+	}
+	if cacheKey != "" && !options.NoBuildCache {
+		if existing, err := cli.ImageInspect(ctx, tag); err == nil && existing.Config != nil && existing.Config.Labels[featuresCacheLabel] == cacheKey {
+			options.logger().Info("feature image is up to date", "image", tag)
+			return tag, nil
+		}
+	}
+	buildContext, err := tarDirectory(contextDir)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = buildContext.Close()
+	}()
+	buildOptions := newFeatureImageBuildOptions(tag, options)
+	if cacheKey != "" {
+		buildOptions.Labels = map[string]string{featuresCacheLabel: cacheKey}
+	}
+	resp, err := cli.ImageBuild(ctx, buildContext, buildOptions)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	redactor := options.secretRedactor()
+	output, flush := redactWriter(options.BuildOutput, redactor)
+	err = consumeFeatureBuildOutput(resp.Body, features, options.logger(), output)
+	flush()
+	if err != nil {
+		return "", redactError(err, redactor)
+	}
+	return tag, nil
+}
+
+// writeFeatureBuildContext copies each feature with its rendered devcontainer-features.env, the
+// entrypoint wrapper, and the Dockerfile into contextDir. With WithDumpFeatureEnv the env files are
+// also written to the dump directory as <NN-id>.env.
+func writeFeatureBuildContext(contextDir, baseImage, baseUser string, cfg *DevcontainerConfig, features []*ResolvedFeature, vars map[string]string, options startOptions) error {
 	featuresDir := filepath.Join(contextDir, "features")
 	if err := os.MkdirAll(featuresDir, 0o755); err != nil {
-		return "", err
+		return err
+	}
+	if options.DumpFeatureEnv != "" {
+		if err := os.MkdirAll(options.DumpFeatureEnv, 0o755); err != nil {
+			return err
+		}
 	}
 	extraEnv := featureUserEnv(cfg, baseUser)
 	for idx, feature := range features {
@@ -38,51 +107,179 @@ func buildFeaturesImage(ctx context.Context, cli *client.Client, baseImage, base
 		source := feature.FeatureDir
 		dest := filepath.Join(featuresDir, dirName)
 		if err := copyDir(source, dest); err != nil {
-			return "", err
+			return err
 		}
 		feature.ImageDir = path.Join(featureImageBaseDir, dirName)
 		entrypoint, err := featureEntrypointPath(feature, vars)
 		if err != nil {
-			return "", err
+			return err
 		}
 		if entrypoint != "" && !strings.HasPrefix(entrypoint, feature.ImageDir) {
-			return "", fmt.Errorf("feature entrypoint must be under %s", feature.ImageDir)
+			return fmt.Errorf("feature entrypoint must be under %s", feature.ImageDir)
 		}
 		envFile := renderFeatureEnvFile(feature.Options.Values, extraEnv)
 		if err := os.WriteFile(filepath.Join(dest, "devcontainer-features.env"), []byte(envFile), 0o644); err != nil {
-			return "", err
+			return err
+		}
+		if options.DumpFeatureEnv != "" {
+			if err := os.WriteFile(filepath.Join(options.DumpFeatureEnv, dirName+".env"), []byte(envFile), 0o644); err != nil {
+				return err
+			}
 		}
 	}
-	dockerfile := buildFeaturesDockerfile(baseImage, baseUser, features, vars)
-	if err := os.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte(dockerfile), 0o644); err != nil {
-		return "", err
+	wrapper, err := featureEntrypointScript(features, vars)
+	if err != nil {
+		return err
 	}
-	buildContext, err := tarDirectory(contextDir)
+	if wrapper != "" {
+		if err := os.WriteFile(filepath.Join(featuresDir, path.Base(featureEntrypointWrapper)), []byte(wrapper), 0o755); err != nil {
+			return err
+		}
+	}
+	dockerfile, err := buildFeaturesDockerfile(baseImage, baseUser, features, vars, extraEnv)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte(dockerfile), 0o644)
+}
+
+// featuresCacheKey hashes everything a feature build reads: the base image ID and every file in the build context,
+// which holds the Dockerfile, each feature's files, and the rendered option env files.
+func featuresCacheKey(ctx context.Context, cli *client.Client, baseImage, contextDir string) (string, error) {
+	base, err := cli.ImageInspect(ctx, baseImage)
 	if err != nil {
 		return "", err
 	}
-	defer func() {
-		_ = buildContext.Close()
-	}()
-	tag := featuresImageTag(workspaceRoot, devcontainerID, features)
-	resp, err := cli.ImageBuild(ctx, buildContext, build.ImageBuildOptions{
-		Dockerfile: "Dockerfile",
-		Tags:       []string{tag},
-		Remove:     true,
+	hasher := sha256.New()
+	_, _ = fmt.Fprintf(hasher, "%s\x00", base.ID)
+	err = filepath.WalkDir(contextDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contextDir, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(hasher, "%s\x00%s\x00", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(hasher, "%s\x00", link)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(hasher, file)
+			_ = file.Close()
+			if err != nil {
+				return err
+			}
+			_, _ = hasher.Write([]byte{0})
+		}
+		return nil
 	})
 	if err != nil {
 		return "", err
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		return "", err
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func newFeatureImageBuildOptions(tag string, options startOptions) build.ImageBuildOptions {
+	return build.ImageBuildOptions{
+		Dockerfile:  "Dockerfile",
+		Tags:        []string{tag},
+		Remove:      true,
+		NoCache:     options.NoBuildCache,
+		NetworkMode: options.BuildNetwork,
+		ExtraHosts:  options.BuildExtraHosts,
 	}
-	return tag, nil
 }
 
-func buildFeaturesDockerfile(baseImage, baseUser string, features []*ResolvedFeature, vars map[string]string) string {
+// buildMessage is one JSON message from a docker build, pull, or push response stream.
+type buildMessage struct {
+	Stream   string `json:"stream"`   // Stream is build output text.
+	Status   string `json:"status"`   // Status is a pull or push progress message.
+	ID       string `json:"id"`       // ID names the layer a Status refers to.
+	Progress string `json:"progress"` // Progress is set on repeated download and upload progress bars.
+	Error    string `json:"error"`    // Error is set when the operation failed.
+}
+
+// consumeFeatureBuildOutput reads the build stream, logging when each feature's RUN step starts
+// and how long it took, and copies output to w with lines prefixed by the feature's canonical name.
+func consumeFeatureBuildOutput(r io.Reader, features []*ResolvedFeature, logger *slog.Logger, w io.Writer) error {
+	var current *ResolvedFeature
+	var started time.Time
+	finish := func() {
+		if current != nil {
+			logger.Info("feature installed", "feature", current.CanonicalName, "duration", time.Since(started))
+			current = nil
+		}
+	}
+	decoder := json.NewDecoder(r)
+	for {
+		var message buildMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if message.Error != "" {
+			return fmt.Errorf("feature build failed: %s", message.Error)
+		}
+		for _, line := range strings.SplitAfter(message.Stream, "\n") {
+			if line == "" {
+				continue
+			}
+			if strings.HasPrefix(line, "Step ") {
+				finish()
+				if feature := featureForBuildStep(line, features); feature != nil {
+					current = feature
+					started = time.Now()
+					logger.Info("installing feature", "feature", feature.CanonicalName)
+				}
+			}
+			if w == nil {
+				continue
+			}
+			if current != nil {
+				line = fmt.Sprintf("[%s] %s", current.CanonicalName, line)
+			}
+			if _, err := io.WriteString(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	finish()
+	return nil
+}
+
+// featureForBuildStep matches a "Step N/M : RUN ..." line to the feature whose install it runs.
+func featureForBuildStep(line string, features []*ResolvedFeature) *ResolvedFeature {
+	for _, feature := range features {
+		if feature.ImageDir != "" && strings.Contains(line, "cd "+feature.ImageDir+";") {
+			return feature
+		}
+	}
+	return nil
+}
+
+// dockerUserPattern matches a user or UID with an optional group, the forms that are safe unquoted on a Dockerfile
+// USER line and in the chown before it.
+var dockerUserPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]*)?$`)
+
+func buildFeaturesDockerfile(baseImage, baseUser string, features []*ResolvedFeature, vars, userEnv map[string]string) (string, error) {
+	if baseUser != "" && !dockerUserPattern.MatchString(baseUser) {
+		return "", fmt.Errorf("invalid image user %q", baseUser)
+	}
 	var b strings.Builder
 	fmt.Fprintf(&b, "FROM %s\n", baseImage)
 	b.WriteString("USER root\n")
@@ -90,12 +287,77 @@ func buildFeaturesDockerfile(baseImage, baseUser string, features []*ResolvedFea
 	fmt.Fprintf(&b, "COPY features/ %s/\n", featureImageBaseDir)
 	for _, feature := range features {
 		command := featureInstallCommand(feature, vars)
+		user := featureInstallUser(feature, userEnv)
+		if user == "root" {
+			fmt.Fprintf(&b, "RUN %s\n", command)
+			continue
+		}
+		if !dockerUserPattern.MatchString(user) {
+			return "", fmt.Errorf("feature %s: invalid install user %q", feature.Metadata.ID, user)
+		}
+		fmt.Fprintf(&b, "RUN chown -R %s %s\n", user, feature.ImageDir)
+		fmt.Fprintf(&b, "USER %s\n", user)
 		fmt.Fprintf(&b, "RUN %s\n", command)
+		b.WriteString("USER root\n")
+	}
+	if hasFeatureEntrypoints(features) {
+		fmt.Fprintf(&b, "RUN chmod +x %s\n", featureEntrypointWrapper)
 	}
 	if baseUser != "" && baseUser != "root" {
 		fmt.Fprintf(&b, "USER %s\n", baseUser)
 	}
-	return b.String()
+	return b.String(), nil
+}
+
+// featureEntrypointScript renders a wrapper that runs each feature entrypoint in install order
+// and then execs the container command; it returns "" when no feature declares an entrypoint.
+func featureEntrypointScript(features []*ResolvedFeature, vars map[string]string) (string, error) {
+	var b strings.Builder
+	for _, feature := range features {
+		entrypoint, err := featureEntrypointPath(feature, vars)
+		if err != nil {
+			return "", err
+		}
+		if entrypoint == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString("#!/bin/sh\n")
+		}
+		fmt.Fprintf(&b, "%s\n", entrypoint)
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	b.WriteString("exec \"$@\"\n")
+	return b.String(), nil
+}
+
+func hasFeatureEntrypoints(features []*ResolvedFeature) bool {
+	for _, feature := range features {
+		if feature.Metadata.Entrypoint != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// featureInstallUser resolves the user a feature's install.sh runs as.
+// The installUser metadata accepts remoteUser, containerUser, or a literal user; root is the default.
+func featureInstallUser(feature *ResolvedFeature, userEnv map[string]string) string {
+	user := strings.TrimSpace(feature.Metadata.InstallUser)
+	switch user {
+	case "":
+		return "root"
+	case "remoteUser":
+		user = userEnv["_REMOTE_USER"]
+	case "containerUser":
+		user = userEnv["_CONTAINER_USER"]
+	}
+	if user == "" || user == "0" {
+		return "root"
+	}
+	return user
 }
 
 func featureInstallCommand(feature *ResolvedFeature, vars map[string]string) string {
@@ -121,6 +383,17 @@ func featuresImageTag(workspaceRoot, devcontainerID string, features []*Resolved
 	return fmt.Sprintf("godev-%s-%s-features-%s:latest", base, devcontainerID, hex.EncodeToString(sum[:8]))
 }
 
+// sharedFeaturesImageTag names a feature image by everything its build depends on and nothing workspace specific.
+func sharedFeaturesImageTag(baseImage, baseUser string, cfg *DevcontainerConfig, features []*ResolvedFeature) string {
+	userEnv := featureUserEnv(cfg, baseUser)
+	hashInput := []string{baseImage, userEnv["_CONTAINER_USER"], userEnv["_REMOTE_USER"]}
+	for _, feature := range features {
+		hashInput = append(hashInput, feature.DependencyKey)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(hashInput, "\n")))
+	return fmt.Sprintf("godev-features-%s:latest", hex.EncodeToString(sum[:8]))
+}
+
 func featureUserEnv(cfg *DevcontainerConfig, baseUser string) map[string]string {
 	containerUser := cfg.ContainerUser
 	if containerUser == "" {
@@ -163,6 +436,9 @@ func copyDir(source, dest string) error {
 		if err != nil {
 			return err
 		}
+		if rel == featureExtractionSentinel {
+			return nil
+		}
 		target := filepath.Join(dest, rel)
 		info, err := entry.Info()
 		if err != nil {
@@ -203,13 +479,91 @@ func copyDir(source, dest string) error {
 	})
 }
 
-func imageDefaultUser(ctx context.Context, cli *client.Client, imageRef string) (string, error) {
-	inspect, err := cli.ImageInspect(ctx, imageRef)
+// imageDefaultUser returns the image's configured user for platform, falling back to root.
+func imageDefaultUser(ctx context.Context, cli *client.Client, imageRef string, platform *ocispec.Platform) (string, error) {
+	var inspectOpts []client.ImageInspectOption
+	if platform != nil && !versions.LessThan(cli.ClientVersion(), "1.49") {
+		inspectOpts = append(inspectOpts, client.ImageInspectWithPlatform(platform))
+	}
+	inspect, err := cli.ImageInspect(ctx, imageRef, inspectOpts...)
 	if err != nil {
 		return "", err
 	}
-	if inspect.Config == nil {
-		return "", nil
+	if inspect.Config == nil || inspect.Config.User == "" {
+		return "root", nil
 	}
 	return inspect.Config.User, nil
 }
+
+// imageCommand returns the image's configured entrypoint and default command.
+func imageCommand(ctx context.Context, cli *client.Client, imageRef string) ([]string, []string, error) {
+	inspect, err := cli.ImageInspect(ctx, imageRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inspect.Config == nil {
+		return nil, nil, nil
+	}
+	return inspect.Config.Entrypoint, inspect.Config.Cmd, nil
+}
+
+// defaultKeepAliveShell runs the keep-alive loop when the image does not name a shell.
+const defaultKeepAliveShell = "/bin/sh"
+
+// keepAliveShells lists the shell names that can run the keep-alive loop.
+var keepAliveShells = map[string]bool{"sh": true, "bash": true, "ash": true, "dash": true, "zsh": true}
+
+// keepAliveCommand runs a sleep loop under shell that keeps the container up when overrideCommand is true.
+func keepAliveCommand(shell string) []string {
+	return []string{shell, "-c", "while sleep 1000; do :; done"}
+}
+
+// imageShell picks the shell for the keep-alive loop from the image's SHELL, entrypoint, or command, so an
+// image that only names /bin/bash gets bash. It falls back to /bin/sh, also when the image cannot be inspected.
+func imageShell(ctx context.Context, cli *client.Client, imageRef string, logger *slog.Logger) string {
+	inspect, err := cli.ImageInspect(ctx, imageRef)
+	if err != nil {
+		logger.Debug("image inspect failed, using default keep-alive shell", "image", imageRef, "error", err)
+		return defaultKeepAliveShell
+	}
+	if inspect.Config == nil {
+		return defaultKeepAliveShell
+	}
+	for _, command := range [][]string{inspect.Config.Shell, inspect.Config.Entrypoint, inspect.Config.Cmd} {
+		if len(command) > 0 && keepAliveShells[path.Base(command[0])] {
+			return command[0]
+		}
+	}
+	return defaultKeepAliveShell
+}
+
+// checkKeepAliveShell fails the start when the created container has no shell to run the keep-alive loop, as with
+// distroless or scratch images, instead of letting the container exit as soon as it starts. A stat that fails for
+// another reason is logged and the start goes on.
+func checkKeepAliveShell(ctx context.Context, cli *client.Client, containerID, shell string, logger *slog.Logger) error {
+	_, err := cli.ContainerStatPath(ctx, containerID, shell)
+	if err == nil {
+		return nil
+	}
+	if cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("image has no %s to run the keep-alive loop; set overrideCommand to false or use WithKeepAliveCommand", shell)
+	}
+	logger.Debug("keep-alive shell stat failed", "container", containerID, "shell", shell, "error", err)
+	return nil
+}
+
+// parsePlatform converts an os/arch[/variant] string; an empty string yields nil.
+func parsePlatform(value string) (*ocispec.Platform, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(strings.ToLower(value), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid platform: %s", value)
+	}
+	platform := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
@@ -60,7 +60,7 @@ func TestDockerEngine_FeaturesLocal(t *testing.T) {
 	if err != nil {
 		t.Fatalf("resolveWorkspacePaths: %v", err)
 	}
-	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg)
+	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg, nil, "", "")
 	if err != nil {
 		t.Fatalf("resolveFeatures: %v", err)
 	}
@@ -177,7 +177,7 @@ func TestDockerEngine_FeaturesOCI(t *testing.T) {
 	if err != nil {
 		t.Fatalf("resolveWorkspacePaths: %v", err)
 	}
-	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg)
+	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg, nil, "", "")
 	if err != nil {
 		t.Fatalf("resolveFeatures: %v", err)
 	}
@@ -259,7 +259,7 @@ func TestDockerEngine_FeaturesGHCR(t *testing.T) {
 	if err != nil {
 		t.Fatalf("resolveWorkspacePaths: %v", err)
 	}
-	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg)
+	features, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg, nil, "", "")
 	if err != nil {
 		t.Fatalf("resolveFeatures: %v", err)
 	}
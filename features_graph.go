@@ -0,0 +1,88 @@
+package godev
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FeatureGraph returns the resolved feature dependency graph in Graphviz DOT format.
+// Impact: It resolves features (fetching remote ones) but does not order them, so installsAfter cycles still render.
+// Example:
+//
+//	dot, err := devcontainer.FeatureGraph(ctx, "./.devcontainer/devcontainer.json")
+//
+// Similar: EffectiveConfig shows the merged config; FeatureGraph shows how its features relate.
+func FeatureGraph(ctx context.Context, configPath string) (string, error) {
+	options := defaultStartOptions()
+	options.ConfigPath = configPath
+	configPath, cfg, err := loadEffectiveConfig(options)
+	if err != nil {
+		return "", err
+	}
+	var workspaceRoot string
+	var vars map[string]string
+	if isComposeConfig(cfg) {
+		workspaceRoot, _, vars, err = resolveComposeWorkspacePaths(configPath, cfg)
+	} else {
+		workspaceRoot, _, _, vars, err = resolveWorkspacePaths(configPath, cfg)
+	}
+	if err != nil {
+		return "", err
+	}
+	var features []*ResolvedFeature
+	if len(cfg.Features) > 0 {
+		var tempDirs []string
+		features, tempDirs, err = resolveFeatureRequests(ctx, configPath, workspaceRoot, cfg, vars, options.TempDir)
+		if err != nil {
+			return "", err
+		}
+		defer removeFeatureDirs(tempDirs)
+	}
+	linkInstallsAfter(features)
+	return renderFeatureGraph(features), nil
+}
+
+// renderFeatureGraph writes one node per feature and an edge from each feature to the features it
+// depends on (solid) or installs after (dashed).
+func renderFeatureGraph(features []*ResolvedFeature) string {
+	sorted := append([]*ResolvedFeature{}, features...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return featureLess(sorted[i], sorted[j])
+	})
+	var b strings.Builder
+	b.WriteString("digraph features {\n")
+	for _, feature := range sorted {
+		fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(feature.DependencyKey), dotQuote(feature.CanonicalName))
+	}
+	for _, feature := range sorted {
+		for _, key := range sortedUnique(feature.DependsOnKeys) {
+			fmt.Fprintf(&b, "  %s -> %s [label=\"dependsOn\"];\n", dotQuote(feature.DependencyKey), dotQuote(key))
+		}
+		for _, key := range sortedUnique(feature.InstallsAfterKeys) {
+			fmt.Fprintf(&b, "  %s -> %s [label=\"installsAfter\", style=dashed];\n", dotQuote(feature.DependencyKey), dotQuote(key))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func sortedUnique(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		unique = append(unique, value)
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
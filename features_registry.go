@@ -20,7 +20,9 @@ import (
 	"time"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 	"oras.land/oras-go/v2/registry/remote/retry"
@@ -28,8 +30,11 @@ import (
 
 // registryClient fetches feature artifacts from registries or HTTP sources.
 type registryClient struct {
-	httpClient *http.Client            // httpClient performs HTTP requests.
-	auth       map[string]registryAuth // auth caches registry credentials.
+	httpClient  *http.Client            // httpClient performs HTTP requests.
+	auth        map[string]registryAuth // auth caches registry credentials.
+	tempDir     string                  // tempDir is the parent of extracted feature directories; "" uses the OS default.
+	extracted   []string                // extracted lists the directories this client extracted features into.
+	extractions map[string]string       // extractions maps archive checksums to completed extraction directories.
 }
 
 // registryAuth holds credentials for a registry host.
@@ -39,10 +44,15 @@ type registryAuth struct {
 	identityToken string // identityToken is an OAuth token when present.
 }
 
-func newRegistryClient() *registryClient {
+// registryAuths maps docker config host keys, such as "ghcr.io", to credentials.
+type registryAuths map[string]registryAuth
+
+func newRegistryClient(tempDir string) *registryClient {
 	return &registryClient{
-		httpClient: &http.Client{Timeout: 2 * time.Minute},
-		auth:       make(map[string]registryAuth),
+		httpClient:  &http.Client{Timeout: 2 * time.Minute},
+		auth:        make(map[string]registryAuth),
+		tempDir:     tempDir,
+		extractions: make(map[string]string),
 	}
 }
 
@@ -66,7 +76,7 @@ func (c *registryClient) fetchHTTPFeature(ctx context.Context, url string) (stri
 		return "", "", err
 	}
 	digest := sha256.Sum256(data)
-	dir, err := extractFeatureArchive(data)
+	dir, err := c.extract(data)
 	if err != nil {
 		return "", "", err
 	}
@@ -88,13 +98,29 @@ func (c *registryClient) fetchOCIFeature(ctx context.Context, registry, reposito
 			return c.orasCredential(hostport), nil
 		},
 	}
-	desc, err := repo.Resolve(ctx, reference)
+	return c.fetchOCITargetFeature(ctx, repo, reference)
+}
+
+// fetchOCILayoutFeature reads a feature from an OCI image layout directory, such as one written by
+// "oras copy --to-oci-layout", so air-gapped setups need no registry.
+func (c *registryClient) fetchOCILayoutFeature(ctx context.Context, layoutDir, reference string) (string, string, error) {
+	store, err := oci.NewFromFS(ctx, os.DirFS(layoutDir))
+	if err != nil {
+		return "", "", fmt.Errorf("OCI layout %s: %w", layoutDir, err)
+	}
+	return c.fetchOCITargetFeature(ctx, store, reference)
+}
+
+// fetchOCITargetFeature resolves reference in target, follows an index to its first manifest, and extracts
+// the feature layer; it returns the extracted directory and the manifest digest.
+func (c *registryClient) fetchOCITargetFeature(ctx context.Context, target oras.ReadOnlyTarget, reference string) (string, string, error) {
+	desc, err := target.Resolve(ctx, reference)
 	if err != nil {
 		return "", "", err
 	}
 	manifestDesc := desc
 	if isManifestIndex(desc.MediaType) {
-		indexBytes, err := content.FetchAll(ctx, repo, desc)
+		indexBytes, err := content.FetchAll(ctx, target, desc)
 		if err != nil {
 			return "", "", err
 		}
@@ -107,7 +133,7 @@ func (c *registryClient) fetchOCIFeature(ctx context.Context, registry, reposito
 		}
 		manifestDesc = index.Manifests[0]
 	}
-	manifestBytes, err := content.FetchAll(ctx, repo, manifestDesc)
+	manifestBytes, err := content.FetchAll(ctx, target, manifestDesc)
 	if err != nil {
 		return "", "", err
 	}
@@ -119,11 +145,11 @@ func (c *registryClient) fetchOCIFeature(ctx context.Context, registry, reposito
 	if err != nil {
 		return "", "", err
 	}
-	blob, err := content.FetchAll(ctx, repo, layer)
+	blob, err := content.FetchAll(ctx, target, layer)
 	if err != nil {
 		return "", "", err
 	}
-	dir, err := extractFeatureArchive(blob)
+	dir, err := c.extract(blob)
 	if err != nil {
 		return "", "", err
 	}
@@ -226,11 +252,72 @@ func dockerConfigPath() string {
 	return filepath.Join(home, ".docker", "config.json")
 }
 
-func extractFeatureArchive(data []byte) (string, error) {
-	root, err := os.MkdirTemp("", "godev-feature-*")
+// featureExtractionSentinel is written into a fully extracted feature and holds the archive checksum.
+const featureExtractionSentinel = ".godev-extracted"
+
+// partialExtractionSuffix marks an extraction directory that is still being written.
+const partialExtractionSuffix = ".partial"
+
+// extract unpacks a downloaded feature and records the directory so it can be removed after the build.
+// An archive this client already extracted is reused as long as its sentinel still matches.
+func (c *registryClient) extract(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	checksum := fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:]))
+	if root, ok := c.extractions[checksum]; ok {
+		if dir, err := trustedFeatureExtraction(root, checksum); err == nil {
+			return dir, nil
+		}
+	}
+	root, err := extractFeatureAtomically(data, c.tempDir, checksum)
+	if err != nil {
+		return "", err
+	}
+	c.extracted = append(c.extracted, root)
+	c.extractions[checksum] = root
+	return findFeatureRoot(root)
+}
+
+// extractFeatureAtomically unpacks data into a ".partial" directory under parent ("" uses the OS default),
+// writes the checksum sentinel, and only then renames it to its final name, so an interrupted extraction
+// never looks complete. A failed extraction is removed.
+func extractFeatureAtomically(data []byte, parent, checksum string) (string, error) {
+	staging, err := os.MkdirTemp(parent, "godev-feature-*"+partialExtractionSuffix)
 	if err != nil {
 		return "", err
 	}
+	if err := writeFeatureExtraction(data, staging, checksum); err != nil {
+		_ = os.RemoveAll(staging)
+		return "", err
+	}
+	root := strings.TrimSuffix(staging, partialExtractionSuffix)
+	if err := os.Rename(staging, root); err != nil {
+		_ = os.RemoveAll(staging)
+		return "", err
+	}
+	return root, nil
+}
+
+func writeFeatureExtraction(data []byte, root, checksum string) error {
+	if _, err := extractFeatureArchive(data, root); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, featureExtractionSentinel), []byte(checksum), 0o644)
+}
+
+// trustedFeatureExtraction returns the feature directory in root when its sentinel records checksum.
+func trustedFeatureExtraction(root, checksum string) (string, error) {
+	recorded, err := os.ReadFile(filepath.Join(root, featureExtractionSentinel))
+	if err != nil {
+		return "", fmt.Errorf("feature extraction %s is incomplete: %w", root, err)
+	}
+	if string(recorded) != checksum {
+		return "", fmt.Errorf("feature extraction %s does not match %s", root, checksum)
+	}
+	return findFeatureRoot(root)
+}
+
+// extractFeatureArchive unpacks a feature tarball into root and returns the directory holding devcontainer-feature.json.
+func extractFeatureArchive(data []byte, root string) (string, error) {
 	reader := bytes.NewReader(data)
 	var tarReader *tar.Reader
 	if gz, err := gzip.NewReader(reader); err == nil {
@@ -315,7 +402,10 @@ func findFeatureRoot(root string) (string, error) {
 		if entry.IsDir() {
 			return nil
 		}
-		if entry.Name() != "devcontainer-feature.json" {
+		if !isFeatureMetadataFileName(entry.Name()) {
+			return nil
+		}
+		if candidate == filepath.Dir(path) {
 			return nil
 		}
 		if candidate != "" {
@@ -3,6 +3,8 @@ package godev
 import (
 	"context"
 	"fmt"
+
+	"github.com/compose-spec/compose-go/types"
 )
 
 var lifecycleOrder = []string{
@@ -13,28 +15,9 @@ var lifecycleOrder = []string{
 	"postAttachCommand",
 }
 
-func runFeatureEntrypoints(ctx context.Context, features []*ResolvedFeature, vars map[string]string, runner lifecycleRunner) error {
-	if len(features) == 0 {
-		return nil
-	}
-	for _, feature := range features {
-		entrypoint, err := featureEntrypointPath(feature, vars)
-		if err != nil {
-			return err
-		}
-		if entrypoint == "" {
-			continue
-		}
-		command := LifecycleCommand{Shell: entrypoint}
-		name := fmt.Sprintf("featureEntrypoint:%s", feature.Metadata.ID)
-		if err := runner(ctx, name, command); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func runLifecycleWithFeatures(ctx context.Context, features *ResolvedFeatures, userHooks map[string]*LifecycleCommands, runner lifecycleRunner) error {
+// runLifecycleWithFeatures runs feature and user hooks in lifecycle order on the primary runner.
+// User hooks listed in serviceRunners are additionally run on each of those runners after the primary.
+func runLifecycleWithFeatures(ctx context.Context, features *ResolvedFeatures, userHooks map[string]*LifecycleCommands, runner lifecycleRunner, serviceRunners map[string][]lifecycleRunner) error {
 	if len(userHooks) == 0 && (features == nil || len(features.Order) == 0) {
 		return nil
 	}
@@ -50,6 +33,46 @@ func runLifecycleWithFeatures(ctx context.Context, features *ResolvedFeatures, u
 			if err := runLifecycleCommands(ctx, hook, commands, runner); err != nil {
 				return err
 			}
+			for _, serviceRunner := range serviceRunners[hook] {
+				if err := runLifecycleCommands(ctx, hook, commands, serviceRunner); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasLifecycleCommands reports whether any feature or user lifecycle command will run in the container.
+func hasLifecycleCommands(features *ResolvedFeatures, userHooks map[string]*LifecycleCommands) bool {
+	for _, hook := range lifecycleOrder {
+		if commands := userHooks[hook]; commands != nil && !commands.IsZero() {
+			return true
+		}
+		if features == nil {
+			continue
+		}
+		for _, feature := range features.Order {
+			if commands := featureLifecycleCommands(hook, feature); commands != nil && !commands.IsZero() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateHookServices(hookServices map[string][]string, project *types.Project, runServices []string) error {
+	for hook, services := range hookServices {
+		if !containsString(lifecycleOrder, hook) {
+			return fmt.Errorf("unsupported lifecycle hook for services: %s", hook)
+		}
+		for _, service := range services {
+			if _, err := findComposeService(project, service); err != nil {
+				return err
+			}
+			if len(runServices) > 0 && !containsString(runServices, service) {
+				return fmt.Errorf("service %s for %s is not in runServices", service, hook)
+			}
 		}
 	}
 	return nil
@@ -1,9 +1,30 @@
 package godev
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
 )
 
 func TestResolveFeatureOptions(t *testing.T) {
@@ -57,7 +78,7 @@ func TestOrderFeatures_DependsOnInstallsAfter(t *testing.T) {
 		Options:          ResolvedFeatureOptions{UserValues: map[string]string{}},
 		CanonicalName:    "baz@sha",
 	}
-	order, err := orderFeatures([]*ResolvedFeature{bar, baz, foo}, nil)
+	order, err := orderFeatures([]*ResolvedFeature{bar, baz, foo}, nil, "")
 	if err != nil {
 		t.Fatalf("orderFeatures: %v", err)
 	}
@@ -68,7 +89,7 @@ func TestOrderFeatures_DependsOnInstallsAfter(t *testing.T) {
 			t.Fatalf("unexpected order: %#v", got)
 		}
 	}
-	override, err := orderFeatures([]*ResolvedFeature{bar, baz, foo}, []string{"baz"})
+	override, err := orderFeatures([]*ResolvedFeature{bar, baz, foo}, []string{"baz"}, "")
 	if err != nil {
 		t.Fatalf("orderFeatures override: %v", err)
 	}
@@ -77,6 +98,107 @@ func TestOrderFeatures_DependsOnInstallsAfter(t *testing.T) {
 	}
 }
 
+func TestOrderFeatures_GlobOverride(t *testing.T) {
+	newFeature := func(baseName string) *ResolvedFeature {
+		return &ResolvedFeature{
+			DependencyKey: baseName + "-key",
+			BaseName:      baseName,
+			Tag:           "1",
+			Options:       ResolvedFeatureOptions{UserValues: map[string]string{}},
+			CanonicalName: baseName + "@sha",
+		}
+	}
+	features := []*ResolvedFeature{
+		newFeature("example.com/tools/alpha"),
+		newFeature("ghcr.io/devcontainers/features/node"),
+		newFeature("ghcr.io/devcontainers/features/go"),
+		newFeature("example.com/tools/zeta"),
+	}
+
+	order, err := orderFeatures(features, []string{"example.com/tools/zeta", "ghcr.io/devcontainers/features/*"}, "")
+	if err != nil {
+		t.Fatalf("orderFeatures: %v", err)
+	}
+	got := make([]string, 0, len(order))
+	for _, feature := range order {
+		got = append(got, feature.BaseName)
+	}
+	expected := []string{
+		"example.com/tools/zeta",
+		"ghcr.io/devcontainers/features/go",
+		"ghcr.io/devcontainers/features/node",
+		"example.com/tools/alpha",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected order: %#v", got)
+	}
+
+	if _, err := orderFeatures(features, []string{"ghcr.io/other/*"}, ""); err == nil || !strings.Contains(err.Error(), "matches no feature") {
+		t.Fatalf("expected unmatched pattern error, got %v", err)
+	}
+	if _, err := orderFeatures(features, []string{"ghcr.io/[/*"}, ""); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+}
+
+func TestOrderFeatures_Strategies(t *testing.T) {
+	newFeature := func(baseName string) *ResolvedFeature {
+		return &ResolvedFeature{
+			DependencyKey: baseName + "-key",
+			BaseName:      baseName,
+			Tag:           "1",
+			Options:       ResolvedFeatureOptions{UserValues: map[string]string{}},
+			CanonicalName: baseName + "@sha",
+		}
+	}
+	alpha := newFeature("alpha")
+	alpha.InstallsAfterIDs = []string{"zeta"}
+	beta := newFeature("beta")
+	beta.DependsOnKeys = []string{"zeta-key"}
+	// Resolution order: config IDs sorted, with beta's dependency zeta right after it.
+	features := []*ResolvedFeature{alpha, beta, newFeature("zeta"), newFeature("mid")}
+	names := func(order []*ResolvedFeature) []string {
+		got := make([]string, 0, len(order))
+		for _, feature := range order {
+			got = append(got, feature.BaseName)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name     string
+		strategy FeatureOrderStrategy
+		override []string
+		want     []string
+	}{
+		{name: "default", want: []string{"mid", "zeta", "alpha", "beta"}},
+		{name: "spec", strategy: FeatureOrderSpec, want: []string{"mid", "zeta", "alpha", "beta"}},
+		{name: "topological", strategy: FeatureOrderTopological, want: []string{"alpha", "zeta", "beta", "mid"}},
+		{name: "topological override", strategy: FeatureOrderTopological, override: []string{"mid"}, want: []string{"mid", "alpha", "zeta", "beta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := orderFeatures(features, tt.override, tt.strategy)
+			if err != nil {
+				t.Fatalf("orderFeatures: %v", err)
+			}
+			if got := names(order); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unexpected order: %#v", got)
+			}
+		})
+	}
+
+	cycle := []*ResolvedFeature{newFeature("a"), newFeature("b")}
+	cycle[0].DependsOnKeys = []string{"b-key"}
+	cycle[1].DependsOnKeys = []string{"a-key"}
+	if _, err := orderFeatures(cycle, nil, FeatureOrderTopological); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	if _, err := applyStartOptions([]StartOption{WithFeatureOrderStrategy("random")}); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
 func TestResolveFeatures_Local(t *testing.T) {
 	root := t.TempDir()
 	copyTestcaseDir(t, root, "features", "deps")
@@ -90,7 +212,7 @@ func TestResolveFeatures_Local(t *testing.T) {
 	if err != nil {
 		t.Fatalf("resolveWorkspacePaths: %v", err)
 	}
-	resolved, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg)
+	resolved, err := resolveFeatures(context.Background(), configPath, workspaceRoot, cfg, nil, "", "")
 	if err != nil {
 		t.Fatalf("resolveFeatures: %v", err)
 	}
@@ -102,6 +224,739 @@ func TestResolveFeatures_Local(t *testing.T) {
 	}
 }
 
+// serveFeatureTarball serves a gzipped feature tarball with the given files at /feature.tgz.
+func serveFeatureTarball(t *testing.T, files map[string]string) string {
+	t.Helper()
+	data := featureTarball(t, files)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/feature.tgz"
+}
+
+// featureTarball builds a gzipped feature tarball with the given files.
+func featureTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("tar write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestResolveFeatures_FromOCILayout(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	configDir := filepath.Join(root, ".devcontainer")
+	store, err := oci.New(filepath.Join(configDir, "layout"))
+	if err != nil {
+		t.Fatalf("oci layout: %v", err)
+	}
+	data := featureTarball(t, map[string]string{
+		"devcontainer-feature.json": `{"id":"offline","version":"1.0.0","name":"Offline"}`,
+		"install.sh":                "#!/bin/sh\n",
+	})
+	layer := content.NewDescriptorFromBytes("application/vnd.devcontainers.layer.v1+tar", data)
+	if err := store.Push(ctx, layer, bytes.NewReader(data)); err != nil {
+		t.Fatalf("push layer: %v", err)
+	}
+	manifest, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, "application/vnd.devcontainers", oras.PackManifestOptions{Layers: []ocispec.Descriptor{layer}})
+	if err != nil {
+		t.Fatalf("pack manifest: %v", err)
+	}
+	if err := store.Tag(ctx, manifest, "1.0"); err != nil {
+		t.Fatalf("tag manifest: %v", err)
+	}
+
+	cfg := &DevcontainerConfig{Features: FeatureSet{"oci:layout:1.0": FeatureOptions{}}}
+	resolved, err := resolveFeatures(ctx, filepath.Join(configDir, "devcontainer.json"), root, cfg, nil, "", "")
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+	defer resolved.removeTempDirs()
+	if len(resolved.Order) != 1 {
+		t.Fatalf("expected one feature, got %#v", resolved.Order)
+	}
+	feature := resolved.Order[0]
+	if feature.Metadata.ID != "offline" || feature.Tag != "1.0" || feature.CanonicalName != "oci:layout@"+manifest.Digest.String() {
+		t.Fatalf("unexpected feature: %#v", feature)
+	}
+	if _, err := os.Stat(filepath.Join(feature.FeatureDir, "install.sh")); err != nil {
+		t.Fatalf("expected extracted install.sh: %v", err)
+	}
+
+	cfg = &DevcontainerConfig{Features: FeatureSet{"oci:layout:2.0": FeatureOptions{}}}
+	if _, err := resolveFeatures(ctx, filepath.Join(configDir, "devcontainer.json"), root, cfg, nil, "", ""); err == nil {
+		t.Fatal("expected error for missing layout tag")
+	}
+}
+
+func TestResolveFeatures_ExtractsUnderTempDir(t *testing.T) {
+	url := serveFeatureTarball(t, map[string]string{
+		"devcontainer-feature.json": `{"id":"remote","version":"1.0.0","name":"Remote"}`,
+		"install.sh":                "#!/bin/sh\n",
+	})
+	root := t.TempDir()
+	tempDir := t.TempDir()
+	cfg := &DevcontainerConfig{Features: FeatureSet{url: FeatureOptions{}}}
+	resolved, err := resolveFeatures(context.Background(), filepath.Join(root, "devcontainer.json"), root, cfg, nil, tempDir, "")
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+	if len(resolved.Order) != 1 || filepath.Dir(resolved.Order[0].FeatureDir) != tempDir {
+		t.Fatalf("expected feature to be extracted under %s: %#v", tempDir, resolved.Order)
+	}
+	if _, err := os.Stat(filepath.Join(resolved.Order[0].FeatureDir, "install.sh")); err != nil {
+		t.Fatalf("expected install.sh in extracted feature: %v", err)
+	}
+}
+
+func TestRegistryClientExtract_DiscardsPartialExtraction(t *testing.T) {
+	data := featureTarball(t, map[string]string{
+		"devcontainer-feature.json": `{"id":"remote","version":"1.0.0","name":"Remote"}`,
+		"install.sh":                strings.Repeat("echo install\n", 512),
+	})
+	tempDir := t.TempDir()
+	client := newRegistryClient(tempDir)
+	if _, err := client.extract(data[:len(data)/2]); err == nil {
+		t.Fatal("expected truncated archive to fail")
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("read temp dir: %v", err)
+	}
+	if len(entries) != 0 || len(client.extracted) != 0 || len(client.extractions) != 0 {
+		t.Fatalf("expected no extraction to survive a failure, got %v", entries)
+	}
+
+	partial := t.TempDir()
+	if _, err := extractFeatureArchive(data, partial); err != nil {
+		t.Fatalf("extractFeatureArchive: %v", err)
+	}
+	if _, err := trustedFeatureExtraction(partial, "sha256:any"); err == nil {
+		t.Fatal("expected an extraction without a sentinel to be untrusted")
+	}
+
+	first, err := client.extract(data)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	again, err := client.extract(data)
+	if err != nil || again != first {
+		t.Fatalf("expected completed extraction to be reused, got %s (%v)", again, err)
+	}
+	if err := os.Remove(filepath.Join(first, featureExtractionSentinel)); err != nil {
+		t.Fatalf("remove sentinel: %v", err)
+	}
+	redone, err := client.extract(data)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if redone == first {
+		t.Fatal("expected an extraction without its sentinel to be extracted again")
+	}
+}
+
+func TestStartDevcontainer_FeatureResolveTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	root := t.TempDir()
+	configPath := filepath.Join(root, "devcontainer.json")
+	config := fmt.Sprintf(`{"image": "alpine:3.19", "features": {%q: {}}}`, server.URL+"/feature.tgz")
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	started := time.Now()
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithoutPreflight(), WithFeatureResolveTimeout(200*time.Millisecond))
+	if err == nil {
+		t.Fatal("expected feature resolution to time out")
+	}
+	if !strings.Contains(err.Error(), "feature resolution timed out after 200ms") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("expected the phase to fail promptly, took %s", elapsed)
+	}
+}
+
+func TestResolveFeatures_RejectsCaseVariantIDs(t *testing.T) {
+	cfg := &DevcontainerConfig{Features: FeatureSet{
+		"ghcr.io/Acme/features/Go:1": {},
+		"ghcr.io/acme/features/go:1": {},
+	}}
+	root := t.TempDir()
+	_, err := resolveFeatures(context.Background(), filepath.Join(root, "devcontainer.json"), root, cfg, nil, "", "")
+	if err == nil {
+		t.Fatal("expected case-variant feature IDs to be rejected")
+	}
+	if !strings.Contains(err.Error(), "ghcr.io/Acme/features/Go:1") || !strings.Contains(err.Error(), "ghcr.io/acme/features/go:1") {
+		t.Fatalf("expected both IDs in error, got %v", err)
+	}
+
+	if err := validateFeatureIDCollisions([]string{"ghcr.io/acme/features/go:1", "ghcr.io/acme/features/node:1"}); err != nil {
+		t.Fatalf("distinct IDs: %v", err)
+	}
+}
+
+func TestResolveFeatures_CycleNamesChain(t *testing.T) {
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "features", "cycle")
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	_, err = resolveFeatures(context.Background(), configPath, root, cfg, nil, "", "")
+	if err == nil {
+		t.Fatal("expected dependency cycle error")
+	}
+	if !strings.Contains(err.Error(), "./featureA -> ./featureB -> ./featureA") {
+		t.Fatalf("expected cycle chain in error, got %v", err)
+	}
+}
+
+func TestResolveFeatures_LegacyMetadataFileName(t *testing.T) {
+	root := t.TempDir()
+	for name, file := range map[string]string{"legacy": "devcontainer-features.json", "upper": "Devcontainer-Feature.json"} {
+		dir := filepath.Join(root, ".devcontainer", name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		metadata := `{"id":"` + name + `","version":"1.0.0","name":"` + name + `"}`
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(metadata), 0o644); err != nil {
+			t.Fatalf("write metadata: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "install.sh"), []byte("#!/bin/sh\n"), 0o644); err != nil {
+			t.Fatalf("write install.sh: %v", err)
+		}
+	}
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	cfg := &DevcontainerConfig{Features: FeatureSet{"./legacy": FeatureOptions{}, "./upper": FeatureOptions{}}}
+	resolved, err := resolveFeatures(context.Background(), configPath, root, cfg, nil, "", "")
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+	if len(resolved.Order) != 2 || resolved.Order[0].Metadata.ID != "legacy" || resolved.Order[1].Metadata.ID != "upper" {
+		t.Fatalf("unexpected resolved features: %#v", resolved.Order)
+	}
+}
+
+func TestResolveFeatures_ExpandsLocalEnvOptions(t *testing.T) {
+	t.Setenv("GODEV_TEST_FEATURE_TOKEN", "secret-token")
+	root := t.TempDir()
+	dir := filepath.Join(root, ".devcontainer", "token")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	metadata := `{"id":"token","version":"1.0.0","name":"token","options":{"token":{"type":"string","default":""}}}`
+	if err := os.WriteFile(filepath.Join(dir, "devcontainer-feature.json"), []byte(metadata), 0o644); err != nil {
+		t.Fatalf("write metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "install.sh"), []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("write install.sh: %v", err)
+	}
+	value := "${localEnv:GODEV_TEST_FEATURE_TOKEN}"
+	cfg := &DevcontainerConfig{Features: FeatureSet{"./token": FeatureOptions{"token": {String: &value}}}}
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	resolved, err := resolveFeatures(context.Background(), configPath, root, cfg, nil, "", "")
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+	if got := resolved.Order[0].Options.Values["token"]; got != "secret-token" {
+		t.Fatalf("expected expanded option value, got %q", got)
+	}
+	want := hashFeatureOptions(map[string]string{"token": "secret-token"})
+	if !strings.HasSuffix(resolved.Order[0].DependencyKey, ":"+want) {
+		t.Fatalf("expected dependency key hashed from the expanded value, got %s", resolved.Order[0].DependencyKey)
+	}
+}
+
+func TestFeatureMetadataPath_PrefersCanonicalName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"devcontainer-features.json", "devcontainer-feature.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	got, err := featureMetadataPath(dir)
+	if err != nil {
+		t.Fatalf("featureMetadataPath: %v", err)
+	}
+	if filepath.Base(got) != "devcontainer-feature.json" {
+		t.Fatalf("expected canonical metadata file, got %s", got)
+	}
+	if _, err := featureMetadataPath(t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not-exist error for a directory without metadata, got %v", err)
+	}
+}
+
+func TestOrderFeatures_CycleNamesMembers(t *testing.T) {
+	foo := &ResolvedFeature{DependencyKey: "foo-key", BaseName: "foo", InstallsAfterIDs: []string{"bar"}}
+	bar := &ResolvedFeature{DependencyKey: "bar-key", BaseName: "bar", InstallsAfterIDs: []string{"foo"}}
+	baz := &ResolvedFeature{DependencyKey: "baz-key", BaseName: "baz"}
+
+	_, err := orderFeatures([]*ResolvedFeature{foo, bar, baz}, nil, "")
+	if err == nil {
+		t.Fatal("expected dependency cycle error")
+	}
+	expected := "feature dependency cycle detected: bar waits on foo; foo waits on bar"
+	if err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyFeatureVersions(t *testing.T) {
+	node := func(tag, digest string) *ResolvedFeature {
+		return &ResolvedFeature{
+			BaseName:      "ghcr.io/devcontainers/features/node",
+			Tag:           tag,
+			CanonicalName: "ghcr.io/devcontainers/features/node@" + digest,
+		}
+	}
+	conflicting := &ResolvedFeatures{Order: []*ResolvedFeature{node("1", "sha256:aaa"), node("2", "sha256:bbb")}}
+	if err := verifyFeatureVersions(conflicting, false); err != nil {
+		t.Fatalf("non-strict mode should allow both versions: %v", err)
+	}
+	err := verifyFeatureVersions(conflicting, true)
+	if err == nil {
+		t.Fatal("expected conflicting versions error")
+	}
+	if !strings.Contains(err.Error(), "ghcr.io/devcontainers/features/node") || !strings.Contains(err.Error(), "1 and 2") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sameVersion := &ResolvedFeatures{Order: []*ResolvedFeature{node("1", "sha256:aaa"), node("1", "sha256:aaa")}}
+	if err := verifyFeatureVersions(sameVersion, true); err != nil {
+		t.Fatalf("same version with different options should be allowed: %v", err)
+	}
+}
+
 func stringPtr(value string) *string {
 	return &value
 }
+
+func TestImageDefaultUser_UsesPlatformConfig(t *testing.T) {
+	users := map[string]string{
+		"":             "amd-user",
+		"linux/arm64":  "arm-user",
+		"linux/arm/v7": "",
+	}
+	cli := newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/images/base:latest/json") {
+			http.NotFound(w, r)
+			return
+		}
+		platform := ""
+		if raw := r.URL.Query().Get("platform"); raw != "" {
+			var decoded struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			}
+			if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			platform = strings.TrimSuffix(decoded.OS+"/"+decoded.Architecture+"/"+decoded.Variant, "/")
+		}
+		_ = json.NewEncoder(w).Encode(image.InspectResponse{Config: &dockerspec.DockerOCIImageConfig{ImageConfig: ocispec.ImageConfig{User: users[platform]}}})
+	})
+
+	tests := []struct {
+		platform string
+		want     string
+	}{
+		{platform: "", want: "amd-user"},
+		{platform: "linux/arm64", want: "arm-user"},
+		{platform: "linux/arm/v7", want: "root"},
+	}
+	for _, tt := range tests {
+		platform, err := parsePlatform(tt.platform)
+		if err != nil {
+			t.Fatalf("parsePlatform(%q): %v", tt.platform, err)
+		}
+		got, err := imageDefaultUser(context.Background(), cli, "base:latest", platform)
+		if err != nil {
+			t.Fatalf("imageDefaultUser(%q): %v", tt.platform, err)
+		}
+		if got != tt.want {
+			t.Fatalf("imageDefaultUser(%q) = %q, want %q", tt.platform, got, tt.want)
+		}
+	}
+	if _, err := parsePlatform("arm64"); err == nil {
+		t.Fatal("expected invalid platform error")
+	}
+}
+
+func TestConsumeFeatureBuildOutput_LogsPerFeature(t *testing.T) {
+	features := []*ResolvedFeature{
+		{CanonicalName: "ghcr.io/acme/go", ImageDir: "/tmp/dev-container-features/go_0"},
+		{CanonicalName: "ghcr.io/acme/node", ImageDir: "/tmp/dev-container-features/node_1"},
+	}
+	messages := []string{
+		`{"stream":"Step 1/4 : FROM base\n"}`,
+		`{"stream":"Step 2/4 : RUN set -e; cd /tmp/dev-container-features/go_0; ./install.sh\n"}`,
+		`{"stream":"installing go\n"}`,
+		`{"stream":"Step 3/4 : RUN set -e; cd /tmp/dev-container-features/node_1; ./install.sh\n"}`,
+		`{"stream":"installing node\n"}`,
+		`{"stream":"Step 4/4 : LABEL done=true\n"}`,
+	}
+	logs := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+	output := &bytes.Buffer{}
+	if err := consumeFeatureBuildOutput(strings.NewReader(strings.Join(messages, "\n")), features, logger, output); err != nil {
+		t.Fatalf("consume build output: %v", err)
+	}
+
+	records := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	expected := []string{
+		`msg="installing feature" feature=ghcr.io/acme/go`,
+		`msg="feature installed" feature=ghcr.io/acme/go duration=`,
+		`msg="installing feature" feature=ghcr.io/acme/node`,
+		`msg="feature installed" feature=ghcr.io/acme/node duration=`,
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("unexpected log records: %q", records)
+	}
+	for i, want := range expected {
+		if !strings.Contains(records[i], want) {
+			t.Fatalf("record %d = %q, want %q", i, records[i], want)
+		}
+	}
+	if !strings.Contains(output.String(), "[ghcr.io/acme/go] installing go\n") {
+		t.Fatalf("expected go output to be prefixed: %q", output.String())
+	}
+	if !strings.Contains(output.String(), "[ghcr.io/acme/node] installing node\n") {
+		t.Fatalf("expected node output to be prefixed: %q", output.String())
+	}
+	if !strings.Contains(output.String(), "\nStep 4/4 : LABEL done=true\n") {
+		t.Fatalf("expected trailing step unprefixed: %q", output.String())
+	}
+}
+
+func TestConsumeFeatureBuildOutput_ReturnsBuildError(t *testing.T) {
+	stream := `{"stream":"Step 1/1 : RUN false\n"}` + "\n" + `{"error":"exit code 1"}`
+	err := consumeFeatureBuildOutput(strings.NewReader(stream), nil, slog.New(slog.DiscardHandler), nil)
+	if err == nil || !strings.Contains(err.Error(), "exit code 1") {
+		t.Fatalf("expected build error, got %v", err)
+	}
+}
+
+func TestBuildFeaturesDockerfile_InstallUser(t *testing.T) {
+	features := []*ResolvedFeature{
+		{Metadata: FeatureMetadata{ID: "go"}, ImageDir: "/tmp/dev-container-features/01-go"},
+		{Metadata: FeatureMetadata{ID: "node", InstallUser: "remoteUser"}, ImageDir: "/tmp/dev-container-features/02-node"},
+	}
+	userEnv := featureUserEnv(&DevcontainerConfig{RemoteUser: "vscode"}, "root")
+	dockerfile, err := buildFeaturesDockerfile("base", "root", features, nil, userEnv)
+	if err != nil {
+		t.Fatalf("buildFeaturesDockerfile: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(dockerfile), "\n")
+	expected := []string{
+		"FROM base",
+		"USER root",
+		"WORKDIR /",
+		"COPY features/ " + featureImageBaseDir + "/",
+		"RUN " + featureInstallCommand(features[0], nil),
+		"RUN chown -R vscode /tmp/dev-container-features/02-node",
+		"USER vscode",
+		"RUN " + featureInstallCommand(features[1], nil),
+		"USER root",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected dockerfile:\n%s", dockerfile)
+	}
+
+	for _, user := range []string{"dev user", "vscode\nRUN rm -rf /", "$(id -un)"} {
+		userEnv := featureUserEnv(&DevcontainerConfig{RemoteUser: user}, "root")
+		if _, err := buildFeaturesDockerfile("base", "root", features, nil, userEnv); err == nil || !strings.Contains(err.Error(), "invalid install user") {
+			t.Fatalf("expected install user %q to be rejected, got %v", user, err)
+		}
+	}
+	if _, err := buildFeaturesDockerfile("base", "1000:1000", features[:1], nil, nil); err != nil {
+		t.Fatalf("expected a uid:gid image user to be accepted, got %v", err)
+	}
+	if _, err := buildFeaturesDockerfile("base", "dev user", features[:1], nil, nil); err == nil {
+		t.Fatal("expected an image user with a space to be rejected")
+	}
+}
+
+func TestFeatureEntrypointScript_ChainsInOrder(t *testing.T) {
+	features := []*ResolvedFeature{
+		{Metadata: FeatureMetadata{ID: "docker-in-docker", Entrypoint: "/usr/local/share/docker-init.sh"}, ImageDir: "/features/01-docker"},
+		{Metadata: FeatureMetadata{ID: "go"}, ImageDir: "/features/02-go"},
+		{Metadata: FeatureMetadata{ID: "sshd", Entrypoint: "start.sh"}, ImageDir: "/features/03-sshd"},
+	}
+	script, err := featureEntrypointScript(features, nil)
+	if err != nil {
+		t.Fatalf("featureEntrypointScript: %v", err)
+	}
+	expected := "#!/bin/sh\n/usr/local/share/docker-init.sh\n/features/03-sshd/start.sh\nexec \"$@\"\n"
+	if script != expected {
+		t.Fatalf("unexpected script:\n%s", script)
+	}
+	dockerfile, err := buildFeaturesDockerfile("base", "root", features, nil, nil)
+	if err != nil {
+		t.Fatalf("buildFeaturesDockerfile: %v", err)
+	}
+	if !strings.HasSuffix(dockerfile, "RUN chmod +x "+featureEntrypointWrapper+"\n") {
+		t.Fatalf("expected wrapper to be made executable:\n%s", dockerfile)
+	}
+
+	script, err = featureEntrypointScript(features[1:2], nil)
+	if err != nil || script != "" {
+		t.Fatalf("expected no wrapper without entrypoints, got %q, %v", script, err)
+	}
+}
+
+func TestFeatureGraph_DependsOnEdges(t *testing.T) {
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "features", "deps")
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+
+	dot, err := FeatureGraph(context.Background(), configPath)
+	if err != nil {
+		t.Fatalf("FeatureGraph: %v", err)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	resolved, err := resolveFeatures(context.Background(), configPath, root, cfg, nil, "", "")
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+	featureB, featureA := resolved.Order[0], resolved.Order[1]
+	edge := dotQuote(featureA.DependencyKey) + " -> " + dotQuote(featureB.DependencyKey) + ` [label="dependsOn"];`
+	if !strings.HasPrefix(dot, "digraph features {\n") || !strings.Contains(dot, edge) {
+		t.Fatalf("expected dependsOn edge %s in:\n%s", edge, dot)
+	}
+}
+
+func TestRenderFeatureGraph_InstallsAfterEdges(t *testing.T) {
+	foo := &ResolvedFeature{DependencyKey: "foo-key", BaseName: "foo", CanonicalName: "foo@sha", InstallsAfterKeys: []string{"bar-key"}}
+	bar := &ResolvedFeature{DependencyKey: "bar-key", BaseName: "bar", CanonicalName: "bar@sha", InstallsAfterKeys: []string{"foo-key"}}
+
+	dot := renderFeatureGraph([]*ResolvedFeature{foo, bar})
+	expected := `digraph features {
+  "bar-key" [label="bar@sha"];
+  "foo-key" [label="foo@sha"];
+  "bar-key" -> "foo-key" [label="installsAfter", style=dashed];
+  "foo-key" -> "bar-key" [label="installsAfter", style=dashed];
+}
+`
+	if dot != expected {
+		t.Fatalf("unexpected graph:\n%s", dot)
+	}
+}
+
+func TestWriteFeatureBuildContext_DumpsFeatureEnv(t *testing.T) {
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "features", "local")
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	resolved, err := resolveFeatures(context.Background(), configPath, root, cfg, nil, "", "")
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+
+	dumpDir := filepath.Join(t.TempDir(), "feature-env")
+	options, err := applyStartOptions([]StartOption{WithDumpFeatureEnv(dumpDir)})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	contextDir := t.TempDir()
+	if err := writeFeatureBuildContext(contextDir, "alpine:3.19", "root", cfg, resolved.Order, nil, options); err != nil {
+		t.Fatalf("writeFeatureBuildContext: %v", err)
+	}
+
+	dumped, err := os.ReadFile(filepath.Join(dumpDir, "01-feature-a.env"))
+	if err != nil {
+		t.Fatalf("read dumped env: %v", err)
+	}
+	expected := `FLAG="true"
+MESSAGE="hello"
+_CONTAINER_USER="root"
+_CONTAINER_USER_HOME="/root"
+_REMOTE_USER="root"
+_REMOTE_USER_HOME="/root"
+`
+	if string(dumped) != expected {
+		t.Fatalf("unexpected dumped env:\n%s", dumped)
+	}
+	inContext, err := os.ReadFile(filepath.Join(contextDir, "features", "01-feature-a", "devcontainer-features.env"))
+	if err != nil {
+		t.Fatalf("read context env: %v", err)
+	}
+	if string(inContext) != expected {
+		t.Fatalf("dumped env differs from build context:\n%s", inContext)
+	}
+}
+
+func TestSharedFeaturesImageTag_ReusedAcrossWorkspaces(t *testing.T) {
+	url := serveFeatureTarball(t, map[string]string{
+		"devcontainer-feature.json": `{"id":"remote","version":"1.0.0","name":"Remote"}`,
+		"install.sh":                "#!/bin/sh\n",
+	})
+	cfg := &DevcontainerConfig{Features: FeatureSet{url: FeatureOptions{}}}
+	var shared, scoped []string
+	for _, name := range []string{"api", "web"} {
+		root := filepath.Join(t.TempDir(), name)
+		if err := os.MkdirAll(root, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		resolved, err := resolveFeatures(context.Background(), filepath.Join(root, "devcontainer.json"), root, cfg, nil, t.TempDir(), "")
+		if err != nil {
+			t.Fatalf("resolveFeatures: %v", err)
+		}
+		shared = append(shared, sharedFeaturesImageTag("alpine:3.19", "root", cfg, resolved.Order))
+		scoped = append(scoped, featuresImageTag(root, devcontainerID(root, filepath.Join(root, "devcontainer.json")), resolved.Order))
+		if name == "web" && sharedFeaturesImageTag("debian:12", "root", cfg, resolved.Order) == shared[0] {
+			t.Fatal("expected a different base image to change the shared tag")
+		}
+	}
+	if shared[0] != shared[1] || !strings.HasPrefix(shared[0], "godev-features-") {
+		t.Fatalf("expected identical shared tags, got %v", shared)
+	}
+	if scoped[0] == scoped[1] {
+		t.Fatalf("expected default tags to stay per workspace, got %v", scoped)
+	}
+}
+
+func TestResolveFeatures_PathOptions(t *testing.T) {
+	root := t.TempDir()
+	featureDir := filepath.Join(root, ".devcontainer", "feature-a")
+	if err := os.MkdirAll(featureDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	metadata := `{"id":"feature-a","version":"1.0.0","name":"Feature A","options":{
+		"config":{"type":"path","default":"${localWorkspaceFolder}/defaults/app.yml"},
+		"cache":{"type":"fileString","default":""}}}`
+	if err := os.WriteFile(filepath.Join(featureDir, "devcontainer-feature.json"), []byte(metadata), 0o644); err != nil {
+		t.Fatalf("write metadata: %v", err)
+	}
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	vars := map[string]string{"localWorkspaceFolder": root}
+	resolve := func(options FeatureOptions) (*ResolvedFeatures, error) {
+		cfg := &DevcontainerConfig{Features: FeatureSet{"./feature-a": options}}
+		return resolveFeatures(context.Background(), configPath, root, cfg, vars, "", "")
+	}
+
+	resolved, err := resolve(FeatureOptions{})
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+	values := resolved.Order[0].Options.Values
+	if values["config"] != filepath.Join(root, "defaults", "app.yml") || values["cache"] != "" {
+		t.Fatalf("unexpected default path values: %#v", values)
+	}
+
+	relative := "conf/app.yml"
+	resolved, err = resolve(FeatureOptions{"config": {String: &relative}})
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+	if got := resolved.Order[0].Options.UserValues["config"]; got != filepath.Join(root, "conf", "app.yml") {
+		t.Fatalf("expected the relative path to resolve under the workspace, got %q", got)
+	}
+
+	for _, outside := range []string{"../secrets.txt", "/etc/passwd"} {
+		value := outside
+		if _, err := resolve(FeatureOptions{"cache": {String: &value}}); err == nil || !strings.Contains(err.Error(), "outside the workspace") {
+			t.Fatalf("expected %s to be rejected, got %v", outside, err)
+		}
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	escape := "escape/data"
+	if _, err := resolve(FeatureOptions{"cache": {String: &escape}}); err == nil {
+		t.Fatal("expected a symlink out of the workspace to be rejected")
+	}
+}
+
+func TestBuildFeaturesImage_SkipsUnchangedBuild(t *testing.T) {
+	root := t.TempDir()
+	copyTestcaseDir(t, root, "features", "local")
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	resolved, err := resolveFeatures(context.Background(), configPath, root, cfg, nil, "", "")
+	if err != nil {
+		t.Fatalf("resolveFeatures: %v", err)
+	}
+
+	builds := 0
+	baseID := "sha256:base-1"
+	var labels map[string]string
+	daemon := newFakeDaemon(t)
+	daemon.handle(http.MethodGet, "/json", func(w http.ResponseWriter, r *http.Request) {
+		if labels == nil || !strings.Contains(r.URL.Path, "-features-") {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"Id": "sha256:features", "Config": map[string]any{"Labels": labels}})
+	})
+	daemon.handle(http.MethodGet, "/images/alpine:3.19/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"Id":%q}`, baseID)
+	})
+	daemon.handle(http.MethodPost, "/build", func(w http.ResponseWriter, r *http.Request) {
+		builds++
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("labels")), &labels); err != nil {
+			t.Errorf("decode build labels: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"stream":"built\n"}`))
+	})
+	cli := daemon.cli
+
+	build := func() string {
+		t.Helper()
+		tag, err := buildFeaturesImage(context.Background(), cli, "alpine:3.19", "root", root, "app", cfg, resolved.Order, nil, defaultStartOptions())
+		if err != nil {
+			t.Fatalf("buildFeaturesImage: %v", err)
+		}
+		return tag
+	}
+	first := build()
+	if builds != 1 || labels[featuresCacheLabel] == "" {
+		t.Fatalf("expected a labeled build, got %d builds and labels %v", builds, labels)
+	}
+	if second := build(); second != first || builds != 1 {
+		t.Fatalf("expected the second start to reuse %s, got %s after %d builds", first, second, builds)
+	}
+	baseID = "sha256:base-2"
+	build()
+	if builds != 2 {
+		t.Fatalf("expected a new base image to rebuild, got %d builds", builds)
+	}
+}
@@ -0,0 +1,100 @@
+package godev
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+const (
+	// gitCloneLabel records the temporary clone a devcontainer was started from so RemoveDevcontainer can delete it;
+	// user labels may not set it.
+	gitCloneLabel = "devcontainer.git_clone"
+	// gitClonePrefix names clone directories; only directories with this prefix are ever removed via the label.
+	gitClonePrefix = "godev-git-"
+)
+
+// gitConfigSource is a repository holding devcontainer.json, as given to WithConfigFromGit.
+type gitConfigSource struct {
+	URL     string // URL is the repository URL passed to git clone.
+	Ref     string // Ref is the branch or tag to check out; empty uses the remote default branch.
+	Subpath string // Subpath is the directory searched for devcontainer.json, or the config file itself.
+}
+
+// cloneGitConfig shallow-clones source under tempDir ("" uses the OS default) and returns the clone directory and
+// the config path inside it. The clone is removed again when an error is returned.
+func cloneGitConfig(ctx context.Context, source gitConfigSource, tempDir string) (string, string, error) {
+	cloneDir, err := os.MkdirTemp(tempDir, gitClonePrefix+"*")
+	if err != nil {
+		return "", "", err
+	}
+	args := []string{"clone", "--depth", "1", "--quiet"}
+	if source.Ref != "" {
+		args = append(args, "--branch", source.Ref)
+	}
+	args = append(args, "--", source.URL, cloneDir)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.RemoveAll(cloneDir)
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", "", fmt.Errorf("git clone %s: %w: %s", source.URL, err, message)
+		}
+		return "", "", fmt.Errorf("git clone %s: %w", source.URL, err)
+	}
+	configPath, err := locateClonedConfig(cloneDir, source.Subpath)
+	if err != nil {
+		_ = os.RemoveAll(cloneDir)
+		return "", "", err
+	}
+	return cloneDir, configPath, nil
+}
+
+// locateClonedConfig resolves subpath inside cloneDir to a config file, searching a directory like FindConfigPath.
+func locateClonedConfig(cloneDir, subpath string) (string, error) {
+	target := cloneDir
+	if subpath != "" {
+		local := filepath.FromSlash(subpath)
+		if !filepath.IsLocal(local) {
+			return "", fmt.Errorf("git config subpath must stay inside the repository: %s", subpath)
+		}
+		target = filepath.Join(cloneDir, local)
+	}
+	stat, err := os.Stat(target)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", classifyError(ErrConfigNotFound, fmt.Errorf("git config subpath not found: %s", subpath))
+		}
+		return "", err
+	}
+	if !stat.IsDir() {
+		return target, nil
+	}
+	return FindConfigPath(target)
+}
+
+// gitCloneDir returns the clone recorded on the container, or "" when it has none or cannot be inspected.
+func gitCloneDir(ctx context.Context, cli *client.Client, containerID string) string {
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil || inspect.Config == nil {
+		return ""
+	}
+	return inspect.Config.Labels[gitCloneLabel]
+}
+
+// removeGitClone deletes a clone made by cloneGitConfig; other paths, such as a hand-edited label, are left alone.
+func removeGitClone(cloneDir string) error {
+	if cloneDir == "" || !filepath.IsAbs(cloneDir) || !strings.HasPrefix(filepath.Base(cloneDir), gitClonePrefix) {
+		return nil
+	}
+	return os.RemoveAll(cloneDir)
+}
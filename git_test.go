@@ -0,0 +1,93 @@
+package godev
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=godev", "-c", "user.email=godev@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+func TestStartDevcontainer_ConfigFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	work := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(work, "app", ".devcontainer"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeTestcaseFile(t, filepath.Join(work, "app", ".devcontainer", "devcontainer.json"), "config", "basic", "devcontainer.json")
+	gitRun(t, work, "init", "--quiet")
+	gitRun(t, work, "add", ".")
+	gitRun(t, work, "commit", "--quiet", "-m", "devcontainer")
+	bare := filepath.Join(t.TempDir(), "app.git")
+	gitRun(t, work, "clone", "--quiet", "--bare", work, bare)
+
+	var created struct {
+		container.Config
+		HostConfig container.HostConfig
+	}
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", &created)
+	daemon.respond(http.MethodPost, "/containers/container-123/stop", http.StatusNoContent, "")
+	daemon.respond(http.MethodDelete, "/containers/container-123", http.StatusNoContent, "")
+	daemon.handle(http.MethodGet, "/containers/container-123/json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"Id":     "container-123",
+			"Name":   "/devcontainer",
+			"Config": map[string]any{"Labels": created.Labels},
+		})
+	})
+	daemon.useAsDockerHost()
+
+	tempDir := t.TempDir()
+	_, err := StartDevcontainer(context.Background(), WithTempDir(tempDir), WithConfigFromGit("file://"+bare, "main", "app"))
+	if err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	cloneDir := created.Labels[gitCloneLabel]
+	if filepath.Dir(cloneDir) != tempDir {
+		t.Fatalf("expected clone under %s, got %q", tempDir, cloneDir)
+	}
+	wantConfig := filepath.Join(cloneDir, "app", ".devcontainer", "devcontainer.json")
+	if created.Labels["devcontainer.config_path"] != wantConfig {
+		t.Fatalf("unexpected config path label: %q", created.Labels["devcontainer.config_path"])
+	}
+	if len(created.HostConfig.Mounts) == 0 || created.HostConfig.Mounts[0].Source != filepath.Join(cloneDir, "app") {
+		t.Fatalf("expected the cloned workspace to be mounted, got %#v", created.HostConfig.Mounts)
+	}
+
+	if err := StopDevcontainer(context.Background(), "container-123", 0); err != nil {
+		t.Fatalf("StopDevcontainer: %v", err)
+	}
+	if _, err := os.Stat(cloneDir); err != nil {
+		t.Fatalf("expected clone to survive stop for a restart, got %v", err)
+	}
+	if err := RemoveDevcontainer(context.Background(), "container-123"); err != nil {
+		t.Fatalf("RemoveDevcontainer: %v", err)
+	}
+	if _, err := os.Stat(cloneDir); !os.IsNotExist(err) {
+		t.Fatalf("expected clone to be removed after remove, got %v", err)
+	}
+
+	if _, err := StartDevcontainer(context.Background(), WithTempDir(tempDir), WithConfigFromGit("file://"+bare, "main", "missing")); err == nil || !strings.Contains(err.Error(), "subpath not found") {
+		t.Fatalf("expected missing subpath error, got %v", err)
+	}
+	if entries, err := os.ReadDir(tempDir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected failed clones to be removed, got %v (%v)", entries, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"sort"
@@ -18,6 +19,8 @@ import (
 )
 
 // LifecycleCommand represents a lifecycle hook command in shell or exec form.
+// Variables such as ${containerWorkspaceFolder} are substituted verbatim, and workspace paths may contain
+// spaces, so quote them in Shell form ("cd \"${containerWorkspaceFolder}\"") or pass them as Exec items.
 type LifecycleCommand struct {
 	Shell string   // Shell is a shell-form command string.
 	Exec  []string // Exec is an argv-style command array.
@@ -105,6 +108,44 @@ func (c *LifecycleCommands) UnmarshalJSON(data []byte) error {
 	}
 }
 
+// MarshalJSON encodes LifecycleCommands in the string, array, or object form accepted by UnmarshalJSON.
+// Impact: Single commands become a string or array, and parallel commands become an object keyed by name.
+// Example:
+//
+//	data, err := json.Marshal(devcontainer.LifecycleCommands{Single: &devcontainer.LifecycleCommand{Shell: "echo hi"}})
+//
+// Similar: UnmarshalJSON performs the reverse conversion from devcontainer.json.
+func (c LifecycleCommands) MarshalJSON() ([]byte, error) {
+	if c.Single != nil {
+		return c.Single.MarshalJSON()
+	}
+	if len(c.Parallel) == 0 {
+		return []byte("null"), nil
+	}
+	commands := make(map[string]LifecycleCommand, len(c.Parallel))
+	for _, command := range c.Parallel {
+		commands[command.Name] = command.Command
+	}
+	return json.Marshal(commands)
+}
+
+// MarshalJSON encodes a LifecycleCommand as a shell string or an exec array.
+// Impact: Shell-form commands become a JSON string, while exec-form commands become a JSON array.
+// Example:
+//
+//	data, err := json.Marshal(devcontainer.LifecycleCommand{Exec: []string{"echo", "hi"}})
+//
+// Similar: LifecycleCommands.MarshalJSON also handles the named parallel form.
+func (c LifecycleCommand) MarshalJSON() ([]byte, error) {
+	if c.Shell != "" {
+		return json.Marshal(c.Shell)
+	}
+	if len(c.Exec) == 0 {
+		return []byte("null"), nil
+	}
+	return json.Marshal(c.Exec)
+}
+
 func parseLifecycleCommand(data []byte) (LifecycleCommand, error) {
 	if len(data) == 0 || string(data) == "null" {
 		return LifecycleCommand{}, nil
@@ -145,6 +186,36 @@ type lifecycleHook struct {
 
 type lifecycleRunner func(ctx context.Context, name string, command LifecycleCommand) error
 
+// Lifecycle event statuses passed to WithOnLifecycleEvent callbacks.
+const (
+	LifecycleEventStart   = "start"   // LifecycleEventStart is sent before a lifecycle command runs.
+	LifecycleEventSuccess = "success" // LifecycleEventSuccess is sent after a lifecycle command succeeds.
+	LifecycleEventFailure = "failure" // LifecycleEventFailure is sent after a lifecycle command fails.
+)
+
+// observeLifecycle wraps runner so onEvent sees each command start and finish under its hook name,
+// or "hook:name" for parallel commands. Calls are serialized because parallel commands share the runner.
+func observeLifecycle(runner lifecycleRunner, onEvent func(stage, status string)) lifecycleRunner {
+	if onEvent == nil {
+		return runner
+	}
+	var mu sync.Mutex
+	emit := func(stage, status string) {
+		mu.Lock()
+		defer mu.Unlock()
+		onEvent(stage, status)
+	}
+	return func(ctx context.Context, name string, command LifecycleCommand) error {
+		emit(name, LifecycleEventStart)
+		if err := runner(ctx, name, command); err != nil {
+			emit(name, LifecycleEventFailure)
+			return err
+		}
+		emit(name, LifecycleEventSuccess)
+		return nil
+	}
+}
+
 func runLifecycleSequence(ctx context.Context, hooks []lifecycleHook, runner lifecycleRunner) error {
 	for _, hook := range hooks {
 		if hook.Commands == nil || hook.Commands.IsZero() {
@@ -192,6 +263,9 @@ func runParallelLifecycleCommands(ctx context.Context, hookName string, commands
 	return nil
 }
 
+// hostLifecycleRunner runs commands such as initializeCommand on the host in workdir. Besides the host environment,
+// they see the devcontainer variables as environment variables: localWorkspaceFolder, localWorkspaceFolderBasename,
+// containerWorkspaceFolder, containerWorkspaceFolderBasename, and devcontainerId.
 func hostLifecycleRunner(workdir string, vars, containerEnv map[string]string) lifecycleRunner {
 	return func(ctx context.Context, name string, command LifecycleCommand) error {
 		expanded, err := expandLifecycleCommand(command, vars, containerEnv)
@@ -204,7 +278,7 @@ func hostLifecycleRunner(workdir string, vars, containerEnv map[string]string) l
 		}
 		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 		cmd.Dir = workdir
-		cmd.Env = os.Environ()
+		cmd.Env = append(os.Environ(), envMapToSlice(vars)...)
 		var stdout bytes.Buffer
 		var stderr bytes.Buffer
 		cmd.Stdout = &stdout
@@ -216,7 +290,9 @@ func hostLifecycleRunner(workdir string, vars, containerEnv map[string]string) l
 	}
 }
 
-func containerLifecycleRunner(cli *client.Client, containerID, workdir, user string, vars, containerEnv map[string]string, env []string) lifecycleRunner {
+// containerLifecycleRunner runs commands in the container as user, or as the user stageUsers maps the
+// command's hook to; parallel commands named "hook:name" use their hook's entry.
+func containerLifecycleRunner(cli *client.Client, containerID, workdir, user string, stageUsers map[string]string, vars, containerEnv map[string]string, env []string) lifecycleRunner {
 	return func(ctx context.Context, name string, command LifecycleCommand) error {
 		expanded, err := expandLifecycleCommand(command, vars, containerEnv)
 		if err != nil {
@@ -230,7 +306,7 @@ func containerLifecycleRunner(cli *client.Client, containerID, workdir, user str
 			Cmd:          args,
 			Env:          env,
 			WorkingDir:   workdir,
-			User:         user,
+			User:         lifecycleUser(name, user, stageUsers),
 			AttachStdout: true,
 			AttachStderr: true,
 		}
@@ -261,6 +337,15 @@ func containerLifecycleRunner(cli *client.Client, containerID, workdir, user str
 	}
 }
 
+// lifecycleUser returns the exec user for the named lifecycle command.
+func lifecycleUser(name, user string, stageUsers map[string]string) string {
+	stage, _, _ := strings.Cut(name, ":")
+	if stageUser, ok := stageUsers[stage]; ok {
+		return stageUser
+	}
+	return user
+}
+
 func expandLifecycleCommand(command LifecycleCommand, vars, containerEnv map[string]string) (LifecycleCommand, error) {
 	if command.Shell != "" {
 		expanded, err := expandVariables(command.Shell, vars, containerEnv)
@@ -311,13 +396,41 @@ func formatLifecycleExitError(name string, args []string, stdout, stderr string,
 	return fmt.Errorf("%s failed (%s): exit code %d", name, strings.Join(args, " "), exitCode)
 }
 
+// seedImageEnv returns the ENV of the image containerID runs overlaid with env, so lifecycle commands and
+// ${containerEnv:...} references see image values such as PATH unless the config overrides them. env is returned
+// unchanged when the image cannot be inspected.
+func seedImageEnv(ctx context.Context, cli *client.Client, containerID string, env map[string]string, logger *slog.Logger) map[string]string {
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil || inspect.Image == "" {
+		return env
+	}
+	imageInspect, err := cli.ImageInspect(ctx, inspect.Image)
+	if err != nil {
+		logger.Debug("image inspect failed, lifecycle env omits image ENV", "image", inspect.Image, "error", err)
+		return env
+	}
+	if imageInspect.Config == nil || len(imageInspect.Config.Env) == 0 {
+		return env
+	}
+	seeded := make(map[string]string, len(imageInspect.Config.Env)+len(env))
+	for _, item := range imageInspect.Config.Env {
+		if key, value, ok := strings.Cut(item, "="); ok {
+			seeded[key] = value
+		}
+	}
+	for key, value := range env {
+		seeded[key] = value
+	}
+	return seeded
+}
+
 func buildLifecycleEnv(containerEnv, remoteEnv, vars map[string]string) (map[string]string, error) {
 	merged := make(map[string]string, len(containerEnv)+len(remoteEnv))
 	for key, value := range containerEnv {
 		merged[key] = value
 	}
 	for key, value := range remoteEnv {
-		expanded, err := expandVariables(value, vars, merged)
+		expanded, err := expandEnvValue(value, vars, merged)
 		if err != nil {
 			return nil, err
 		}
@@ -4,9 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/docker/docker/api/types/container"
 )
 
 func TestLifecycleCommands_UnmarshalString(t *testing.T) {
@@ -132,3 +139,235 @@ func TestRunLifecycleSequence_Order(t *testing.T) {
 		t.Fatalf("unexpected call order: %#v", called)
 	}
 }
+
+func TestObserveLifecycle_EventsInOrder(t *testing.T) {
+	hooks := []lifecycleHook{
+		{Name: "onCreateCommand", Commands: &LifecycleCommands{Single: &LifecycleCommand{Shell: "echo a"}}},
+		{Name: "postCreateCommand", Commands: &LifecycleCommands{Parallel: []NamedLifecycleCommand{
+			{Name: "deps", Command: LifecycleCommand{Shell: "echo deps"}},
+			{Name: "db", Command: LifecycleCommand{Shell: "echo db"}},
+		}}},
+		{Name: "postStartCommand", Commands: &LifecycleCommands{Single: &LifecycleCommand{Shell: "exit 1"}}},
+	}
+	var events []string
+	options, err := applyStartOptions([]StartOption{WithOnLifecycleEvent(func(stage, status string) {
+		events = append(events, stage+" "+status)
+	})})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	runner := observeLifecycle(func(ctx context.Context, name string, command LifecycleCommand) error {
+		if command.Shell == "exit 1" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}, options.OnLifecycle)
+	if err := runLifecycleSequence(context.Background(), hooks, runner); err == nil {
+		t.Fatal("expected postStartCommand to fail")
+	}
+	if len(events) != 8 {
+		t.Fatalf("unexpected events: %#v", events)
+	}
+	if !reflect.DeepEqual(events[:2], []string{"onCreateCommand start", "onCreateCommand success"}) {
+		t.Fatalf("unexpected onCreateCommand events: %#v", events[:2])
+	}
+	parallel := append([]string(nil), events[2:6]...)
+	for _, name := range []string{"postCreateCommand:deps", "postCreateCommand:db"} {
+		start := indexOf(parallel, name+" start")
+		success := indexOf(parallel, name+" success")
+		if start < 0 || success < start {
+			t.Fatalf("unexpected parallel events for %s: %#v", name, parallel)
+		}
+	}
+	if !reflect.DeepEqual(events[6:], []string{"postStartCommand start", "postStartCommand failure"}) {
+		t.Fatalf("unexpected postStartCommand events: %#v", events[6:])
+	}
+}
+
+func indexOf(values []string, want string) int {
+	for i, value := range values {
+		if value == want {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRunLifecycleWithFeatures_HookServices(t *testing.T) {
+	features := &ResolvedFeatures{
+		Order: []*ResolvedFeature{{
+			Metadata: FeatureMetadata{
+				ID:               "feature-a",
+				PostStartCommand: &LifecycleCommands{Single: &LifecycleCommand{Shell: "echo feature"}},
+			},
+		}},
+	}
+	userHooks := map[string]*LifecycleCommands{
+		"postCreateCommand": {Single: &LifecycleCommand{Shell: "echo create"}},
+		"postStartCommand":  {Single: &LifecycleCommand{Shell: "echo start"}},
+	}
+	var called []string
+	recorder := func(service string) lifecycleRunner {
+		return func(ctx context.Context, name string, command LifecycleCommand) error {
+			called = append(called, service+":"+command.Shell)
+			return nil
+		}
+	}
+	serviceRunners := map[string][]lifecycleRunner{
+		"postStartCommand": {recorder("db")},
+	}
+	if err := runLifecycleWithFeatures(context.Background(), features, userHooks, recorder("app"), serviceRunners); err != nil {
+		t.Fatalf("runLifecycleWithFeatures: %v", err)
+	}
+	expected := []string{
+		"app:echo create",
+		"app:echo feature",
+		"app:echo start",
+		"db:echo start",
+	}
+	if !reflect.DeepEqual(called, expected) {
+		t.Fatalf("unexpected call order: %#v", called)
+	}
+}
+
+func TestExpandLifecycleCommand_KeepsShellDollars(t *testing.T) {
+	vars := map[string]string{"containerWorkspaceFolder": "/workspaces/app"}
+	shell, err := expandLifecycleCommand(LifecycleCommand{Shell: "echo $$ > ${containerWorkspaceFolder}/pid"}, vars, nil)
+	if err != nil {
+		t.Fatalf("expandLifecycleCommand: %v", err)
+	}
+	if shell.Shell != "echo $$ > /workspaces/app/pid" {
+		t.Fatalf("unexpected shell command: %q", shell.Shell)
+	}
+	exec, err := expandLifecycleCommand(LifecycleCommand{Exec: []string{"sh", "-c", "echo $$"}}, vars, nil)
+	if err != nil {
+		t.Fatalf("expandLifecycleCommand: %v", err)
+	}
+	if !reflect.DeepEqual(exec.Exec, []string{"sh", "-c", "echo $$"}) {
+		t.Fatalf("unexpected exec command: %#v", exec.Exec)
+	}
+}
+
+func TestHasLifecycleCommands(t *testing.T) {
+	empty := map[string]*LifecycleCommands{"postCreateCommand": nil, "postStartCommand": {}}
+	if hasLifecycleCommands(nil, empty) {
+		t.Fatal("expected no lifecycle commands")
+	}
+	user := map[string]*LifecycleCommands{"postStartCommand": {Single: &LifecycleCommand{Shell: "echo start"}}}
+	if !hasLifecycleCommands(nil, user) {
+		t.Fatal("expected user hook to count")
+	}
+	features := &ResolvedFeatures{Order: []*ResolvedFeature{{Metadata: FeatureMetadata{
+		PostCreateCommand: &LifecycleCommands{Single: &LifecycleCommand{Shell: "echo feature"}},
+	}}}}
+	if !hasLifecycleCommands(features, empty) {
+		t.Fatal("expected feature hook to count")
+	}
+}
+
+func TestValidateHookServices(t *testing.T) {
+	project := &types.Project{
+		Services: types.Services{
+			{Name: "app"},
+			{Name: "db"},
+		},
+	}
+	if err := validateHookServices(map[string][]string{"postStartCommand": {"db"}}, project, []string{"app", "db"}); err != nil {
+		t.Fatalf("validateHookServices: %v", err)
+	}
+	if err := validateHookServices(map[string][]string{"postStartCommand": {"db"}}, project, []string{"app"}); err == nil {
+		t.Fatal("expected error for service outside runServices")
+	}
+	if err := validateHookServices(map[string][]string{"postStartCommand": {"cache"}}, project, nil); err == nil {
+		t.Fatal("expected error for unknown service")
+	}
+	if err := validateHookServices(map[string][]string{"initializeCommand": {"db"}}, project, nil); err == nil {
+		t.Fatal("expected error for host-only hook")
+	}
+}
+
+func TestContainerLifecycleRunner_StageUsers(t *testing.T) {
+	var mu sync.Mutex
+	users := make(map[string]string)
+	execs := 0
+	daemon := newFakeDaemon(t)
+	daemon.handle(http.MethodPost, "/containers/container-123/exec", func(w http.ResponseWriter, r *http.Request) {
+		var body container.ExecOptions
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode exec: %v", err)
+		}
+		mu.Lock()
+		execs++
+		id := fmt.Sprintf("exec-%d", execs)
+		users[strings.Join(body.Cmd, " ")] = body.User
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"Id":%q}`, id)
+	})
+	daemon.handle(http.MethodPost, "/start", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+		_ = conn.Close()
+	})
+	daemon.respond(http.MethodGet, "/json", http.StatusOK, `{"ExitCode":0}`)
+	cli := daemon.cli
+	options, err := applyStartOptions([]StartOption{WithLifecycleUser("onCreateCommand", "root"), WithLifecycleUser("postCreateCommand", "root")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	userHooks := map[string]*LifecycleCommands{
+		"onCreateCommand": {Single: &LifecycleCommand{Exec: []string{"apt-get", "install"}}},
+		"postCreateCommand": {Parallel: []NamedLifecycleCommand{
+			{Name: "deps", Command: LifecycleCommand{Exec: []string{"make", "deps"}}},
+		}},
+		"postStartCommand": {Single: &LifecycleCommand{Exec: []string{"make", "serve"}}},
+	}
+	runner := containerLifecycleRunner(cli, "container-123", "/work", "vscode", options.StageUsers, nil, nil, nil)
+	if err := runLifecycleWithFeatures(context.Background(), nil, userHooks, runner, nil); err != nil {
+		t.Fatalf("runLifecycleWithFeatures: %v", err)
+	}
+	expected := map[string]string{"apt-get install": "root", "make deps": "root", "make serve": "vscode"}
+	if !reflect.DeepEqual(users, expected) {
+		t.Fatalf("unexpected exec users: %#v", users)
+	}
+
+	if _, err := applyStartOptions([]StartOption{WithLifecycleUser("initializeCommand", "root")}); err == nil {
+		t.Fatal("expected host-side hook to be rejected")
+	}
+}
+
+func TestRedactLifecycle_MasksSecretEnv(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithSecretEnv("TOKEN", "s3cr3t-value"), WithEnv("PLAIN", "visible")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	runner := redactLifecycle(hostLifecycleRunner(t.TempDir(), nil, nil), options.secretRedactor())
+	command := LifecycleCommand{Exec: []string{"sh", "-c", "echo s3cr3t-value visible >&2; exit 1"}}
+	err = runner(context.Background(), "postCreateCommand", command)
+	if err == nil {
+		t.Fatal("expected lifecycle error")
+	}
+	if strings.Contains(err.Error(), "s3cr3t-value") {
+		t.Fatalf("secret leaked into error: %v", err)
+	}
+	if !strings.Contains(err.Error(), "*** visible") {
+		t.Fatalf("expected masked output in error, got %v", err)
+	}
+	if options.Env["TOKEN"] != "s3cr3t-value" {
+		t.Fatalf("expected secret env to be set, got %#v", options.Env)
+	}
+}
+
+func TestHostLifecycleRunner_ExposesWorkspaceVars(t *testing.T) {
+	workdir := t.TempDir()
+	vars := map[string]string{"localWorkspaceFolder": "/home/me/my project", "devcontainerId": "abc123"}
+	runner := hostLifecycleRunner(workdir, vars, nil)
+	command := LifecycleCommand{Shell: `test "$localWorkspaceFolder" = "/home/me/my project" && test "$devcontainerId" = abc123`}
+	if err := runner(context.Background(), "initializeCommand", command); err != nil {
+		t.Fatalf("expected workspace vars in the host environment: %v", err)
+	}
+}
@@ -1,26 +1,97 @@
 package godev
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+)
 
 type StartOption func(*startOptions)
 
 // startOptions holds StartDevcontainer configuration derived from StartOption values.
 type startOptions struct {
-	ConfigPath   string                // ConfigPath overrides the devcontainer.json path.
-	Config       *DevcontainerConfig   // Config overrides devcontainer.json loading when set.
-	MergeConfigs []*DevcontainerConfig // MergeConfigs are merged onto the base config in order.
-	Env          map[string]string     // Env holds extra environment variables.
-	ExtraPublish []string              // ExtraPublish adds port publish entries.
-	ExtraMounts  []Mount               // ExtraMounts adds extra mount entries.
-	RunArgs      []string              // RunArgs adds raw docker run arguments.
-	RemoveOnStop bool                  // RemoveOnStop enables AutoRemove on the container.
-	Detach       bool                  // Detach controls whether StartDevcontainer waits.
-	TTY          bool                  // TTY controls pseudo-TTY allocation.
-	Labels       map[string]string     // Labels adds Docker labels.
-	Resources    ResourceLimits        // Resources configures CPU and memory limits.
-	Network      string                // Network overrides the network mode.
-	Timeout      time.Duration         // Timeout limits the overall start duration.
-	Workdir      string                // Workdir overrides the container working directory.
+	ConfigPath       string                // ConfigPath overrides the devcontainer.json path.
+	Config           *DevcontainerConfig   // Config overrides devcontainer.json loading when set.
+	GitSource        *gitConfigSource      // GitSource clones the config and its workspace from a git repository at start.
+	GitClone         string                // GitClone is the directory GitSource was cloned into, recorded as a container label.
+	MergeConfigs     []*DevcontainerConfig // MergeConfigs are merged onto the base config in order.
+	NoLocalConfig    bool                  // NoLocalConfig skips merging devcontainer.local.json.
+	Env              map[string]string     // Env holds extra environment variables.
+	SecretEnv        []string              // SecretEnv lists Env keys whose values are masked in errors and output.
+	PassEnv          []string              // PassEnv lists host environment variables copied into the container at start.
+	ExtraPublish     []string              // ExtraPublish adds port publish entries.
+	EphemeralPorts   bool                  // EphemeralPorts publishes bare appPort entries on Docker-assigned host ports.
+	ExtraMounts      []Mount               // ExtraMounts adds extra mount entries.
+	WorkspaceMount   string                // WorkspaceMount replaces the computed or configured workspace mount spec.
+	NoWorkspaceMount bool                  // NoWorkspaceMount omits the workspace mount entirely.
+	RunArgs          []string              // RunArgs adds raw docker run arguments.
+	AllowedRunArgs   []string              // AllowedRunArgs restricts accepted runArg flags; nil allows every supported flag.
+	IgnoreRunArgs    bool                  // IgnoreRunArgs skips unsupported runArg flags with a warning instead of failing.
+	NoPrivileged     bool                  // NoPrivileged rejects privileged mode and dangerous capabilities from any source.
+	RemoveOnStop     bool                  // RemoveOnStop enables AutoRemove on the container.
+	Detach           bool                  // Detach controls whether StartDevcontainer waits.
+	TTY              bool                  // TTY controls pseudo-TTY allocation.
+	Interactive      bool                  // Interactive attaches stdio to the container while waiting for it to exit.
+	AutoForwardPorts bool                  // AutoForwardPorts reports container ports that listen without being published.
+	Stdin            io.Reader             // Stdin feeds the attached container; nil uses os.Stdin.
+	Stdout           io.Writer             // Stdout receives attached container output; nil uses os.Stdout.
+	Stderr           io.Writer             // Stderr receives attached container errors without a TTY; nil uses os.Stderr.
+	Labels           map[string]string     // Labels adds Docker labels.
+	FileLabels       map[string]string     // FileLabels holds labels read from label files; Labels take precedence.
+	Resources        ResourceLimits        // Resources configures CPU and memory limits.
+	Network          string                // Network overrides the network mode.
+	Hostname         string                // Hostname sets the container hostname.
+	StopSignal       string                // StopSignal is the signal Docker sends to stop the container.
+	StopTimeout      time.Duration         // StopTimeout is the default grace period before the container is killed.
+	KeepAlive        []string              // KeepAlive replaces the sleep loop run when overrideCommand is true.
+	Entrypoint       []string              // Entrypoint replaces the image entrypoint when non-nil; empty clears it.
+	Timeout          time.Duration         // Timeout limits the overall start duration.
+	FeatureTimeout   time.Duration         // FeatureTimeout limits feature resolution, including registry downloads.
+	SkipPreflight    bool                  // SkipPreflight skips the Docker daemon ping before starting.
+	Workdir          string                // Workdir overrides the container working directory.
+	BuildContext     string                // BuildContext overrides the Docker build context directory.
+	BuildTarget      string                // BuildTarget overrides the Docker build target stage.
+	ImageTag         string                // ImageTag is an extra tag applied to the final image after any build.
+	PushImage        bool                  // PushImage pushes ImageTag to its registry after tagging.
+	RegistryAuth     registryAuths         // RegistryAuth holds WithRegistryAuth credentials by docker config host key.
+	NoBuildCache     bool                  // NoBuildCache disables the layer cache for image and feature builds.
+	PullBuildBase    bool                  // PullBuildBase refreshes the Dockerfile's FROM images before building.
+	BuildNetwork     string                // BuildNetwork is the network mode for RUN steps in image and feature builds.
+	BuildExtraHosts  []string              // BuildExtraHosts holds host:ip entries added to /etc/hosts during builds.
+	BuildArgs        map[string]string     // BuildArgs overrides build.args from devcontainer.json.
+	FileBuildArgs    map[string]string     // FileBuildArgs holds build args read from files; BuildArgs take precedence.
+	ComposeDiscovery bool                  // ComposeDiscovery enables COMPOSE_FILE fallback and override auto-discovery.
+	HookServices     map[string][]string   // HookServices lists extra compose services per lifecycle hook.
+	Name             string                // Name overrides the container or compose project name.
+	ComposeOutput    io.Writer             // ComposeOutput receives live docker compose up output.
+	PersistOverride  bool                  // PersistOverride keeps the compose override in the workspace .devcontainer directory.
+	KeepOverride     bool                  // KeepOverride leaves the temporary compose override on disk and logs its path.
+	KeepOnFailure    bool                  // KeepOnFailure leaves a compose stack running when start fails after it came up.
+	Platform         string                // Platform selects the image platform such as linux/arm64.
+	DevcontainerID   string                // DevcontainerID overrides the path-derived ${devcontainerId}.
+	ValidateBinds    bool                  // ValidateBinds checks bind mount sources exist before creating the container.
+	ReadOnlyRootfs   bool                  // ReadOnlyRootfs mounts the container root filesystem read-only.
+	NoNewPrivileges  bool                  // NoNewPrivileges adds no-new-privileges:true to the security options.
+	HealthyTimeout   time.Duration         // HealthyTimeout waits for a healthy HEALTHCHECK before lifecycle hooks when set.
+	WorkspaceWait    time.Duration         // WorkspaceWait waits for the workspace folder to be populated before lifecycle hooks when set.
+	WorkspaceMarker  string                // WorkspaceMarker is the path, relative to the workspace folder, that WorkspaceWait looks for.
+	ToolingLabels    bool                  // ToolingLabels adds devcontainer.local_folder and devcontainer.config_file labels.
+	StrictFeatures   bool                  // StrictFeatures rejects one feature resolved at conflicting versions.
+	FeatureOrder     FeatureOrderStrategy  // FeatureOrder selects how features are ordered for installation; empty is FeatureOrderSpec.
+	SharedFeatures   bool                  // SharedFeatures tags feature images by content only so workspaces can reuse them.
+	Logger           *slog.Logger          // Logger receives structured progress; nil discards it.
+	OnLifecycle      func(string, string)  // OnLifecycle receives lifecycle command start and finish events.
+	StageUsers       map[string]string     // StageUsers overrides the exec user for specific container lifecycle hooks.
+	BuildOutput      io.Writer             // BuildOutput receives live docker build output.
+	DumpFeatureEnv   string                // DumpFeatureEnv is a host directory receiving each feature's devcontainer-features.env.
+	TempDir          string                // TempDir is the parent of feature extraction and build context directories.
+	Errs             []error               // Errs collects failures from options that parse their input.
 }
 
 // Mount describes an extra container mount to apply at start.
@@ -30,12 +101,16 @@ type Mount struct {
 	Type        string // Type is the mount type, such as bind or volume.
 	ReadOnly    bool   // ReadOnly marks the mount as read-only.
 	Consistency string // Consistency sets the Docker mount consistency mode.
+	shorthand   bool   // shorthand marks a source:target spec, whose relative bind source is resolved against the workspace.
 }
 
 // ResourceLimits defines CPU and memory limits for the container.
 type ResourceLimits struct {
-	CPUQuota int64  // CPUQuota is the Docker CPU quota value.
-	Memory   string // Memory is the memory limit string (e.g. "1g").
+	CPUQuota          int64  // CPUQuota is the Docker CPU quota value.
+	CPUShares         int64  // CPUShares is the relative CPU weight against other containers.
+	Memory            string // Memory is the memory limit string (e.g. "1g").
+	MemoryReservation string // MemoryReservation is the soft memory limit string (e.g. "512m").
+	PidsLimit         int64  // PidsLimit caps the number of processes in the container.
 }
 
 func defaultStartOptions() startOptions {
@@ -45,6 +120,21 @@ func defaultStartOptions() startOptions {
 	}
 }
 
+// applyStartOptions applies opts over the defaults and returns errors collected by fallible options.
+func applyStartOptions(opts []StartOption) (startOptions, error) {
+	options := defaultStartOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.PushImage && options.ImageTag == "" {
+		options.Errs = append(options.Errs, errors.New("WithPushImage requires WithImageTag"))
+	}
+	if err := errors.Join(options.Errs...); err != nil {
+		return options, fmt.Errorf("invalid start option: %w", err)
+	}
+	return options, nil
+}
+
 // WithConfigPath sets the devcontainer.json path used by StartDevcontainer.
 // Impact: The provided path is used directly instead of searching for the config.
 // Example:
@@ -58,6 +148,25 @@ func WithConfigPath(path string) StartOption {
 	}
 }
 
+// WithConfigFromGit shallow-clones repoURL at ref into a temporary directory and starts the devcontainer found there.
+// Impact: subpath is searched for devcontainer.json like FindConfigPath (or names the file itself), and the clone is the
+// workspace; it replaces WithConfigPath. The clone outlives StopDevcontainer so a stopped container can restart, and is
+// deleted by RemoveDevcontainer or when start fails before a container exists. An empty ref uses the remote default branch.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithConfigFromGit("https://github.com/org/app.git", "main", ""))
+//
+// Similar: WithConfigPath starts from a config already on disk and never deletes it.
+func WithConfigFromGit(repoURL, ref, subpath string) StartOption {
+	return func(o *startOptions) {
+		if strings.TrimSpace(repoURL) == "" {
+			o.Errs = append(o.Errs, errors.New("git config source requires a repository URL"))
+			return
+		}
+		o.GitSource = &gitConfigSource{URL: repoURL, Ref: ref, Subpath: subpath}
+	}
+}
+
 // WithConfig sets the devcontainer config struct used by StartDevcontainer.
 // Impact: The provided config is used instead of loading devcontainer.json.
 // Example:
@@ -73,7 +182,8 @@ func WithConfig(cfg *DevcontainerConfig) StartOption {
 }
 
 // WithMergeConfig adds a config overlay merged onto the base config.
-// Impact: Later overlays override earlier values for scalar fields and append to slices.
+// Impact: Later overlays override earlier values for scalar fields and append to slices. Relative paths in an overlay,
+// such as build.dockerfile or local features, resolve against the base config's directory, not the overlay's.
 // Example:
 //
 //	overlay := &devcontainer.DevcontainerConfig{RunArgs: []string{"--privileged"}}
@@ -89,6 +199,19 @@ func WithMergeConfig(cfg *DevcontainerConfig) StartOption {
 	}
 }
 
+// WithoutLocalConfig skips the devcontainer.local.json overlay next to the loaded config.
+// Impact: Only devcontainer.json and WithMergeConfig overlays are used, e.g. for reproducible CI runs.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithoutLocalConfig())
+//
+// Similar: WithMergeConfig adds overlays programmatically; the local file is merged before them.
+func WithoutLocalConfig() StartOption {
+	return func(o *startOptions) {
+		o.NoLocalConfig = true
+	}
+}
+
 // WithEnv adds one container environment variable.
 // Impact: Values are merged with containerEnv and override keys with the same name.
 // Example:
@@ -105,6 +228,36 @@ func WithEnv(key, value string) StartOption {
 	}
 }
 
+// WithEnvPassthrough copies the host value of key into the container environment at start.
+// Impact: The host value is used verbatim and overrides containerEnv; WithEnv for the same key wins, and a key that
+// is unset on the host is left as configured.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithEnvPassthrough("SSH_AUTH_SOCK"))
+//
+// Similar: ${localEnv:KEY} in containerEnv reads the host value from devcontainer.json instead.
+func WithEnvPassthrough(key string) StartOption {
+	return func(o *startOptions) {
+		o.PassEnv = append(o.PassEnv, key)
+	}
+}
+
+// WithSecretEnv adds one container environment variable whose value is treated as a secret.
+// Impact: The variable is set like WithEnv, and its value is replaced with *** in lifecycle errors, build output,
+// compose output, and WithLogger records. Compose passes it to docker compose through the process environment, so
+// it is never written to the compose override file.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithSecretEnv("GITHUB_TOKEN", os.Getenv("GITHUB_TOKEN")))
+//
+// Similar: WithEnv sets a variable whose value may appear in output.
+func WithSecretEnv(key, value string) StartOption {
+	return func(o *startOptions) {
+		WithEnv(key, value)(o)
+		o.SecretEnv = append(o.SecretEnv, key)
+	}
+}
+
 // WithExtraPublish adds an extra port publish mapping.
 // Impact: It is applied in addition to forwardPorts and appPort from devcontainer.json.
 // Example:
@@ -118,6 +271,46 @@ func WithExtraPublish(mapping string) StartOption {
 	}
 }
 
+// WithEphemeralAppPorts publishes bare appPort entries such as 3000 on a host port Docker picks instead of the same port.
+// Impact: Several devcontainers can publish the same appPort without host port conflicts; StartResult.Ports reports the
+// assigned host port. Entries with an explicit host port and forwardPorts are published as written.
+// Example:
+//
+//	result, err := devcontainer.StartDevcontainerResult(ctx, devcontainer.WithEphemeralAppPorts())
+//
+// Similar: WithExtraPublish(":3000") requests an ephemeral host port for one extra port.
+func WithEphemeralAppPorts() StartOption {
+	return func(o *startOptions) {
+		o.EphemeralPorts = true
+	}
+}
+
+// WithWorkspaceMount replaces the workspace mount with a mount string such as "type=volume,source=ws,target=/workspaces/app".
+// Impact: It takes precedence over workspaceMount in devcontainer.json; variables like ${devcontainerId} are expanded before parsing.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithWorkspaceMount("type=volume,source=${devcontainerId}-ws,target=/workspaces/app"))
+//
+// Similar: WithExtraMount adds a mount alongside the workspace mount instead of replacing it.
+func WithWorkspaceMount(spec string) StartOption {
+	return func(o *startOptions) {
+		o.WorkspaceMount = spec
+	}
+}
+
+// WithoutWorkspaceMount starts the container without mounting the host workspace.
+// Impact: workspaceFolder and ${containerWorkspaceFolder} are still resolved; it fails if devcontainer.json sets workspaceMount or WithWorkspaceMount is used.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithoutWorkspaceMount())
+//
+// Similar: WithWorkspaceMount replaces the workspace mount instead of removing it.
+func WithoutWorkspaceMount() StartOption {
+	return func(o *startOptions) {
+		o.NoWorkspaceMount = true
+	}
+}
+
 // WithExtraMount adds an extra mount to the container configuration.
 // Impact: It is appended to workspace and configured mounts and applied to HostConfig at create time.
 // Example:
@@ -131,6 +324,330 @@ func WithExtraMount(m Mount) StartOption {
 	}
 }
 
+// WithPlatform selects the image platform (os/arch[/variant]) used to pull, build, inspect, and run.
+// Impact: Multi-arch images resolve to the given platform, including the default user used for features.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithPlatform("linux/arm64"))
+//
+// Similar: Compose configurations take the platform from the service definition instead.
+func WithPlatform(platform string) StartOption {
+	return func(o *startOptions) {
+		o.Platform = platform
+	}
+}
+
+// WithDevcontainerID pins ${devcontainerId} instead of hashing the workspace and config paths.
+// Impact: Default container, image, and compose project names become reproducible across machines and CI checkouts.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithDevcontainerID("ci-main"))
+//
+// Similar: WithName replaces the whole container or project name rather than only the ID part.
+func WithDevcontainerID(id string) StartOption {
+	return func(o *startOptions) {
+		if !devcontainerIDPattern.MatchString(id) {
+			o.Errs = append(o.Errs, fmt.Errorf("invalid devcontainer id %q: use lowercase letters, digits, '.', '_' or '-'", id))
+			return
+		}
+		o.DevcontainerID = id
+	}
+}
+
+// WithValidateBindSources checks that every bind mount source exists on the host before the container is created.
+// Impact: Missing paths are reported together in one error instead of a Docker create failure; volume mounts are skipped.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithValidateBindSources())
+//
+// Similar: Without this option Docker reports missing bind sources when the container is created. Compose configs reject it.
+func WithValidateBindSources() StartOption {
+	return func(o *startOptions) {
+		o.ValidateBinds = true
+	}
+}
+
+// WithReadOnlyRootfs mounts the container root filesystem read-only, like docker run --read-only.
+// Impact: Writes outside mounts fail, so writable paths such as /tmp usually need tmpfs mounts (e.g. WithMountSpec("type=tmpfs,target=/tmp")).
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithReadOnlyRootfs(), devcontainer.WithMountSpec("type=tmpfs,target=/tmp"))
+//
+// Similar: The "--read-only" runArg in devcontainer.json has the same effect.
+func WithReadOnlyRootfs() StartOption {
+	return func(o *startOptions) {
+		o.ReadOnlyRootfs = true
+	}
+}
+
+// WithNoNewPrivileges prevents container processes from gaining privileges via setuid binaries.
+// Impact: "no-new-privileges:true" is added to HostConfig.SecurityOpt unless securityOpt or runArgs already set it.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithNoNewPrivileges())
+//
+// Similar: Listing "no-new-privileges:true" in devcontainer.json securityOpt has the same effect.
+func WithNoNewPrivileges() StartOption {
+	return func(o *startOptions) {
+		o.NoNewPrivileges = true
+	}
+}
+
+// WithWorkspaceReadyCheck waits up to timeout for the workspace folder to be populated before lifecycle hooks run.
+// Impact: onCreate/updateContent/postCreate commands no longer race a slow bind mount; the check execs in the container
+// until marker (relative to the workspace folder) exists, or until the folder is non-empty when marker is "".
+// A timeout aborts the start. The check runs test in the container, or /bin/sh and ls when marker is "", so the
+// image needs those tools.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithWorkspaceReadyCheck("go.mod", 30*time.Second))
+//
+// Similar: WithWaitForHealthy waits for the image HEALTHCHECK rather than the workspace contents.
+func WithWorkspaceReadyCheck(marker string, timeout time.Duration) StartOption {
+	return func(o *startOptions) {
+		if timeout <= 0 {
+			o.Errs = append(o.Errs, fmt.Errorf("workspace ready check timeout must be positive: %s", timeout))
+			return
+		}
+		if marker != "" && !filepath.IsLocal(filepath.FromSlash(marker)) {
+			o.Errs = append(o.Errs, fmt.Errorf("workspace marker must be relative to the workspace folder: %s", marker))
+			return
+		}
+		o.WorkspaceWait = timeout
+		o.WorkspaceMarker = marker
+	}
+}
+
+// WithWaitForHealthy waits up to timeout for the container HEALTHCHECK to report healthy before lifecycle hooks run.
+// Impact: postCreate/postStart commands see a ready container; an unhealthy status or timeout aborts the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithWaitForHealthy(30*time.Second))
+//
+// Similar: Containers without a healthcheck proceed immediately, as they do without this option.
+func WithWaitForHealthy(timeout time.Duration) StartOption {
+	return func(o *startOptions) {
+		if timeout <= 0 {
+			o.Errs = append(o.Errs, fmt.Errorf("wait for healthy timeout must be positive: %s", timeout))
+			return
+		}
+		o.HealthyTimeout = timeout
+	}
+}
+
+// WithLabelFile reads KEY=VALUE labels from a dotenv-style file.
+// Impact: File labels sit beneath WithLabel values, so explicit labels win on conflicting keys; later files override earlier ones.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithLabelFile("ci.labels"))
+//
+// Similar: WithLabel adds a single label that overrides file labels.
+func WithLabelFile(path string) StartOption {
+	return func(o *startOptions) {
+		labels, err := parseDotEnvFile(path)
+		if err != nil {
+			o.Errs = append(o.Errs, fmt.Errorf("label file %s: %w", path, err))
+			return
+		}
+		if o.FileLabels == nil {
+			o.FileLabels = make(map[string]string)
+		}
+		for key, value := range labels {
+			o.FileLabels[key] = value
+		}
+	}
+}
+
+// WithToolingLabels adds the devcontainer.local_folder and devcontainer.config_file labels.
+// Impact: Other devcontainer tooling, such as the VS Code Dev Containers extension, can discover and attach to the container.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithToolingLabels())
+//
+// Similar: WithLabel adds arbitrary labels; devcontainer.config_path is always set.
+func WithToolingLabels() StartOption {
+	return func(o *startOptions) {
+		o.ToolingLabels = true
+	}
+}
+
+// WithStrictFeatureVersions fails when the same feature is requested at conflicting versions.
+// Impact: Features sharing a base name must resolve to the same content; otherwise start and build return an error instead of installing both.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithStrictFeatureVersions())
+//
+// Similar: Without this option each version installs separately, ordered by the usual feature ordering rules.
+func WithStrictFeatureVersions() StartOption {
+	return func(o *startOptions) {
+		o.StrictFeatures = true
+	}
+}
+
+// WithFeatureOrderStrategy selects how features are ordered for installation.
+// Impact: FeatureOrderSpec (the default) installs features in spec-compatible rounds sorted by feature name, honoring
+// installsAfter; FeatureOrderTopological only waits on dependsOn and otherwise keeps resolution order, so unrelated
+// features are not reordered by name. overrideFeatureInstallOrder applies to both; other values fail the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithFeatureOrderStrategy(devcontainer.FeatureOrderTopological))
+//
+// Similar: overrideFeatureInstallOrder in devcontainer.json pins specific features first under either strategy.
+func WithFeatureOrderStrategy(strategy FeatureOrderStrategy) StartOption {
+	return func(o *startOptions) {
+		switch strategy {
+		case FeatureOrderSpec, FeatureOrderTopological:
+			o.FeatureOrder = strategy
+		default:
+			o.Errs = append(o.Errs, fmt.Errorf("unknown feature order strategy %q: use %q or %q", strategy, FeatureOrderSpec, FeatureOrderTopological))
+		}
+	}
+}
+
+// WithSharedFeatureImages tags the feature image by a hash of its content instead of by workspace.
+// Impact: The tag covers the base image, the container and remote users, and every feature with its resolved options, so
+// workspaces with identical feature sets build and tag the same godev-features-<hash> image and reuse Docker's cache.
+// Local features are keyed by their path, so only registry and URL features are shared between workspaces.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithSharedFeatureImages())
+//
+// Similar: By default the tag also names the workspace and devcontainerId, keeping each workspace's image separate.
+func WithSharedFeatureImages() StartOption {
+	return func(o *startOptions) {
+		o.SharedFeatures = true
+	}
+}
+
+// WithLogger sends structured progress, such as per-feature install timing, to logger.
+// Impact: Progress records are emitted at info level while images are built and containers start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithLogger(slog.Default()))
+//
+// Similar: WithBuildOutput streams raw build output instead of structured records.
+func WithLogger(logger *slog.Logger) StartOption {
+	return func(o *startOptions) {
+		o.Logger = logger
+	}
+}
+
+// WithOnLifecycleEvent calls fn when each lifecycle command starts and finishes.
+// Impact: stage is the hook name, such as "postCreateCommand", or "postCreateCommand:name" for a parallel
+// command; status is LifecycleEventStart, LifecycleEventSuccess, or LifecycleEventFailure. Calls are serialized.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithOnLifecycleEvent(func(stage, status string) { fmt.Println(stage, status) }))
+//
+// Similar: WithLogger reports progress as log records rather than callbacks.
+func WithOnLifecycleEvent(fn func(stage, status string)) StartOption {
+	return func(o *startOptions) {
+		o.OnLifecycle = fn
+	}
+}
+
+// WithLifecycleUser runs one container lifecycle hook, such as "postCreateCommand", as user.
+// Impact: Feature and user commands for that hook, including parallel ones, exec as user instead of remoteUser;
+// other hooks are unchanged, and hooks other than onCreate, updateContent, postCreate, postStart, and postAttach fail the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithLifecycleUser("onCreateCommand", "root"))
+//
+// Similar: remoteUser in devcontainer.json sets the user for every hook.
+func WithLifecycleUser(stage, user string) StartOption {
+	return func(o *startOptions) {
+		if !containsString(lifecycleOrder, stage) {
+			o.Errs = append(o.Errs, fmt.Errorf("unsupported container lifecycle hook: %s", stage))
+			return
+		}
+		if o.StageUsers == nil {
+			o.StageUsers = make(map[string]string)
+		}
+		o.StageUsers[stage] = user
+	}
+}
+
+// WithBuildOutput streams docker build output to w.
+// Impact: The Dockerfile build, the feature build, and image pull status lines are written to w; feature install
+// lines are prefixed with the feature's canonical name so slow or failing features are visible.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithBuildOutput(os.Stderr))
+//
+// Similar: WithComposeOutput streams docker compose up output.
+func WithBuildOutput(w io.Writer) StartOption {
+	return func(o *startOptions) {
+		o.BuildOutput = w
+	}
+}
+
+// WithDumpFeatureEnv writes each feature's devcontainer-features.env to dir on the host.
+// Impact: Files are named after the feature's build directory (e.g. 01-go.env) and show the options install.sh receives.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithDumpFeatureEnv("./.devcontainer/.feature-env"))
+//
+// Similar: WithBuildOutput shows what install.sh printed rather than what it was given.
+func WithDumpFeatureEnv(dir string) StartOption {
+	return func(o *startOptions) {
+		o.DumpFeatureEnv = dir
+	}
+}
+
+// attachStdio reports whether stdio is attached, which only WithInteractive asks for.
+func (o startOptions) attachStdio() bool {
+	return !o.Detach && o.Interactive
+}
+
+// WithTempDir sets the directory that holds temporary feature downloads and build contexts.
+// Impact: Remote features are extracted and the features build context is written under dir instead of the OS
+// temp dir, so large feature sets can use a scratch volume; dir must already exist.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithTempDir("/mnt/scratch"))
+//
+// Similar: WithDumpFeatureEnv writes files meant to be kept; WithTempDir only moves scratch space.
+func WithTempDir(dir string) StartOption {
+	return func(o *startOptions) {
+		o.TempDir = dir
+	}
+}
+
+// secretRedactor masks the current values of the WithSecretEnv keys, or is nil when there are none.
+func (o startOptions) secretRedactor() *redactor {
+	values := make([]string, 0, len(o.SecretEnv))
+	for _, key := range o.SecretEnv {
+		values = append(values, o.Env[key])
+	}
+	return newSecretRedactor(values)
+}
+
+// logger returns the configured logger, masking WithSecretEnv values, or one that discards records.
+func (o startOptions) logger() *slog.Logger {
+	if o.Logger != nil {
+		return redactLogger(o.Logger, o.secretRedactor())
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// WithMountSpec adds an extra mount parsed from a Docker --mount string.
+// Impact: The spec is parsed with ParseMountSpec; a parse error is reported when StartDevcontainer applies the options.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithMountSpec("type=bind,source=/tmp,target=/work"))
+//
+// Similar: WithExtraMount takes an already constructed Mount.
+func WithMountSpec(spec string) StartOption {
+	return func(o *startOptions) {
+		parsed, err := ParseMountSpec(spec)
+		if err != nil {
+			o.Errs = append(o.Errs, err)
+			return
+		}
+		o.ExtraMounts = append(o.ExtraMounts, parsed)
+	}
+}
+
 // WithRunArg appends one raw docker run argument.
 // Impact: Selected flags (such as --cap-add) are parsed and affect privilege and network settings.
 // Example:
@@ -144,6 +661,49 @@ func WithRunArg(arg string) StartOption {
 	}
 }
 
+// WithAllowedRunArgs restricts runArgs to the given flags, such as "--cap-add" or "--user".
+// Impact: Any other flag in devcontainer.json runArgs or WithRunArg fails the start before the container is created;
+// short forms match their long names (-u is --user). An empty list rejects every runArg.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithAllowedRunArgs([]string{"--cap-add", "--label"}))
+//
+// Similar: Without this option every supported runArg is accepted.
+func WithAllowedRunArgs(flags []string) StartOption {
+	return func(o *startOptions) {
+		o.AllowedRunArgs = append([]string{}, flags...)
+	}
+}
+
+// WithIgnoreUnknownRunArgs skips runArgs godev does not model instead of failing the start.
+// Impact: Each skipped flag is logged at warn level and has no effect on the container. A following argument that
+// does not start with "-" is taken as the flag's value and skipped too; write "--flag=value" to avoid the guess.
+// Flags outside WithAllowedRunArgs are still rejected.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithIgnoreUnknownRunArgs())
+//
+// Similar: Without this option an unsupported runArg fails the start with "unsupported runArg".
+func WithIgnoreUnknownRunArgs() StartOption {
+	return func(o *startOptions) {
+		o.IgnoreRunArgs = true
+	}
+}
+
+// WithDisallowPrivileged rejects configs that request privileged mode or dangerous capabilities such as SYS_ADMIN.
+// Impact: privileged and capAdd in devcontainer.json, feature metadata, runArgs, and the compose service are checked,
+// and the start fails naming the source before the container is created.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithDisallowPrivileged())
+//
+// Similar: WithAllowedRunArgs limits runArg flags but does not inspect config or feature settings.
+func WithDisallowPrivileged() StartOption {
+	return func(o *startOptions) {
+		o.NoPrivileged = true
+	}
+}
+
 // WithRemoveOnStop enables automatic container removal when it stops.
 // Impact: Docker AutoRemove is set to true so the container is removed after stopping.
 // Example:
@@ -196,6 +756,50 @@ func WithTTY() StartOption {
 	}
 }
 
+// WithInteractive attaches stdin, stdout, and stderr to the container and waits for it to exit.
+// Impact: It implies WithDetachValue(false); stdio is attached before start and, with a TTY, a terminal stdin is put in
+// raw mode while attached. The terminal is restored and stdin is no longer read once the container exits.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithInteractive())
+//
+// Similar: WithDetachValue(false) alone waits for exit without touching stdio; WithStdio replaces the process stdio.
+func WithInteractive() StartOption {
+	return func(o *startOptions) {
+		o.Interactive = true
+		o.Detach = false
+	}
+}
+
+// WithAutoForwardPorts detects ports that start listening in the container without being published.
+// Impact: After lifecycle commands, /proc/net/tcp is read via exec and each unpublished listening port is logged
+// and listed in StartResult.DetectedPorts; without detach the scan repeats until the container exits.
+// Example:
+//
+//	result, err := devcontainer.StartDevcontainerResult(ctx, devcontainer.WithAutoForwardPorts(), devcontainer.WithLogger(slog.Default()))
+//
+// Similar: WithExtraPublish publishes a known port at create time; Docker cannot publish detected ports later.
+func WithAutoForwardPorts() StartOption {
+	return func(o *startOptions) {
+		o.AutoForwardPorts = true
+	}
+}
+
+// WithStdio sets the streams used by interactive runs; nil keeps the process stream.
+// Impact: Only used when stdio is attached by WithInteractive.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithInteractive(), devcontainer.WithStdio(in, out, errOut))
+//
+// Similar: WithBuildOutput redirects build output rather than the container's.
+func WithStdio(stdin io.Reader, stdout, stderr io.Writer) StartOption {
+	return func(o *startOptions) {
+		o.Stdin = stdin
+		o.Stdout = stdout
+		o.Stderr = stderr
+	}
+}
+
 // WithTTYValue sets the TTY allocation flag explicitly.
 // Impact: When false, Tty is disabled on the container.
 // Example:
@@ -238,8 +842,23 @@ func WithTimeout(timeout time.Duration) StartOption {
 	}
 }
 
+// WithFeatureResolveTimeout bounds the feature resolution phase.
+// Impact: Fetching and parsing every feature shares one deadline, so an unreachable registry fails the start
+// with a "feature resolution timed out" error instead of consuming the overall timeout.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithFeatureResolveTimeout(30*time.Second))
+//
+// Similar: WithTimeout bounds the whole start, including feature resolution.
+func WithFeatureResolveTimeout(timeout time.Duration) StartOption {
+	return func(o *startOptions) {
+		o.FeatureTimeout = timeout
+	}
+}
+
 // WithResources sets CPU and memory limits.
-// Impact: Docker HostConfig CPUQuota/Memory are set, enabling resource limits.
+// Impact: Docker HostConfig CPUQuota, CPUShares, Memory, MemoryReservation and PidsLimit are set from the non-zero fields,
+// replacing limits from earlier resource options.
 // Example:
 //
 //	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithResources(devcontainer.ResourceLimits{Memory: "1g"}))
@@ -251,6 +870,153 @@ func WithResources(resources ResourceLimits) StartOption {
 	}
 }
 
+// WithMemoryReservation sets the soft memory limit, such as "512m", that Docker reclaims down to under host memory pressure.
+// Impact: HostConfig.MemoryReservation is set and takes precedence over --memory-reservation in runArgs; an invalid
+// size fails the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithMemoryReservation("512m"))
+//
+// Similar: WithResources sets the hard Memory limit, which the container can never exceed.
+func WithMemoryReservation(size string) StartOption {
+	return func(o *startOptions) {
+		if _, err := parseMemoryReservation(size); err != nil {
+			o.Errs = append(o.Errs, err)
+			return
+		}
+		o.Resources.MemoryReservation = size
+	}
+}
+
+// WithCPUShares sets the relative CPU weight (Docker's default is 1024) used when containers compete for CPU.
+// Impact: HostConfig.CPUShares is set and takes precedence over --cpu-shares in runArgs; a negative value fails the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithCPUShares(512))
+//
+// Similar: WithResources sets CPUQuota, a hard cap that applies even when the host is idle.
+func WithCPUShares(shares int64) StartOption {
+	return func(o *startOptions) {
+		if shares < 0 {
+			o.Errs = append(o.Errs, fmt.Errorf("invalid cpu shares: %d", shares))
+			return
+		}
+		o.Resources.CPUShares = shares
+	}
+}
+
+// WithPidsLimit caps the number of processes in the container, guarding shared hosts against fork bombs.
+// Impact: HostConfig.PidsLimit is set and takes precedence over --pids-limit in runArgs; a limit below 1 fails the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithPidsLimit(512))
+//
+// Similar: WithResources limits CPU and memory rather than process count.
+func WithPidsLimit(limit int64) StartOption {
+	return func(o *startOptions) {
+		if err := validatePidsLimit(limit); err != nil {
+			o.Errs = append(o.Errs, err)
+			return
+		}
+		o.Resources.PidsLimit = limit
+	}
+}
+
+// WithoutPreflight skips the Docker daemon ping performed once the config is loaded, before any Docker work starts.
+// Impact: Connection failures then surface from the first Docker call instead of as "docker daemon unreachable".
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithoutPreflight())
+//
+// Similar: WithTimeout bounds the whole start, including the preflight ping.
+func WithoutPreflight() StartOption {
+	return func(o *startOptions) {
+		o.SkipPreflight = true
+	}
+}
+
+// WithHostname sets the container hostname.
+// Impact: It overrides --hostname from runArgs; in compose mode it sets the primary service's hostname.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithHostname("devbox"))
+//
+// Similar: WithName sets the container name, which Docker does not use as the hostname.
+func WithHostname(hostname string) StartOption {
+	return func(o *startOptions) {
+		o.Hostname = hostname
+	}
+}
+
+// WithStopSignal sets the signal used to stop the container, such as "SIGINT".
+// Impact: It is recorded on the container at create time, so StopDevcontainer and docker stop send it; invalid names fail the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithStopSignal("SIGINT"))
+//
+// Similar: WithStopTimeout controls how long Docker waits after the signal before killing the container.
+func WithStopSignal(signal string) StartOption {
+	return func(o *startOptions) {
+		parsed, err := parseStopSignal(signal)
+		if err != nil {
+			o.Errs = append(o.Errs, err)
+			return
+		}
+		o.StopSignal = parsed
+	}
+}
+
+// WithEntrypoint replaces the image entrypoint, like docker run --entrypoint; an empty slice clears it.
+// Impact: The keep-alive command used with overrideCommand, or nothing when overrideCommand is false, becomes its
+// arguments, so an image entrypoint that would swallow or replace the keep-alive loop can be bypassed. Feature
+// entrypoints still run first and then exec this entrypoint. It takes precedence over an --entrypoint runArg.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithEntrypoint([]string{"/bin/sh", "-c", "exec \"$@\"", "sh"}))
+//
+// Similar: WithKeepAliveCommand replaces the command instead of the entrypoint.
+func WithEntrypoint(entrypoint []string) StartOption {
+	return func(o *startOptions) {
+		o.Entrypoint = append([]string{}, entrypoint...)
+	}
+}
+
+// WithKeepAliveCommand sets the command that keeps the container running when overrideCommand is true.
+// Impact: It replaces the default shell sleep loop, so images without a POSIX shell can stay up; an empty command
+// fails the start. The default loop needs the shell the image names, or /bin/sh, and a start that finds neither
+// fails before the container runs; Docker Compose services always run it under /bin/sh.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithKeepAliveCommand([]string{"/pause"}))
+//
+// Similar: Setting overrideCommand to false in devcontainer.json runs the image's own command instead.
+func WithKeepAliveCommand(command []string) StartOption {
+	return func(o *startOptions) {
+		if len(command) == 0 || command[0] == "" {
+			o.Errs = append(o.Errs, errors.New("keep-alive command cannot be empty"))
+			return
+		}
+		o.KeepAlive = append([]string{}, command...)
+	}
+}
+
+// WithStopTimeout sets the container's default stop grace period.
+// Impact: It is recorded at create time and used when StopDevcontainer is called without a timeout.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithStopTimeout(30*time.Second))
+//
+// Similar: The timeout argument of StopDevcontainer overrides it for a single stop.
+func WithStopTimeout(timeout time.Duration) StartOption {
+	return func(o *startOptions) {
+		if timeout < 0 {
+			o.Errs = append(o.Errs, fmt.Errorf("stop timeout must not be negative: %s", timeout))
+			return
+		}
+		o.StopTimeout = timeout
+	}
+}
+
 // WithWorkdir overrides the container working directory.
 // Impact: It takes precedence over workspaceFolder from devcontainer.json.
 // Example:
@@ -276,3 +1042,320 @@ func WithNetwork(network string) StartOption {
 		o.Network = network
 	}
 }
+
+// WithBuildContext overrides the Docker build context directory.
+// Impact: The directory replaces build.context from devcontainer.json, and the Dockerfile must be inside it. A relative dir
+// resolves against the current working directory, not the config directory. Compose configs reject it.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithBuildContext("/src/app"))
+//
+// Similar: build.context is static in the config file and resolves against the config directory, while WithBuildContext is
+// a runtime override.
+func WithBuildContext(dir string) StartOption {
+	return func(o *startOptions) {
+		o.BuildContext = dir
+	}
+}
+
+// WithBuildTarget overrides the multi-stage build target.
+// Impact: The stage replaces build.target from devcontainer.json when the image is built.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithBuildTarget("dev"))
+//
+// Similar: build.target is static in the config file, while WithBuildTarget is a runtime override.
+func WithBuildTarget(target string) StartOption {
+	return func(o *startOptions) {
+		o.BuildTarget = target
+	}
+}
+
+// WithBuildArg sets one Docker build argument for the devcontainer image build.
+// Impact: It is merged over build.args from devcontainer.json and wins for keys with the same name.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithBuildArg("COMMIT_SHA", sha))
+//
+// Similar: WithEnv sets container environment variables at run time, not build arguments.
+func WithBuildArg(key, value string) StartOption {
+	return func(o *startOptions) {
+		if o.BuildArgs == nil {
+			o.BuildArgs = make(map[string]string)
+		}
+		o.BuildArgs[key] = value
+	}
+}
+
+// WithBuildArgFile reads KEY=VALUE build arguments from a dotenv-style file.
+// Impact: File args override build.args from devcontainer.json and sit beneath WithBuildArg values; later files
+// override earlier ones.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithBuildArgFile("ci.args"))
+//
+// Similar: WithBuildArg sets a single argument that overrides file args.
+func WithBuildArgFile(path string) StartOption {
+	return func(o *startOptions) {
+		args, err := parseDotEnvFile(path)
+		if err != nil {
+			o.Errs = append(o.Errs, fmt.Errorf("build arg file %s: %w", path, err))
+			return
+		}
+		if o.FileBuildArgs == nil {
+			o.FileBuildArgs = make(map[string]string)
+		}
+		for key, value := range args {
+			o.FileBuildArgs[key] = value
+		}
+	}
+}
+
+// WithImageTag tags the final devcontainer image, including any features layer, as tag once it is built.
+// Impact: StartDevcontainer and BuildImageFromDevcontainer add the tag after building so CI can reuse the image
+// elsewhere; an invalid reference fails the start and compose configs reject the option.
+// Example:
+//
+//	id, err := devcontainer.BuildImageFromDevcontainer(ctx, configPath, devcontainer.WithImageTag("ghcr.io/org/app-dev:main"))
+//
+// Similar: WithPushImage also pushes the tag to its registry.
+func WithImageTag(tag string) StartOption {
+	return func(o *startOptions) {
+		if _, err := reference.ParseNormalizedNamed(tag); err != nil {
+			o.Errs = append(o.Errs, fmt.Errorf("invalid image tag %q: %w", tag, err))
+			return
+		}
+		o.ImageTag = tag
+	}
+}
+
+// WithRegistryAuth sets the credentials used to pull and push images on registry, such as "ghcr.io".
+// Impact: They are sent with the image pulls godev makes itself, including the base image of compose features, and
+// WithPushImage pushes, taking precedence over ~/.docker/config.json for that registry; "docker.io" means Docker Hub.
+// Credential helpers are not consulted either way. Other compose service images are pulled by docker compose up,
+// which uses the docker CLI login instead.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithRegistryAuth("ghcr.io", "ci", os.Getenv("GHCR_TOKEN")))
+//
+// Similar: Without this option credentials come from the auths section of the docker config, or none are sent.
+func WithRegistryAuth(registryHost, username, password string) StartOption {
+	return func(o *startOptions) {
+		if strings.TrimSpace(registryHost) == "" {
+			o.Errs = append(o.Errs, errors.New("registry auth requires a registry host"))
+			return
+		}
+		if o.RegistryAuth == nil {
+			o.RegistryAuth = make(registryAuths)
+		}
+		o.RegistryAuth[registryAuthKey(strings.TrimSpace(registryHost))] = registryAuth{username: username, password: password}
+	}
+}
+
+// WithPushImage pushes the WithImageTag tag to its registry after tagging.
+// Impact: WithRegistryAuth credentials for the tag's registry win over the Docker config.json, which is used otherwise;
+// a failed push fails the start or build.
+// Example:
+//
+//	id, err := devcontainer.BuildImageFromDevcontainer(ctx, configPath, devcontainer.WithImageTag("ghcr.io/org/app-dev:main"), devcontainer.WithPushImage())
+//
+// Similar: WithImageTag alone keeps the tag local.
+func WithPushImage() StartOption {
+	return func(o *startOptions) {
+		o.PushImage = true
+	}
+}
+
+// WithNoBuildCache rebuilds images without reusing cached layers.
+// Impact: Both the devcontainer image build and the features image build run with NoCache, and an unchanged features
+// image is rebuilt instead of reused; build.cacheFrom is still passed.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithNoBuildCache())
+//
+// Similar: WithBuildTarget changes which stage is built, not whether the cache is used.
+func WithNoBuildCache() StartOption {
+	return func(o *startOptions) {
+		o.NoBuildCache = true
+	}
+}
+
+// WithPullBuildBase pulls newer versions of the Dockerfile's FROM images before building.
+// Impact: The devcontainer image build runs with PullParent, so long-lived build caches do not keep a stale base;
+// the features image is built on the local result and is not affected.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithPullBuildBase())
+//
+// Similar: WithNoBuildCache also rebuilds every layer; image-based configs are always pulled.
+func WithPullBuildBase() StartOption {
+	return func(o *startOptions) {
+		o.PullBuildBase = true
+	}
+}
+
+// WithBuildNetwork sets the network mode for RUN steps during image builds.
+// Impact: Both the devcontainer image build and the features image build run with this NetworkMode, such as
+// "host" when RUN steps must reach services on the host.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithBuildNetwork("host"))
+//
+// Similar: WithNetwork sets the network of the running container, not the build.
+func WithBuildNetwork(network string) StartOption {
+	return func(o *startOptions) {
+		o.BuildNetwork = network
+	}
+}
+
+// WithBuildExtraHost maps host to ip in /etc/hosts for RUN steps during image builds.
+// Impact: Both the devcontainer image build and the features image build receive the entry, so RUN steps can
+// resolve internal mirrors; ip may be an address or "host-gateway", and anything else fails the start.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithBuildExtraHost("mirror.internal", "10.0.0.5"))
+//
+// Similar: WithBuildNetwork changes the build network instead of name resolution.
+func WithBuildExtraHost(host, ip string) StartOption {
+	return func(o *startOptions) {
+		entry, err := parseExtraHost(host, ip)
+		if err != nil {
+			o.Errs = append(o.Errs, err)
+			return
+		}
+		o.BuildExtraHosts = append(o.BuildExtraHosts, entry)
+	}
+}
+
+// WithComposeFileDiscovery enables compose file auto-discovery.
+// Impact: A sibling compose override file is included automatically, and COMPOSE_FILE is used when dockerComposeFile is empty.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithComposeFileDiscovery())
+//
+// Similar: dockerComposeFile lists files explicitly, while WithComposeFileDiscovery mirrors docker compose defaults.
+func WithComposeFileDiscovery() StartOption {
+	return func(o *startOptions) {
+		o.ComposeDiscovery = true
+	}
+}
+
+// WithHookServices runs a user lifecycle hook on additional compose services.
+// Impact: After the hook runs on the primary service, it also runs in each listed service container; feature hooks and entrypoints stay on the primary.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithHookServices("postStartCommand", "db"))
+//
+// Similar: runServices selects which services start, while WithHookServices selects where a hook runs.
+func WithHookServices(hook string, services ...string) StartOption {
+	return func(o *startOptions) {
+		if o.HookServices == nil {
+			o.HookServices = make(map[string][]string)
+		}
+		o.HookServices[hook] = appendUnique(o.HookServices[hook], services...)
+	}
+}
+
+// WithName overrides the container name, or the project name in docker compose mode.
+// Impact: It takes precedence over COMPOSE_PROJECT_NAME and the name field from devcontainer.json.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithName("feature-branch"))
+//
+// Similar: The name field in devcontainer.json is static, while WithName allows multiple stacks per workspace.
+func WithName(name string) StartOption {
+	return func(o *startOptions) {
+		o.Name = name
+	}
+}
+
+// WithComposeOutput streams docker compose up output to w.
+// Impact: Compose progress is written live instead of only being buffered for error reporting.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithComposeOutput(os.Stderr))
+//
+// Similar: Errors from compose always include buffered output, while WithComposeOutput shows it as it happens.
+func WithComposeOutput(w io.Writer) StartOption {
+	return func(o *startOptions) {
+		o.ComposeOutput = w
+	}
+}
+
+// WithPersistentComposeOverride writes the compose override to <workspaceRoot>/.devcontainer/.godev2-compose-override-<project>.yml.
+// Impact: The override is kept for the container's lifetime so it can be inspected and reused by stop/down; each compose
+// project name gets its own file. Add the files to .gitignore.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithPersistentComposeOverride())
+//
+// Similar: Without this option the override is written to a temporary file and removed after start.
+func WithPersistentComposeOverride() StartOption {
+	return func(o *startOptions) {
+		o.PersistOverride = true
+	}
+}
+
+// WithKeepComposeOverride leaves the temporary compose override file on disk instead of removing it after start.
+// Impact: The path is logged at info level and returned in StartResult.ComposeOverride so the generated YAML can be
+// inspected when a start misbehaves; the file is never cleaned up by godev and stop/down do not use it.
+// Example:
+//
+//	result, err := devcontainer.StartDevcontainerResult(ctx, devcontainer.WithKeepComposeOverride())
+//
+// Similar: WithPersistentComposeOverride writes a fixed file under .devcontainer that stop/down reuse.
+func WithKeepComposeOverride() StartOption {
+	return func(o *startOptions) {
+		o.KeepOverride = true
+	}
+}
+
+// WithKeepOnFailure leaves a Docker Compose stack running when start fails or ctx is canceled after docker compose up.
+// Impact: By default a stack that this start created is brought down again (volumes are kept); with this option it
+// stays up for debugging and the container ID is returned alongside the error. A stack that already had containers
+// before docker compose up is never brought down.
+// Example:
+//
+//	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithKeepOnFailure())
+//
+// Similar: A single-container start always leaves its container in place on failure.
+func WithKeepOnFailure() StartOption {
+	return func(o *startOptions) {
+		o.KeepOnFailure = true
+	}
+}
+
+type RemoveOption func(*removeOptions)
+
+// removeOptions holds RemoveDevcontainer configuration derived from RemoveOption values.
+type removeOptions struct {
+	RemoveVolumes bool // RemoveVolumes also deletes named volumes: compose volumes on down, or labeled volumes the container mounts.
+	RequireExists bool // RequireExists reports a missing container as an error instead of success.
+}
+
+// WithRemoveVolumes makes RemoveDevcontainer delete named volumes as well.
+// Impact: docker compose down runs with --volumes; for single containers, the named volumes the container mounts
+// that were labeled with devcontainer.config_path when Docker created them are removed, so their data is lost.
+// Example:
+//
+//	err := devcontainer.RemoveDevcontainer(ctx, containerID, devcontainer.WithRemoveVolumes())
+//
+// Similar: Without this option compose down keeps volumes, while single containers always drop anonymous volumes.
+func WithRemoveVolumes() RemoveOption {
+	return func(o *removeOptions) {
+		o.RemoveVolumes = true
+	}
+}
+
+// WithRequireExisting makes RemoveDevcontainer fail when the container does not exist.
+// Impact: Docker's not-found error is returned instead of treating the container as already removed.
+// Example:
+//
+//	err := devcontainer.RemoveDevcontainer(ctx, containerID, devcontainer.WithRequireExisting())
+//
+// Similar: Without this option RemoveDevcontainer is idempotent, like StopDevcontainer always is.
+func WithRequireExisting() RemoveOption {
+	return func(o *removeOptions) {
+		o.RequireExists = true
+	}
+}
@@ -1,6 +1,9 @@
 package godev
 
 import (
+	"bytes"
+	"log/slog"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -28,6 +31,25 @@ func TestStartOptionHelpers(t *testing.T) {
 	WithResources(ResourceLimits{CPUQuota: 100, Memory: "128m"})(&options)
 	WithWorkdir("/work")(&options)
 	WithNetwork("host")(&options)
+	WithBuildContext("/src")(&options)
+	WithBuildTarget("dev")(&options)
+	WithComposeFileDiscovery()(&options)
+	WithHookServices("postStartCommand", "db", "db")(&options)
+	WithName("stack")(&options)
+	output := &bytes.Buffer{}
+	WithComposeOutput(output)(&options)
+	WithPersistentComposeOverride()(&options)
+	WithPlatform("linux/arm64")(&options)
+	WithValidateBindSources()(&options)
+	WithReadOnlyRootfs()(&options)
+	WithNoNewPrivileges()(&options)
+	WithWaitForHealthy(30 * time.Second)(&options)
+	WithToolingLabels()(&options)
+	WithStrictFeatureVersions()(&options)
+	logger := slog.New(slog.DiscardHandler)
+	WithLogger(logger)(&options)
+	buildOutput := &bytes.Buffer{}
+	WithBuildOutput(buildOutput)(&options)
 
 	if options.ConfigPath != "devcontainer.json" {
 		t.Fatalf("unexpected config path: %s", options.ConfigPath)
@@ -74,4 +96,98 @@ func TestStartOptionHelpers(t *testing.T) {
 	if options.Network != "host" {
 		t.Fatalf("unexpected network: %s", options.Network)
 	}
+	if options.BuildContext != "/src" {
+		t.Fatalf("unexpected build context: %s", options.BuildContext)
+	}
+	if options.BuildTarget != "dev" {
+		t.Fatalf("unexpected build target: %s", options.BuildTarget)
+	}
+	if !options.ComposeDiscovery {
+		t.Fatalf("expected compose discovery true")
+	}
+	if len(options.HookServices["postStartCommand"]) != 1 || options.HookServices["postStartCommand"][0] != "db" {
+		t.Fatalf("unexpected hook services: %#v", options.HookServices)
+	}
+	if options.Name != "stack" {
+		t.Fatalf("unexpected name: %s", options.Name)
+	}
+	if options.Platform != "linux/arm64" {
+		t.Fatalf("unexpected platform: %s", options.Platform)
+	}
+	if !options.ValidateBinds {
+		t.Fatalf("expected bind source validation")
+	}
+	if !options.ReadOnlyRootfs {
+		t.Fatalf("expected read-only rootfs")
+	}
+	if !options.NoNewPrivileges {
+		t.Fatalf("expected no-new-privileges")
+	}
+	if options.HealthyTimeout != 30*time.Second {
+		t.Fatalf("unexpected healthy timeout: %s", options.HealthyTimeout)
+	}
+	if !options.ToolingLabels {
+		t.Fatalf("expected tooling labels")
+	}
+	if !options.StrictFeatures {
+		t.Fatalf("expected strict feature versions")
+	}
+	if options.Logger != logger {
+		t.Fatalf("unexpected logger: %#v", options.Logger)
+	}
+	if options.BuildOutput != buildOutput {
+		t.Fatalf("unexpected build output: %#v", options.BuildOutput)
+	}
+	if options.ComposeOutput != output {
+		t.Fatalf("unexpected compose output: %#v", options.ComposeOutput)
+	}
+	if !options.PersistOverride {
+		t.Fatalf("expected persistent compose override")
+	}
+}
+
+func TestStartOptionHelpers_RejectInvalidTimeouts(t *testing.T) {
+	tests := map[string]StartOption{
+		"zero healthy timeout":     WithWaitForHealthy(0),
+		"negative healthy timeout": WithWaitForHealthy(-time.Second),
+		"negative stop timeout":    WithStopTimeout(-time.Second),
+	}
+	for name, opt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := applyStartOptions([]StartOption{opt}); err == nil {
+				t.Fatal("expected an invalid timeout to fail")
+			}
+		})
+	}
+}
+
+func TestRemoveOptionHelpers(t *testing.T) {
+	options := removeOptions{}
+	if options.RemoveVolumes {
+		t.Fatal("expected volumes to be kept by default")
+	}
+	WithRemoveVolumes()(&options)
+	if !options.RemoveVolumes {
+		t.Fatal("expected remove volumes")
+	}
+}
+
+func TestWithMountSpec(t *testing.T) {
+	options := defaultStartOptions()
+	WithMountSpec("type=bind,source=/tmp,target=/work,readonly")(&options)
+	if len(options.Errs) != 0 {
+		t.Fatalf("unexpected errors: %v", options.Errs)
+	}
+	expected := []Mount{{Source: "/tmp", Target: "/work", Type: "bind", ReadOnly: true}}
+	if !reflect.DeepEqual(options.ExtraMounts, expected) {
+		t.Fatalf("unexpected mounts: %#v", options.ExtraMounts)
+	}
+
+	WithMountSpec("type=bind,source=/tmp")(&options)
+	if len(options.Errs) != 1 {
+		t.Fatalf("expected one parse error, got %v", options.Errs)
+	}
+	if len(options.ExtraMounts) != 1 {
+		t.Fatalf("invalid spec should not add a mount: %#v", options.ExtraMounts)
+	}
 }
@@ -3,9 +3,12 @@ package godev
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/csv"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
@@ -16,6 +19,7 @@ import (
 
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
 )
 
 func stripJSONComments(input []byte) ([]byte, error) {
@@ -104,7 +108,7 @@ func resolveWorkspacePaths(configPath string, cfg *DevcontainerConfig) (string,
 
 	workspaceMount := cfg.WorkspaceMount
 	if workspaceMount == "" {
-		workspaceMount = fmt.Sprintf("source=%s,target=%s,type=bind", workspaceRoot, workspaceFolder)
+		workspaceMount = strings.Join([]string{mountOption("source", workspaceRoot), mountOption("target", workspaceFolder), "type=bind"}, ",")
 	}
 
 	devcontainerID := devcontainerID(workspaceRoot, absConfig)
@@ -118,15 +122,42 @@ func resolveWorkspacePaths(configPath string, cfg *DevcontainerConfig) (string,
 	return workspaceRoot, workspaceFolder, workspaceMount, vars, nil
 }
 
+// devcontainerID derives the default ${devcontainerId}: the first 8 bytes of
+// sha256(workspaceRoot + "::" + configPath), hex encoded. It changes when either path moves;
+// WithDevcontainerID pins it instead.
 func devcontainerID(workspaceRoot, configPath string) string {
 	sum := sha256.Sum256([]byte(workspaceRoot + "::" + configPath))
 	return hex.EncodeToString(sum[:8])
 }
 
+var devcontainerIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// overrideDevcontainerID replaces the path-derived devcontainerId when id is set.
+func overrideDevcontainerID(vars map[string]string, id string) {
+	if id != "" {
+		vars["devcontainerId"] = id
+	}
+}
+
 var variablePattern = regexp.MustCompile(`\$\{([^}]+)\}`)
 
+// escapedVariablePattern also matches the $$ escape for a literal dollar sign.
+var escapedVariablePattern = regexp.MustCompile(`\$\$|\$\{([^}]+)\}`)
+
+// expandVariables substitutes ${...} references and leaves every other "$" alone, so shell syntax such as
+// "$$" in lifecycle commands reaches the container unchanged.
 func expandVariables(input string, vars map[string]string, containerEnv map[string]string) (string, error) {
-	matches := variablePattern.FindAllStringSubmatchIndex(input, -1)
+	return expandMatches(input, variablePattern, vars, containerEnv)
+}
+
+// expandEnvValue substitutes ${...} references in containerEnv and remoteEnv values.
+// "$$" emits a literal "$", so "$${name}" is kept as "${name}".
+func expandEnvValue(input string, vars map[string]string, containerEnv map[string]string) (string, error) {
+	return expandMatches(input, escapedVariablePattern, vars, containerEnv)
+}
+
+func expandMatches(input string, pattern *regexp.Regexp, vars map[string]string, containerEnv map[string]string) (string, error) {
+	matches := pattern.FindAllStringSubmatchIndex(input, -1)
 	if len(matches) == 0 {
 		return input, nil
 	}
@@ -134,13 +165,17 @@ func expandVariables(input string, vars map[string]string, containerEnv map[stri
 	last := 0
 	for _, match := range matches {
 		out.WriteString(input[last:match[0]])
+		last = match[1]
+		if match[2] < 0 {
+			out.WriteByte('$')
+			continue
+		}
 		token := input[match[2]:match[3]]
 		value, err := resolveVariable(token, vars, containerEnv)
 		if err != nil {
 			return "", err
 		}
 		out.WriteString(value)
-		last = match[1]
 	}
 	out.WriteString(input[last:])
 	return out.String(), nil
@@ -181,14 +216,14 @@ func resolveEnvVariable(token string) (string, error) {
 func mergeEnvMaps(base, overlay map[string]string, vars map[string]string) (map[string]string, error) {
 	merged := make(map[string]string)
 	for key, value := range base {
-		expanded, err := expandVariables(value, vars, merged)
+		expanded, err := expandEnvValue(value, vars, merged)
 		if err != nil {
 			return nil, err
 		}
 		merged[key] = expanded
 	}
 	for key, value := range overlay {
-		expanded, err := expandVariables(value, vars, merged)
+		expanded, err := expandEnvValue(value, vars, merged)
 		if err != nil {
 			return nil, err
 		}
@@ -197,6 +232,19 @@ func mergeEnvMaps(base, overlay map[string]string, vars map[string]string) (map[
 	return merged, nil
 }
 
+// passthroughEnv copies the host value of each key into envMap unless explicit sets the key;
+// keys unset on the host keep their configured value.
+func passthroughEnv(envMap map[string]string, keys []string, explicit map[string]string) {
+	for _, key := range keys {
+		if _, ok := explicit[key]; ok {
+			continue
+		}
+		if value, ok := os.LookupEnv(key); ok {
+			envMap[key] = value
+		}
+	}
+}
+
 func envMapToSlice(envMap map[string]string) []string {
 	if len(envMap) == 0 {
 		return nil
@@ -213,20 +261,23 @@ func envMapToSlice(envMap map[string]string) []string {
 	return env
 }
 
-func collectPortSpecs(configPorts, appPorts PortList, extra []string) ([]string, error) {
+func collectPortSpecs(configPorts, appPorts PortList, extra []string, ephemeralApp bool) ([]string, error) {
 	specs := make([]string, 0, len(configPorts)+len(appPorts)+len(extra))
-	for _, item := range append(append([]string{}, configPorts...), appPorts...) {
-		normalized, err := normalizePortSpec(item)
-		if err != nil {
-			return nil, err
+	seen := make(map[string]struct{}, cap(specs))
+	for i, item := range append(append(append([]string{}, configPorts...), appPorts...), extra...) {
+		normalize := normalizePortSpec
+		if ephemeralApp && i >= len(configPorts) && i < len(configPorts)+len(appPorts) {
+			normalize = ephemeralPortSpec
 		}
-		specs = append(specs, normalized)
-	}
-	for _, item := range extra {
-		normalized, err := normalizePortSpec(item)
+		normalized, err := normalize(item)
 		if err != nil {
 			return nil, err
 		}
+		// The same port listed twice (e.g. in forwardPorts and WithExtraPublish) would otherwise bind the host port twice.
+		if _, ok := seen[normalized]; ok {
+			continue
+		}
+		seen[normalized] = struct{}{}
 		specs = append(specs, normalized)
 	}
 	return specs, nil
@@ -236,6 +287,9 @@ func normalizePortSpec(spec string) (string, error) {
 	if spec == "" {
 		return "", errors.New("empty port spec")
 	}
+	if strings.HasPrefix(spec, "[") {
+		return normalizeIPv6PortSpec(spec)
+	}
 	if strings.Contains(spec, ":") {
 		parts := strings.SplitN(spec, ":", 2)
 		if parts[0] != "" {
@@ -261,6 +315,66 @@ func normalizePortSpec(spec string) (string, error) {
 	return fmt.Sprintf("%s:%s/%s", port, port, proto), nil
 }
 
+// normalizeIPv6PortSpec validates a "[addr]:host:container[/proto]" spec and returns it unchanged for nat.ParsePortSpecs.
+// The bracketed address is split off first because its colons would otherwise be taken as field separators.
+func normalizeIPv6PortSpec(spec string) (string, error) {
+	end := strings.Index(spec, "]")
+	if end < 0 {
+		return "", fmt.Errorf("unterminated IPv6 address in port spec: %s", spec)
+	}
+	if ip := net.ParseIP(spec[1:end]); ip == nil || ip.To4() != nil {
+		return "", fmt.Errorf("invalid IPv6 address in port spec: %s", spec)
+	}
+	rest, ok := strings.CutPrefix(spec[end+1:], ":")
+	if !ok {
+		return "", fmt.Errorf("invalid port spec: %s", spec)
+	}
+	hostPort, containerPort, ok := strings.Cut(rest, ":")
+	if !ok || containerPort == "" {
+		return "", fmt.Errorf("IPv6 port spec requires host and container ports: %s", spec)
+	}
+	if hostPort != "" {
+		if _, err := strconv.Atoi(hostPort); err != nil {
+			return "", fmt.Errorf("invalid host port in port spec: %s", spec)
+		}
+	}
+	return spec, nil
+}
+
+// ephemeralPortSpec is normalizePortSpec except that a bare port such as "3000" becomes ":3000", leaving the host
+// port empty so Docker assigns a free one.
+func ephemeralPortSpec(spec string) (string, error) {
+	if spec != "" && !strings.Contains(spec, ":") {
+		spec = ":" + spec
+	}
+	return normalizePortSpec(spec)
+}
+
+// checkPortPublished reports an error unless bindings already publish every port in spec.
+func checkPortPublished(bindings nat.PortMap, spec string) error {
+	normalized, err := normalizePortSpec(spec)
+	if err != nil {
+		return err
+	}
+	mappings, err := nat.ParsePortSpec(normalized)
+	if err != nil {
+		return err
+	}
+	for _, mapping := range mappings {
+		published := false
+		for _, binding := range bindings[mapping.Port] {
+			if mapping.Binding.HostPort == "" || binding.HostPort == mapping.Binding.HostPort {
+				published = true
+				break
+			}
+		}
+		if !published {
+			return fmt.Errorf("port %s is not published and Docker cannot publish ports on a running container; recreate the container with WithExtraPublish(%q) or forwardPorts", mapping.Port, spec)
+		}
+	}
+	return nil
+}
+
 func parsePortSpecs(specs []string) (nat.PortSet, nat.PortMap, error) {
 	if len(specs) == 0 {
 		return nil, nil, nil
@@ -272,8 +386,39 @@ func parsePortSpecs(specs []string) (nat.PortSet, nat.PortMap, error) {
 	return exposed, bindings, nil
 }
 
+// mountOption formats key=value for a mount string, quoting it as a CSV field when value contains a comma or quote.
+func mountOption(key, value string) string {
+	option := key + "=" + value
+	if !strings.ContainsAny(value, ",\"") {
+		return option
+	}
+	return `"` + strings.ReplaceAll(option, `"`, `""`) + `"`
+}
+
+// parseExtraHost validates an /etc/hosts mapping and returns it in Docker's host:ip form.
+func parseExtraHost(host, ip string) (string, error) {
+	host = strings.TrimSpace(host)
+	ip = strings.TrimSpace(ip)
+	if host == "" || strings.ContainsAny(host, ": \t") {
+		return "", fmt.Errorf("invalid extra host name: %q", host)
+	}
+	if ip != "host-gateway" && net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("invalid extra host address for %s: %q", host, ip)
+	}
+	return host + ":" + ip, nil
+}
+
+// parseMountString parses a Docker --mount string. Like the Docker CLI it reads the options as CSV,
+// so a field holding a comma is written quoted, as in "source=/src/a,b",target=/work.
+// A spec without "=" is read as the compose-style source:target[:options] shorthand.
 func parseMountString(spec string) (mount.Mount, error) {
-	parts := strings.Split(spec, ",")
+	if isMountShorthand(spec) {
+		return parseMountShorthand(spec)
+	}
+	parts, err := csv.NewReader(strings.NewReader(spec)).Read()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return mount.Mount{}, fmt.Errorf("invalid mount %s: %w", spec, err)
+	}
 	var result mount.Mount
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
@@ -309,11 +454,60 @@ func parseMountString(spec string) (mount.Mount, error) {
 	if result.Target == "" {
 		return mount.Mount{}, errors.New("mount target is required")
 	}
+	if err := validateMountTarget(result.Target, spec); err != nil {
+		return mount.Mount{}, err
+	}
 	return result, nil
 }
 
+// isMountShorthand reports whether spec uses source:target rather than key=value options.
+func isMountShorthand(spec string) bool {
+	return !strings.Contains(spec, "=") && strings.Contains(spec, ":")
+}
+
+// parseMountShorthand parses "vol:/data" or "./src:/app:ro". A source starting with "/" or "." is a bind
+// mount and anything else names a volume; options are ro, rw, or a consistency mode, comma separated.
+func parseMountShorthand(spec string) (mount.Mount, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return mount.Mount{}, fmt.Errorf("invalid mount shorthand: %s", spec)
+	}
+	result := mount.Mount{Type: mount.TypeVolume, Source: parts[0], Target: parts[1]}
+	if strings.HasPrefix(result.Source, "/") || strings.HasPrefix(result.Source, ".") {
+		result.Type = mount.TypeBind
+	}
+	if len(parts) == 3 {
+		for _, option := range strings.Split(parts[2], ",") {
+			switch option {
+			case "ro":
+				result.ReadOnly = true
+			case "rw":
+				result.ReadOnly = false
+			case "consistent", "cached", "delegated":
+				result.Consistency = mount.Consistency(option)
+			default:
+				return mount.Mount{}, fmt.Errorf("unsupported mount shorthand option %s: %s", option, spec)
+			}
+		}
+	}
+	if err := validateMountTarget(result.Target, spec); err != nil {
+		return mount.Mount{}, err
+	}
+	return result, nil
+}
+
+// validateMountTarget rejects relative container paths, which Docker only reports at create time.
+func validateMountTarget(target, spec string) error {
+	if !path.IsAbs(target) {
+		return fmt.Errorf("mount target must be an absolute path: %s", spec)
+	}
+	return nil
+}
+
 // ParseMountSpec converts a Docker --mount string into a Mount.
-// Impact: It validates the string and returns an error when required fields are missing.
+// Impact: It validates the string and returns an error when required fields are missing. A relative bind source in
+// the source:target shorthand is resolved against the local workspace folder when the container starts, as in
+// devcontainer.json mounts; key=value sources are passed to Docker as written.
 // Example:
 //
 //	m, err := devcontainer.ParseMountSpec("type=bind,source=/tmp,target=/work")
@@ -330,6 +524,7 @@ func ParseMountSpec(spec string) (Mount, error) {
 		Type:        string(parsed.Type),
 		ReadOnly:    parsed.ReadOnly,
 		Consistency: string(parsed.Consistency),
+		shorthand:   isMountShorthand(spec),
 	}, nil
 }
 
@@ -340,6 +535,9 @@ func mountFromSpec(spec MountSpec) (mount.Mount, error) {
 	if spec.Type == "" || spec.Target == "" {
 		return mount.Mount{}, errors.New("mount requires type and target")
 	}
+	if err := validateMountTarget(spec.Target, fmt.Sprintf("type=%s,source=%s,target=%s", spec.Type, spec.Source, spec.Target)); err != nil {
+		return mount.Mount{}, err
+	}
 	return mount.Mount{
 		Type:   mount.Type(spec.Type),
 		Source: spec.Source,
@@ -351,6 +549,9 @@ func toDockerMount(m Mount) (mount.Mount, error) {
 	if m.Target == "" {
 		return mount.Mount{}, errors.New("mount target is required")
 	}
+	if err := validateMountTarget(m.Target, fmt.Sprintf("type=%s,source=%s,target=%s", m.Type, m.Source, m.Target)); err != nil {
+		return mount.Mount{}, err
+	}
 	mountType := mount.Type(m.Type)
 	if mountType == "" {
 		mountType = mount.TypeVolume
@@ -367,18 +568,48 @@ func toDockerMount(m Mount) (mount.Mount, error) {
 // runArgOptions captures parsed docker run arguments.
 type runArgOptions struct {
 	CapAdd      []string          // CapAdd holds added Linux capabilities.
+	CapDrop     []string          // CapDrop holds dropped Linux capabilities.
 	SecurityOpt []string          // SecurityOpt holds security options.
 	Privileged  bool              // Privileged indicates --privileged was set.
 	Init        bool              // Init indicates --init was set.
+	ReadOnly    bool              // ReadOnly indicates --read-only was set.
 	User        string            // User is the requested user override.
 	Network     string            // Network is the requested network mode.
+	Hostname    string            // Hostname is the requested container hostname.
+	MacAddress  string            // MacAddress is the requested MAC address on the container's network.
+	IPv4Address string            // IPv4Address is the requested IPv4 address on the container's network.
+	IPv6Address string            // IPv6Address is the requested IPv6 address on the container's network.
+	MemoryRes   int64             // MemoryRes is the --memory-reservation soft limit in bytes.
+	CPUShares   int64             // CPUShares is the --cpu-shares relative CPU weight.
+	PidsLimit   int64             // PidsLimit is the --pids-limit cap on processes in the container.
+	Entrypoint  []string          // Entrypoint is the --entrypoint override; [""] clears the image entrypoint.
 	Labels      map[string]string // Labels holds parsed Docker labels.
+	Skipped     []string          // Skipped lists unsupported runArgs, with any value, dropped under WithIgnoreUnknownRunArgs.
 }
 
 func parseRunArgs(args []string) (runArgOptions, error) {
+	return parseRestrictedRunArgs(args, nil, false)
+}
+
+// parseRestrictedRunArgs parses runArgs, rejecting flags missing from allowed; a nil allowed accepts every supported flag.
+// With ignoreUnknown an unsupported flag is recorded in Skipped instead of failing. A separate value is skipped with it
+// when the flag has no "=value" and the next argument does not start with "-".
+func parseRestrictedRunArgs(args []string, allowed []string, ignoreUnknown bool) (runArgOptions, error) {
+	var allowedFlags map[string]struct{}
+	if allowed != nil {
+		allowedFlags = make(map[string]struct{}, len(allowed))
+		for _, flag := range allowed {
+			allowedFlags[runArgFlagName(flag)] = struct{}{}
+		}
+	}
 	var opts runArgOptions
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
+		if allowedFlags != nil {
+			if _, ok := allowedFlags[runArgFlagName(arg)]; !ok {
+				return runArgOptions{}, fmt.Errorf("runArg %s is not allowed", runArgFlagName(arg))
+			}
+		}
 		switch {
 		case strings.HasPrefix(arg, "--cap-add="):
 			opts.CapAdd = append(opts.CapAdd, strings.TrimPrefix(arg, "--cap-add="))
@@ -388,6 +619,14 @@ func parseRunArgs(args []string) (runArgOptions, error) {
 				return runArgOptions{}, err
 			}
 			opts.CapAdd = append(opts.CapAdd, value)
+		case strings.HasPrefix(arg, "--cap-drop="):
+			opts.CapDrop = append(opts.CapDrop, strings.TrimPrefix(arg, "--cap-drop="))
+		case arg == "--cap-drop":
+			value, err := nextRunArgValue(args, &i, arg)
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			opts.CapDrop = append(opts.CapDrop, value)
 		case strings.HasPrefix(arg, "--security-opt="):
 			opts.SecurityOpt = append(opts.SecurityOpt, strings.TrimPrefix(arg, "--security-opt="))
 		case arg == "--security-opt":
@@ -400,6 +639,8 @@ func parseRunArgs(args []string) (runArgOptions, error) {
 			opts.Privileged = true
 		case arg == "--init":
 			opts.Init = true
+		case arg == "--read-only":
+			opts.ReadOnly = true
 		case strings.HasPrefix(arg, "--user="):
 			opts.User = strings.TrimPrefix(arg, "--user=")
 		case arg == "--user" || arg == "-u":
@@ -416,6 +657,79 @@ func parseRunArgs(args []string) (runArgOptions, error) {
 				return runArgOptions{}, err
 			}
 			opts.Network = value
+		case strings.HasPrefix(arg, "--hostname="):
+			opts.Hostname = strings.TrimPrefix(arg, "--hostname=")
+		case arg == "--hostname" || arg == "-h":
+			value, err := nextRunArgValue(args, &i, arg)
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			opts.Hostname = value
+		case strings.HasPrefix(arg, "--mac-address="), arg == "--mac-address":
+			value, err := runArgFlagValue(args, &i, arg, "--mac-address")
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			if err := validateMacAddress(value); err != nil {
+				return runArgOptions{}, err
+			}
+			opts.MacAddress = value
+		case strings.HasPrefix(arg, "--ip="), arg == "--ip":
+			value, err := runArgFlagValue(args, &i, arg, "--ip")
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+				return runArgOptions{}, fmt.Errorf("invalid IPv4 address for --ip: %s", value)
+			}
+			opts.IPv4Address = value
+		case strings.HasPrefix(arg, "--ip6="), arg == "--ip6":
+			value, err := runArgFlagValue(args, &i, arg, "--ip6")
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+				return runArgOptions{}, fmt.Errorf("invalid IPv6 address for --ip6: %s", value)
+			}
+			opts.IPv6Address = value
+		case strings.HasPrefix(arg, "--memory-reservation="), arg == "--memory-reservation":
+			value, err := runArgFlagValue(args, &i, arg, "--memory-reservation")
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			opts.MemoryRes, err = parseMemoryReservation(value)
+			if err != nil {
+				return runArgOptions{}, err
+			}
+		case strings.HasPrefix(arg, "--cpu-shares="), arg == "--cpu-shares":
+			value, err := runArgFlagValue(args, &i, arg, "--cpu-shares")
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			shares, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || shares < 0 {
+				return runArgOptions{}, fmt.Errorf("invalid value for --cpu-shares: %s", value)
+			}
+			opts.CPUShares = shares
+		case strings.HasPrefix(arg, "--pids-limit="), arg == "--pids-limit":
+			value, err := runArgFlagValue(args, &i, arg, "--pids-limit")
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return runArgOptions{}, fmt.Errorf("invalid value for --pids-limit: %s", value)
+			}
+			if err := validatePidsLimit(limit); err != nil {
+				return runArgOptions{}, err
+			}
+			opts.PidsLimit = limit
+		case strings.HasPrefix(arg, "--entrypoint="), arg == "--entrypoint":
+			value, err := runArgFlagValue(args, &i, arg, "--entrypoint")
+			if err != nil {
+				return runArgOptions{}, err
+			}
+			opts.Entrypoint = []string{value}
 		case strings.HasPrefix(arg, "--label="):
 			if err := applyRunArgLabel(&opts, strings.TrimPrefix(arg, "--label=")); err != nil {
 				return runArgOptions{}, err
@@ -429,12 +743,95 @@ func parseRunArgs(args []string) (runArgOptions, error) {
 				return runArgOptions{}, err
 			}
 		default:
-			return runArgOptions{}, fmt.Errorf("unsupported runArg: %s", arg)
+			if !ignoreUnknown {
+				return runArgOptions{}, fmt.Errorf("unsupported runArg: %s", arg)
+			}
+			skipped := arg
+			if !strings.Contains(arg, "=") && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				skipped += " " + args[i]
+			}
+			opts.Skipped = append(opts.Skipped, skipped)
 		}
 	}
 	return opts, nil
 }
 
+// runArgShorthands maps single-letter runArg flags to their long names.
+var runArgShorthands = map[string]string{
+	"-u": "--user",
+	"-h": "--hostname",
+	"-l": "--label",
+}
+
+// runArgFlagName returns the long flag name of a runArg such as "--cap-add=SYS_PTRACE" or "-u".
+func runArgFlagName(arg string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(arg), "=")
+	if long, ok := runArgShorthands[name]; ok {
+		return long
+	}
+	return name
+}
+
+// runArgFlagValue returns the value of flag given either as "--flag=value" or as "--flag value".
+func runArgFlagValue(args []string, index *int, arg, flag string) (string, error) {
+	if arg == flag {
+		return nextRunArgValue(args, index, flag)
+	}
+	return strings.TrimPrefix(arg, flag+"="), nil
+}
+
+var stopSignalNames = map[string]struct{}{
+	"HUP": {}, "INT": {}, "QUIT": {}, "ILL": {}, "TRAP": {}, "ABRT": {}, "IOT": {}, "BUS": {},
+	"FPE": {}, "KILL": {}, "USR1": {}, "SEGV": {}, "USR2": {}, "PIPE": {}, "ALRM": {}, "TERM": {},
+	"STKFLT": {}, "CHLD": {}, "CONT": {}, "STOP": {}, "TSTP": {}, "TTIN": {}, "TTOU": {}, "URG": {},
+	"XCPU": {}, "XFSZ": {}, "VTALRM": {}, "PROF": {}, "WINCH": {}, "IO": {}, "POLL": {}, "PWR": {}, "SYS": {},
+}
+
+var realtimeSignalPattern = regexp.MustCompile(`^RTMIN(\+([1-9]|1[0-5]))?$|^RTMAX(-([1-9]|1[0-5]))?$`)
+
+// parseStopSignal validates a signal name ("SIGINT", "int") or number and returns it in SIGNAME form.
+func parseStopSignal(value string) (string, error) {
+	trimmed := strings.TrimSpace(value)
+	if number, err := strconv.Atoi(trimmed); err == nil {
+		if number < 1 || number > 64 {
+			return "", fmt.Errorf("invalid stop signal: %s", value)
+		}
+		return trimmed, nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(trimmed), "SIG")
+	if _, ok := stopSignalNames[name]; !ok && !realtimeSignalPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid stop signal: %s", value)
+	}
+	return "SIG" + name, nil
+}
+
+// validateMacAddress accepts a 48-bit MAC address such as 02:42:ac:11:00:02.
+func validateMacAddress(value string) error {
+	hw, err := net.ParseMAC(value)
+	if err != nil || len(hw) != 6 {
+		return fmt.Errorf("invalid MAC address for --mac-address: %s", value)
+	}
+	return nil
+}
+
+// parseMemoryReservation converts a size such as "512m" to bytes for HostConfig.MemoryReservation.
+func parseMemoryReservation(value string) (int64, error) {
+	bytes, err := units.RAMInBytes(value)
+	if err != nil || bytes < 0 {
+		return 0, fmt.Errorf("invalid memory reservation: %s", value)
+	}
+	return bytes, nil
+}
+
+// validatePidsLimit rejects limits that would not cap processes; Docker's -1 for "unlimited" is the same as no limit.
+func validatePidsLimit(limit int64) error {
+	if limit <= 0 {
+		return fmt.Errorf("pids limit must be positive: %d", limit)
+	}
+	return nil
+}
+
 func nextRunArgValue(args []string, index *int, flag string) (string, error) {
 	if *index+1 >= len(args) {
 		return "", fmt.Errorf("missing value for %s", flag)
@@ -1,6 +1,8 @@
 package godev
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types/mount"
@@ -27,6 +29,74 @@ func TestNormalizePortSpec(t *testing.T) {
 	}
 }
 
+func TestNormalizePortSpec_IPv6(t *testing.T) {
+	for _, spec := range []string{"[::1]:3000:3000", "[::1]:3000:3000/tcp", "[2001:db8::1]::5353/udp"} {
+		got, err := normalizePortSpec(spec)
+		if err != nil {
+			t.Fatalf("normalizePortSpec(%q): %v", spec, err)
+		}
+		if got != spec {
+			t.Fatalf("normalizePortSpec(%q) = %q, want it unchanged", spec, got)
+		}
+	}
+	_, bindings, err := parsePortSpecs([]string{"[::1]:3000:3000", "[::1]:4000:4000/udp"})
+	if err != nil {
+		t.Fatalf("parsePortSpecs: %v", err)
+	}
+	if got := bindings["3000/tcp"]; len(got) != 1 || got[0].HostIP != "::1" || got[0].HostPort != "3000" {
+		t.Fatalf("unexpected tcp binding: %#v", got)
+	}
+	if got := bindings["4000/udp"]; len(got) != 1 || got[0].HostIP != "::1" || got[0].HostPort != "4000" {
+		t.Fatalf("unexpected udp binding: %#v", got)
+	}
+	for _, spec := range []string{"[::1:3000:3000", "[::1]:3000", "[127.0.0.1]:3000:3000", "[::1]3000:3000", "[::1]:x:3000"} {
+		if _, err := normalizePortSpec(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCollectPortSpecs_AccumulatesExtraPublishes(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithExtraPublish("3000"), WithExtraPublish("5000:5000"), WithExtraPublish("9229/udp")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	specs, err := collectPortSpecs(PortList{"3000"}, PortList{"8080"}, options.ExtraPublish, false)
+	if err != nil {
+		t.Fatalf("collectPortSpecs: %v", err)
+	}
+	want := []string{"3000:3000", "8080:8080", "5000:5000", "9229:9229/udp"}
+	if !reflect.DeepEqual(specs, want) {
+		t.Fatalf("unexpected specs: %v", specs)
+	}
+	_, bindings, err := parsePortSpecs(specs)
+	if err != nil {
+		t.Fatalf("parsePortSpecs: %v", err)
+	}
+	if got := bindings[nat.Port("3000/tcp")]; len(got) != 1 {
+		t.Fatalf("expected a single binding for the repeated port, got %#v", got)
+	}
+}
+
+func TestCheckPortPublished(t *testing.T) {
+	_, bindings, err := parsePortSpecs([]string{"3000:3000", "8080:80"})
+	if err != nil {
+		t.Fatalf("parsePortSpecs: %v", err)
+	}
+	for _, spec := range []string{"3000", "8080:80", ":80"} {
+		if err := checkPortPublished(bindings, spec); err != nil {
+			t.Fatalf("expected %s to be published: %v", spec, err)
+		}
+	}
+	err = checkPortPublished(bindings, "5000")
+	if err == nil || !strings.Contains(err.Error(), "recreate the container") || !strings.Contains(err.Error(), "5000/tcp") {
+		t.Fatalf("expected late publish to be rejected with a recreate hint, got %v", err)
+	}
+	if err := checkPortPublished(bindings, "9090:80"); err == nil {
+		t.Fatal("expected a different host port to be rejected")
+	}
+}
+
 func TestParsePortSpecs(t *testing.T) {
 	exposed, bindings, err := parsePortSpecs([]string{"3000:3000"})
 	if err != nil {
@@ -91,3 +161,69 @@ func TestMountParsingHelpers(t *testing.T) {
 		t.Fatalf("expected error for empty target")
 	}
 }
+
+func TestParseMountString_QuotedFields(t *testing.T) {
+	got, err := parseMountString(`type=bind,"source=/src/a,b",target=/work/my project`)
+	if err != nil {
+		t.Fatalf("parseMountString: %v", err)
+	}
+	if got.Source != "/src/a,b" || got.Target != "/work/my project" {
+		t.Fatalf("unexpected mount: %#v", got)
+	}
+	if mountOption("source", `/src/a"b`) != `"source=/src/a""b"` {
+		t.Fatalf("unexpected quoting: %s", mountOption("source", `/src/a"b`))
+	}
+	if _, err := parseMountString(`type=bind,source="/src,target=/work`); err == nil {
+		t.Fatal("expected error for unbalanced quote")
+	}
+}
+
+func TestParseMountString_Shorthand(t *testing.T) {
+	got, err := parseMountString("./src:/app:ro")
+	if err != nil {
+		t.Fatalf("parseMountString: %v", err)
+	}
+	want := mount.Mount{Type: mount.TypeBind, Source: "./src", Target: "/app", ReadOnly: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected bind mount: %#v", got)
+	}
+	got, err = parseMountString("vol:/data")
+	if err != nil {
+		t.Fatalf("parseMountString: %v", err)
+	}
+	want = mount.Mount{Type: mount.TypeVolume, Source: "vol", Target: "/data"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected volume mount: %#v", got)
+	}
+	if _, err := parseMountString("vol:/data:bogus"); err == nil {
+		t.Fatal("expected error for unknown shorthand option")
+	}
+	if _, err := parseMountString("vol:data"); err == nil {
+		t.Fatal("expected error for relative shorthand target")
+	}
+
+	mounts, err := buildMounts("", []MountSpec{{Raw: "./src:/app:ro"}}, nil, map[string]string{"localWorkspaceFolder": "/home/me/project"})
+	if err != nil {
+		t.Fatalf("buildMounts: %v", err)
+	}
+	if len(mounts) != 1 || mounts[0].Source != "/home/me/project/src" {
+		t.Fatalf("expected bind source resolved against the workspace, got %#v", mounts)
+	}
+
+	shorthand, err := ParseMountSpec("./src:/app")
+	if err != nil {
+		t.Fatalf("ParseMountSpec shorthand: %v", err)
+	}
+	keyValue, err := ParseMountSpec("type=bind,source=./data,target=/data")
+	if err != nil {
+		t.Fatalf("ParseMountSpec key=value: %v", err)
+	}
+	mounts, err = buildMounts("", []MountSpec{{Raw: "type=bind,source=./cfg,target=/cfg"}}, []Mount{shorthand, keyValue}, map[string]string{"localWorkspaceFolder": "/home/me/project"})
+	if err != nil {
+		t.Fatalf("buildMounts extra: %v", err)
+	}
+	sources := []string{mounts[0].Source, mounts[1].Source, mounts[2].Source}
+	if want := []string{"./cfg", "/home/me/project/src", "./data"}; !reflect.DeepEqual(sources, want) {
+		t.Fatalf("expected only shorthand sources resolved against the workspace, got %#v", sources)
+	}
+}
@@ -3,6 +3,8 @@ package godev
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types/mount"
@@ -37,6 +39,40 @@ func TestExpandVariables(t *testing.T) {
 	}
 }
 
+func TestExpandEnvValue_DollarEscape(t *testing.T) {
+	vars := map[string]string{"devcontainerId": "deadbeef"}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "price=$$5", want: "price=$5"},
+		{input: "$${devcontainerId}", want: "${devcontainerId}"},
+		{input: "$$${devcontainerId}", want: "$deadbeef"},
+		{input: "${devcontainerId}${devcontainerId}", want: "deadbeefdeadbeef"},
+		{input: "a$b", want: "a$b"},
+		{input: "$${missing}", want: "${missing}"},
+	}
+	for _, tt := range tests {
+		got, err := expandEnvValue(tt.input, vars, nil)
+		if err != nil {
+			t.Fatalf("expandEnvValue(%q): %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Fatalf("expandEnvValue(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestExpandVariables_KeepsDoubleDollar(t *testing.T) {
+	got, err := expandVariables("echo $$ ${devcontainerId}", map[string]string{"devcontainerId": "deadbeef"}, nil)
+	if err != nil {
+		t.Fatalf("expandVariables: %v", err)
+	}
+	if got != "echo $$ deadbeef" {
+		t.Fatalf("expandVariables = %q", got)
+	}
+}
+
 func TestParseMountString(t *testing.T) {
 	spec := "type=bind,source=/tmp,target=/work,readonly,consistency=cached"
 	parsed, err := parseMountString(spec)
@@ -51,15 +87,42 @@ func TestParseMountString(t *testing.T) {
 	}
 }
 
+func TestMountTargets_MustBeAbsolute(t *testing.T) {
+	if _, err := parseMountString("type=bind,source=/tmp,target=/work"); err != nil {
+		t.Fatalf("absolute target: %v", err)
+	}
+	if _, err := parseMountString("type=bind,source=/tmp,target=work"); err == nil || !strings.Contains(err.Error(), "target=work") {
+		t.Fatalf("expected relative target error naming the spec, got %v", err)
+	}
+	if _, err := mountFromSpec(MountSpec{Type: "volume", Source: "cache", Target: "cache"}); err == nil {
+		t.Fatal("expected relative target error for structured mount")
+	}
+	if _, err := mountFromSpec(MountSpec{Type: "volume", Source: "cache", Target: "/cache"}); err != nil {
+		t.Fatalf("absolute structured target: %v", err)
+	}
+	if _, err := toDockerMount(Mount{Type: "bind", Source: "/tmp", Target: "./work"}); err == nil {
+		t.Fatal("expected relative target error for extra mount")
+	}
+	if _, err := buildMounts("type=bind,source=/src,target=workspace", nil, nil, nil); err == nil {
+		t.Fatal("expected relative target error for workspace mount")
+	}
+}
+
 func TestParseRunArgs(t *testing.T) {
 	opts, err := parseRunArgs([]string{
 		"--cap-add=SYS_PTRACE",
+		"--cap-drop=NET_RAW",
+		"--cap-drop", "MKNOD",
 		"--security-opt=seccomp=unconfined",
 		"--privileged",
 		"--init",
 		"--user=1000",
 		"--label=foo=bar",
 		"--network=host",
+		"--hostname", "devbox",
+		"--mac-address=02:42:ac:11:00:02",
+		"--ip", "172.20.0.5",
+		"--ip6=fd00::5",
 	})
 	if err != nil {
 		t.Fatalf("parseRunArgs: %v", err)
@@ -67,6 +130,9 @@ func TestParseRunArgs(t *testing.T) {
 	if len(opts.CapAdd) != 1 || opts.CapAdd[0] != "SYS_PTRACE" {
 		t.Fatalf("unexpected CapAdd: %#v", opts.CapAdd)
 	}
+	if !reflect.DeepEqual(opts.CapDrop, []string{"NET_RAW", "MKNOD"}) {
+		t.Fatalf("unexpected CapDrop: %#v", opts.CapDrop)
+	}
 	if len(opts.SecurityOpt) != 1 || opts.SecurityOpt[0] != "seccomp=unconfined" {
 		t.Fatalf("unexpected SecurityOpt: %#v", opts.SecurityOpt)
 	}
@@ -76,4 +142,112 @@ func TestParseRunArgs(t *testing.T) {
 	if opts.Labels["foo"] != "bar" {
 		t.Fatalf("unexpected labels: %#v", opts.Labels)
 	}
+	if opts.Hostname != "devbox" {
+		t.Fatalf("unexpected hostname: %q", opts.Hostname)
+	}
+	if opts.MacAddress != "02:42:ac:11:00:02" || opts.IPv4Address != "172.20.0.5" || opts.IPv6Address != "fd00::5" {
+		t.Fatalf("unexpected network addresses: %#v", opts)
+	}
+}
+
+func TestParseRunArgs_RejectsInvalidAddresses(t *testing.T) {
+	for _, args := range [][]string{
+		{"--mac-address=02:42:ac:11:00"},
+		{"--mac-address", "not-a-mac"},
+		{"--mac-address"},
+		{"--ip=fd00::5"},
+		{"--ip6", "172.20.0.5"},
+	} {
+		if _, err := parseRunArgs(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseRunArgs_ResourceFlags(t *testing.T) {
+	opts, err := parseRunArgs([]string{"--memory-reservation=512m", "--cpu-shares", "512"})
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	if opts.MemoryRes != 512*1024*1024 || opts.CPUShares != 512 {
+		t.Fatalf("unexpected resource options: %#v", opts)
+	}
+	for _, args := range [][]string{
+		{"--memory-reservation=lots"},
+		{"--memory-reservation"},
+		{"--cpu-shares=-1"},
+		{"--cpu-shares", "half"},
+	} {
+		if _, err := parseRunArgs(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseRunArgs_PidsLimit(t *testing.T) {
+	for _, args := range [][]string{{"--pids-limit=100"}, {"--pids-limit", "100"}} {
+		opts, err := parseRunArgs(args)
+		if err != nil {
+			t.Fatalf("parseRunArgs(%v): %v", args, err)
+		}
+		if opts.PidsLimit != 100 {
+			t.Fatalf("unexpected pids limit for %v: %d", args, opts.PidsLimit)
+		}
+	}
+	for _, args := range [][]string{{"--pids-limit=0"}, {"--pids-limit=-1"}, {"--pids-limit", "many"}, {"--pids-limit"}} {
+		if _, err := parseRunArgs(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestParseStopSignal(t *testing.T) {
+	for value, want := range map[string]string{"SIGINT": "SIGINT", "term": "SIGTERM", "9": "9", "SIGRTMIN+3": "SIGRTMIN+3"} {
+		got, err := parseStopSignal(value)
+		if err != nil || got != want {
+			t.Fatalf("parseStopSignal(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "SIGFOO", "0", "65", "RTMIN+16"} {
+		if _, err := parseStopSignal(value); err == nil {
+			t.Fatalf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestParseRestrictedRunArgs_IgnoreUnknown(t *testing.T) {
+	args := []string{"--gpus", "all", "--cap-add=SYS_PTRACE", "--device-cgroup-rule=c 1:3 mr", "--oom-kill-disable", "--init"}
+	if _, err := parseRestrictedRunArgs(args, nil, false); err == nil || !strings.Contains(err.Error(), "unsupported runArg: --gpus") {
+		t.Fatalf("expected strict mode to reject --gpus, got %v", err)
+	}
+	opts, err := parseRestrictedRunArgs(args, nil, true)
+	if err != nil {
+		t.Fatalf("parseRestrictedRunArgs: %v", err)
+	}
+	if !reflect.DeepEqual(opts.Skipped, []string{"--gpus all", "--device-cgroup-rule=c 1:3 mr", "--oom-kill-disable"}) {
+		t.Fatalf("unexpected skipped runArgs: %#v", opts.Skipped)
+	}
+	if !reflect.DeepEqual(opts.CapAdd, []string{"SYS_PTRACE"}) || !opts.Init {
+		t.Fatalf("expected known runArgs to still apply: %#v", opts)
+	}
+	if _, err := parseRestrictedRunArgs([]string{"--gpus=all"}, []string{"--init"}, true); err == nil {
+		t.Fatal("expected the allowlist to reject unknown flags even when ignoring them")
+	}
+}
+
+func TestParseRestrictedRunArgs(t *testing.T) {
+	allowed := []string{"--cap-add", "--user"}
+	if _, err := parseRestrictedRunArgs([]string{"--cap-add=SYS_PTRACE", "-u", "vscode"}, allowed, false); err != nil {
+		t.Fatalf("expected allowed runArgs to parse: %v", err)
+	}
+	_, err := parseRestrictedRunArgs([]string{"--cap-add", "SYS_PTRACE", "--privileged"}, allowed, false)
+	if err == nil || !strings.Contains(err.Error(), "--privileged is not allowed") {
+		t.Fatalf("expected --privileged to be rejected, got %v", err)
+	}
+	if _, err := parseRestrictedRunArgs([]string{"--init"}, []string{}, false); err == nil {
+		t.Fatal("expected an empty allowlist to reject every runArg")
+	}
+	if _, err := parseRestrictedRunArgs([]string{"--privileged"}, nil, false); err != nil {
+		t.Fatalf("expected no allowlist to be unrestricted: %v", err)
+	}
 }
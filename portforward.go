@@ -0,0 +1,177 @@
+package godev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ForwardPort proxies TCP connections from 127.0.0.1:hostPort to containerPort on the container's IP address.
+// Impact: It reaches ports that were not published at create time without recreating the container; the forward
+// lives in this process and stops when the returned function is called, which also closes open connections.
+// The container IP must be routable from the host, which holds for Linux engines but not Docker Desktop.
+// Example:
+//
+//	stop, err := devcontainer.ForwardPort(ctx, containerID, 5432, 15432)
+//	defer stop()
+//
+// Similar: VerifyPortPublished checks create-time bindings, while WithAutoForwardPorts reports unpublished listeners.
+func ForwardPort(ctx context.Context, containerID string, containerPort, hostPort int) (func() error, error) {
+	if err := validatePortNumber(containerPort); err != nil {
+		return nil, fmt.Errorf("invalid container port: %w", err)
+	}
+	if err := validatePortNumber(hostPort); err != nil {
+		return nil, fmt.Errorf("invalid host port: %w", err)
+	}
+	cli, err := newDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = cli.Close()
+	}()
+
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.State != nil && !inspect.State.Running {
+		return nil, fmt.Errorf("container %s is not running", containerID)
+	}
+	address, err := containerIPAddress(inspect)
+	if err != nil {
+		return nil, err
+	}
+	var listenConfig net.ListenConfig
+	listener, err := listenConfig.Listen(ctx, "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort)))
+	if err != nil {
+		return nil, err
+	}
+	forward := &portForward{
+		listener: listener,
+		target:   net.JoinHostPort(address, strconv.Itoa(containerPort)),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go forward.serve()
+	return forward.close, nil
+}
+
+// containerIPAddress returns the first IP address the container has on any of its networks.
+func containerIPAddress(inspect container.InspectResponse) (string, error) {
+	if inspect.NetworkSettings != nil {
+		if inspect.NetworkSettings.IPAddress != "" {
+			return inspect.NetworkSettings.IPAddress, nil
+		}
+		for _, endpoint := range inspect.NetworkSettings.Networks {
+			if endpoint != nil && endpoint.IPAddress != "" {
+				return endpoint.IPAddress, nil
+			}
+		}
+	}
+	return "", errors.New("container has no IP address to forward to")
+}
+
+// validatePortNumber rejects values outside the TCP port range.
+func validatePortNumber(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535: %d", port)
+	}
+	return nil
+}
+
+// portForward accepts connections on listener and pipes each one to target.
+type portForward struct {
+	listener net.Listener          // listener accepts host connections.
+	target   string                // target is the container address dialed per connection.
+	mu       sync.Mutex            // mu guards conns and closed.
+	conns    map[net.Conn]struct{} // conns holds open connections so close can interrupt them.
+	closed   bool                  // closed is set once close has run.
+	wg       sync.WaitGroup        // wg tracks connection goroutines.
+}
+
+func (f *portForward) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		if !f.track(conn, true) {
+			_ = conn.Close()
+			return
+		}
+		go func() {
+			defer f.wg.Done()
+			defer f.untrack(conn)
+			f.pipe(conn)
+		}()
+	}
+}
+
+// pipe copies both directions between conn and a fresh connection to the target until either side closes.
+func (f *portForward) pipe(conn net.Conn) {
+	upstream, err := net.Dial("tcp", f.target)
+	if err != nil {
+		return
+	}
+	if !f.track(upstream, false) {
+		_ = upstream.Close()
+		return
+	}
+	defer f.untrack(upstream)
+	done := make(chan struct{}, 2)
+	copyHalf := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			_ = tcp.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go copyHalf(upstream, conn)
+	go copyHalf(conn, upstream)
+	<-done
+	<-done
+}
+
+// track registers conn for close, and a connection goroutine on wg when spawn is set; it fails once closed.
+func (f *portForward) track(conn net.Conn, spawn bool) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false
+	}
+	f.conns[conn] = struct{}{}
+	if spawn {
+		f.wg.Add(1)
+	}
+	return true
+}
+
+func (f *portForward) untrack(conn net.Conn) {
+	f.mu.Lock()
+	delete(f.conns, conn)
+	f.mu.Unlock()
+	_ = conn.Close()
+}
+
+// close stops accepting, closes open connections, and waits for their goroutines to finish.
+func (f *portForward) close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	err := f.listener.Close()
+	for conn := range f.conns {
+		_ = conn.Close()
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+	return err
+}
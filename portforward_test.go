@@ -0,0 +1,73 @@
+package godev
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestForwardPort_ProxiesToContainerIP(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen echo: %v", err)
+	}
+	t.Cleanup(func() { _ = echo.Close() })
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	containerPort := echo.Addr().(*net.TCPAddr).Port
+
+	daemon := newFakeDaemon(t)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","State":{"Running":true},"NetworkSettings":{"Networks":{"bridge":{"IPAddress":"127.0.0.1"}}}}`)
+	daemon.useAsDockerHost()
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve host port: %v", err)
+	}
+	hostPort := free.Addr().(*net.TCPAddr).Port
+	_ = free.Close()
+
+	stop, err := ForwardPort(context.Background(), "container-123", containerPort, hostPort)
+	if err != nil {
+		t.Fatalf("ForwardPort: %v", err)
+	}
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", hostPort))
+	if err != nil {
+		t.Fatalf("dial forward: %v", err)
+	}
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("expected echo, got %q (%v)", line, err)
+	}
+
+	if err := stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the open connection to be closed by stop")
+	}
+	if _, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", hostPort)); err == nil {
+		t.Fatal("expected the host port to be released after stop")
+	}
+
+	if _, err := ForwardPort(context.Background(), "container-123", 0, hostPort); err == nil {
+		t.Fatal("expected an invalid container port to fail")
+	}
+}
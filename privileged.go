@@ -0,0 +1,79 @@
+package godev
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+)
+
+// dangerousCapabilities are capabilities WithDisallowPrivileged treats like privileged mode:
+// each one is enough to escape the container or take over the host kernel.
+var dangerousCapabilities = map[string]struct{}{
+	"ALL":             {},
+	"SYS_ADMIN":       {},
+	"SYS_MODULE":      {},
+	"SYS_RAWIO":       {},
+	"SYS_BOOT":        {},
+	"MAC_ADMIN":       {},
+	"MAC_OVERRIDE":    {},
+	"DAC_READ_SEARCH": {},
+	"BPF":             {},
+}
+
+// firstDangerousCapability returns the first capability in caps that WithDisallowPrivileged blocks.
+// Names are compared without case or a CAP_ prefix, as Docker accepts both.
+func firstDangerousCapability(caps []string) (string, bool) {
+	for _, capability := range caps {
+		name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(capability)), "CAP_")
+		if _, ok := dangerousCapabilities[name]; ok {
+			return capability, true
+		}
+	}
+	return "", false
+}
+
+// checkPrivilegedConfig rejects privileged mode and dangerous capabilities requested by the config or its features.
+func checkPrivilegedConfig(cfg *DevcontainerConfig, features *ResolvedFeatures) error {
+	if cfg.Privileged {
+		return errors.New("privileged mode is disallowed: devcontainer.json sets privileged")
+	}
+	if capability, ok := firstDangerousCapability(cfg.CapAdd); ok {
+		return fmt.Errorf("capability %s is disallowed: devcontainer.json capAdd requests it", capability)
+	}
+	if features == nil {
+		return nil
+	}
+	for _, feature := range features.Order {
+		if feature.Metadata.Privileged {
+			return fmt.Errorf("privileged mode is disallowed: feature %s requests it", feature.CanonicalName)
+		}
+		if capability, ok := firstDangerousCapability(feature.Metadata.CapAdd); ok {
+			return fmt.Errorf("capability %s is disallowed: feature %s requests it", capability, feature.CanonicalName)
+		}
+	}
+	return nil
+}
+
+// checkPrivilegedRunArgs rejects --privileged and dangerous --cap-add values in runArgs.
+func checkPrivilegedRunArgs(runArgOptions runArgOptions) error {
+	if runArgOptions.Privileged {
+		return errors.New("privileged mode is disallowed: runArgs contain --privileged")
+	}
+	if capability, ok := firstDangerousCapability(runArgOptions.CapAdd); ok {
+		return fmt.Errorf("capability %s is disallowed: runArgs add it", capability)
+	}
+	return nil
+}
+
+// checkPrivilegedService rejects a compose service that is privileged or adds dangerous capabilities.
+func checkPrivilegedService(service *types.ServiceConfig) error {
+	if service.Privileged {
+		return fmt.Errorf("privileged mode is disallowed: compose service %s sets privileged", service.Name)
+	}
+	if capability, ok := firstDangerousCapability(service.CapAdd); ok {
+		return fmt.Errorf("capability %s is disallowed: compose service %s adds it", capability, service.Name)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package godev
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+)
+
+func TestDisallowPrivileged_BlocksEachSource(t *testing.T) {
+	feature := func(metadata FeatureMetadata) *ResolvedFeatures {
+		return &ResolvedFeatures{Order: []*ResolvedFeature{{CanonicalName: "ghcr.io/example/dind:1", Metadata: metadata}}}
+	}
+	tests := []struct {
+		name  string
+		check func() error
+		want  string
+	}{
+		{name: "config privileged", check: func() error {
+			return checkPrivilegedConfig(&DevcontainerConfig{Privileged: true}, nil)
+		}, want: "devcontainer.json sets privileged"},
+		{name: "config capAdd", check: func() error {
+			return checkPrivilegedConfig(&DevcontainerConfig{CapAdd: []string{"SYS_PTRACE", "sys_admin"}}, nil)
+		}, want: "capability sys_admin is disallowed"},
+		{name: "feature privileged", check: func() error {
+			return checkPrivilegedConfig(&DevcontainerConfig{}, feature(FeatureMetadata{Privileged: true}))
+		}, want: "feature ghcr.io/example/dind:1 requests it"},
+		{name: "feature capAdd", check: func() error {
+			return checkPrivilegedConfig(&DevcontainerConfig{}, feature(FeatureMetadata{CapAdd: []string{"CAP_SYS_MODULE"}}))
+		}, want: "capability CAP_SYS_MODULE is disallowed"},
+		{name: "runArgs privileged", check: func() error {
+			runArgs, err := parseRunArgs([]string{"--privileged"})
+			if err != nil {
+				return err
+			}
+			return checkPrivilegedRunArgs(runArgs)
+		}, want: "runArgs contain --privileged"},
+		{name: "runArgs cap-add", check: func() error {
+			runArgs, err := parseRunArgs([]string{"--cap-add", "ALL"})
+			if err != nil {
+				return err
+			}
+			return checkPrivilegedRunArgs(runArgs)
+		}, want: "capability ALL is disallowed: runArgs add it"},
+		{name: "compose service", check: func() error {
+			return checkPrivilegedService(&types.ServiceConfig{Name: "app", Privileged: true})
+		}, want: "compose service app sets privileged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if err := checkPrivilegedConfig(&DevcontainerConfig{CapAdd: []string{"SYS_PTRACE"}}, feature(FeatureMetadata{CapAdd: []string{"NET_RAW"}})); err != nil {
+		t.Fatalf("expected ordinary capabilities to be allowed: %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package godev
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// secretMask replaces secret values in errors and output.
+const secretMask = "***"
+
+// redactor masks a fixed set of secret values.
+type redactor struct {
+	secrets  []string          // secrets holds the values to mask, longest first.
+	replacer *strings.Replacer // replacer maps each secret to secretMask.
+}
+
+// newSecretRedactor returns a redactor that masks values, or nil when there is nothing to mask.
+// Longer values are matched first so a secret that contains another is masked whole.
+func newSecretRedactor(values []string) *redactor {
+	sorted := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			sorted = append(sorted, value)
+		}
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	pairs := make([]string, 0, len(sorted)*2)
+	for _, value := range sorted {
+		pairs = append(pairs, value, secretMask)
+	}
+	return &redactor{secrets: sorted, replacer: strings.NewReplacer(pairs...)}
+}
+
+// Replace masks every secret in s.
+func (r *redactor) Replace(s string) string {
+	return r.replacer.Replace(s)
+}
+
+// redactedError masks secrets in an error message. Unwrap yields the wrapped errors masked in turn, so no message
+// in the chain carries a secret, while Is and As still match the original errors for errors.Is and errors.As.
+type redactedError struct {
+	msg      string    // msg is the redacted message.
+	err      error     // err is the original error.
+	redactor *redactor // redactor masks the errors err wraps.
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() []error {
+	var wrapped []error
+	switch err := e.err.(type) {
+	case interface{ Unwrap() error }:
+		wrapped = []error{err.Unwrap()}
+	case interface{ Unwrap() []error }:
+		wrapped = err.Unwrap()
+	}
+	redacted := make([]error, 0, len(wrapped))
+	for _, item := range wrapped {
+		if item != nil {
+			redacted = append(redacted, &redactedError{msg: e.redactor.Replace(item.Error()), err: item, redactor: e.redactor})
+		}
+	}
+	return redacted
+}
+
+func (e *redactedError) Is(target error) bool {
+	if matcher, ok := e.err.(interface{ Is(error) bool }); ok && matcher.Is(target) {
+		return true
+	}
+	targetType := reflect.TypeOf(target)
+	return targetType != nil && targetType.Comparable() && e.err == target
+}
+
+// As matches the original error by concrete type; interface targets receive the redacted error instead.
+func (e *redactedError) As(target any) bool {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return false
+	}
+	elem := value.Elem()
+	if elem.Kind() == reflect.Interface {
+		return false
+	}
+	if reflect.TypeOf(e.err).AssignableTo(elem.Type()) {
+		elem.Set(reflect.ValueOf(e.err))
+		return true
+	}
+	if matcher, ok := e.err.(interface{ As(any) bool }); ok {
+		return matcher.As(target)
+	}
+	return false
+}
+
+// redactError masks secrets in err's message; it returns err unchanged when nothing needs masking.
+func redactError(err error, redactor *redactor) error {
+	if err == nil || redactor == nil {
+		return err
+	}
+	msg := err.Error()
+	redacted := redactor.Replace(msg)
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{msg: redacted, err: err, redactor: redactor}
+}
+
+// redactingWriter masks secrets in the written stream. Output that could be the start of a secret is held back
+// until the next Write shows whether it completes one, so a secret split across writes is still masked; Flush
+// writes whatever is held back.
+type redactingWriter struct {
+	w        io.Writer // w receives the redacted output.
+	redactor *redactor // redactor masks secret values.
+	pending  []byte    // pending holds output not yet known to be free of a partial secret.
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	r.pending = append(r.pending, p...)
+	text := string(r.pending)
+	cut := r.safeCut(text)
+	if cut == 0 {
+		return len(p), nil
+	}
+	r.pending = append(r.pending[:0], text[cut:]...)
+	if _, err := io.WriteString(r.w, r.redactor.Replace(text[:cut])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the held-back output.
+func (r *redactingWriter) Flush() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	text := string(r.pending)
+	r.pending = r.pending[:0]
+	_, err := io.WriteString(r.w, r.redactor.Replace(text))
+	return err
+}
+
+// safeCut returns the length of the prefix of text that can be redacted and written now: it ends before any
+// trailing partial secret and before any secret that would otherwise be split at the cut.
+func (r *redactingWriter) safeCut(text string) int {
+	cut := len(text)
+	for _, secret := range r.redactor.secrets {
+		for n := min(len(secret)-1, len(text)); n > 0; n-- {
+			if strings.HasPrefix(secret, text[len(text)-n:]) {
+				cut = min(cut, len(text)-n)
+				break
+			}
+		}
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, secret := range r.redactor.secrets {
+			start := max(0, cut-len(secret)+1)
+			if index := strings.Index(text[start:], secret); index >= 0 && start+index < cut {
+				cut = start + index
+				moved = true
+			}
+		}
+	}
+	return cut
+}
+
+// redactWriter wraps w so secrets are masked and returns a flush func to call once the stream ends; a nil w or
+// redactor returns w unchanged with a no-op flush.
+func redactWriter(w io.Writer, redactor *redactor) (io.Writer, func()) {
+	if w == nil || redactor == nil {
+		return w, func() {}
+	}
+	writer := &redactingWriter{w: w, redactor: redactor}
+	return writer, func() {
+		_ = writer.Flush()
+	}
+}
+
+// redactingHandler masks secrets in the message and attribute values of records before passing them to h.
+type redactingHandler struct {
+	h        slog.Handler // h receives the redacted records.
+	redactor *redactor    // redactor masks secret values.
+}
+
+func (r *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return r.h.Enabled(ctx, level)
+}
+
+func (r *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, r.redactor.Replace(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(r.redactAttr(attr))
+		return true
+	})
+	return r.h.Handle(ctx, redacted)
+}
+
+func (r *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = r.redactAttr(attr)
+	}
+	return &redactingHandler{h: r.h.WithAttrs(redacted), redactor: r.redactor}
+}
+
+func (r *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{h: r.h.WithGroup(name), redactor: r.redactor}
+}
+
+// redactAttr masks secrets in attr; values other than strings and errors are masked through their text form and
+// left as they were when they hold no secret.
+func (r *redactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, r.redactor.Replace(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, item := range group {
+			redacted[i] = r.redactAttr(item)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.Any(attr.Key, redactError(err, r.redactor))
+		}
+		text := fmt.Sprint(value.Any())
+		if redacted := r.redactor.Replace(text); redacted != text {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// redactLogger wraps logger so secrets are masked in its records; a nil redactor returns logger unchanged.
+func redactLogger(logger *slog.Logger, redactor *redactor) *slog.Logger {
+	if redactor == nil {
+		return logger
+	}
+	return slog.New(&redactingHandler{h: logger.Handler(), redactor: redactor})
+}
+
+// redactLifecycle wraps runner so secrets in command failures, including captured output, are masked.
+func redactLifecycle(runner lifecycleRunner, redactor *redactor) lifecycleRunner {
+	if redactor == nil {
+		return runner
+	}
+	return func(ctx context.Context, name string, command LifecycleCommand) error {
+		return redactError(runner(ctx, name, command), redactor)
+	}
+}
@@ -0,0 +1,75 @@
+package godev
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactError_MasksWholeChain(t *testing.T) {
+	redactor := newSecretRedactor([]string{"s3cr3t"})
+	inner := fmt.Errorf("token s3cr3t rejected: %w", ErrDockerUnavailable)
+	featureErr := &FeatureResolutionError{Err: inner}
+	err := redactError(fmt.Errorf("start: %w", featureErr), redactor)
+
+	for current := err; current != nil; {
+		if strings.Contains(current.Error(), "s3cr3t") {
+			t.Fatalf("secret leaked in chain: %q", current.Error())
+		}
+		wrapped, ok := current.(interface{ Unwrap() []error })
+		if !ok {
+			current = errors.Unwrap(current)
+			continue
+		}
+		next := wrapped.Unwrap()
+		if len(next) == 0 {
+			break
+		}
+		current = next[0]
+	}
+	if !errors.Is(err, ErrDockerUnavailable) {
+		t.Fatalf("expected errors.Is to find the sentinel: %v", err)
+	}
+	var target *FeatureResolutionError
+	if !errors.As(err, &target) || target != featureErr {
+		t.Fatalf("expected errors.As to find the feature error: %v", err)
+	}
+	var wrapper interface{ Unwrap() error }
+	if errors.As(err, &wrapper) && strings.Contains(wrapper.(error).Error(), "s3cr3t") {
+		t.Fatalf("secret leaked through errors.As: %q", wrapper.(error).Error())
+	}
+}
+
+func TestRedactingWriter_MasksSecretSplitAcrossWrites(t *testing.T) {
+	var out bytes.Buffer
+	writer, flush := redactWriter(&out, newSecretRedactor([]string{"s3cr3t-value", "value-2"}))
+	for _, chunk := range []string{"token=s3c", "r3t-va", "lue done\n", "tail value-", "2 s3c"} {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	flush()
+	if got := out.String(); got != "token=*** done\ntail *** s3c" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
+
+func TestRedactLogger_MasksRecords(t *testing.T) {
+	var out bytes.Buffer
+	options, err := applyStartOptions([]StartOption{WithSecretEnv("TOKEN", "s3cr3t"), WithLogger(slog.New(slog.NewTextHandler(&out, nil)))})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	logger := options.logger().With("auth", "Bearer s3cr3t")
+	logger.Info("using s3cr3t", "error", errors.New("bad s3cr3t"), slog.Group("request", "header", "s3cr3t"), "args", []string{"--token", "s3cr3t"}, "count", 3)
+	got := out.String()
+	if strings.Contains(got, "s3cr3t") {
+		t.Fatalf("secret leaked into log: %s", got)
+	}
+	if !strings.Contains(got, "count=3") || !strings.Contains(got, "request.header=***") {
+		t.Fatalf("unexpected log output: %s", got)
+	}
+}
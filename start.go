@@ -3,34 +3,84 @@ package godev
 import (
 	"archive/tar"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/distribution/reference"
 	"github.com/docker/docker/api/types/build"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 	"github.com/docker/go-units"
 )
 
+// StartResult describes a devcontainer started by StartDevcontainerResult.
+type StartResult struct {
+	ContainerID     string          // ContainerID is the started (or, for compose, primary service) container.
+	ContainerName   string          // ContainerName is the container name without Docker's leading slash.
+	BaseImage       string          // BaseImage is the pulled or built image before features; empty for compose services that build.
+	FeatureImage    string          // FeatureImage is the feature image tag, or empty when no features are installed.
+	Ports           []PublishedPort // Ports lists the host bindings Docker assigned to published container ports.
+	DetectedPorts   []int           // DetectedPorts lists unpublished ports found listening by WithAutoForwardPorts.
+	ComposeOverride string          // ComposeOverride is the compose override file kept on disk, or empty when it was removed.
+}
+
+// PublishedPort is one host binding of a published container port.
+type PublishedPort struct {
+	ContainerPort string // ContainerPort is the port and protocol inside the container, such as "8080/tcp".
+	HostIP        string // HostIP is the host address the port is bound to.
+	HostPort      string // HostPort is the host port, resolved when Docker picked it.
+}
+
 // StartDevcontainer reads devcontainer.json and performs image preparation and container start.
 // Impact: It pulls/builds images, creates and starts containers, and runs feature and lifecycle commands.
+// Canceling ctx aborts the current step and returns ctx's error; once a container exists its ID is returned
+// alongside the error and the container is left running for the caller to stop or remove. A compose stack that
+// fails after docker compose up is brought down instead unless WithKeepOnFailure is set.
+// Concurrent starts of the same devcontainer, even from other processes, wait for each other until the container
+// is up; a start still waiting when ctx ends fails with "start already in progress".
 // Example:
 //
 //	id, err := devcontainer.StartDevcontainer(ctx, devcontainer.WithConfigPath("./.devcontainer/devcontainer.json"))
 //
 // Similar: BuildImageFromDevcontainer only builds images and does not start containers or run lifecycle hooks.
 func StartDevcontainer(ctx context.Context, opts ...StartOption) (string, error) {
-	options := defaultStartOptions()
-	for _, opt := range opts {
-		opt(&options)
+	result, err := StartDevcontainerResult(ctx, opts...)
+	if result == nil {
+		return "", err
+	}
+	return result.ContainerID, err
+}
+
+// StartDevcontainerResult starts a devcontainer like StartDevcontainer and describes what it started.
+// Impact: It inspects the container once after start so assigned host ports are included; the result is
+// non-nil alongside an error once a container exists.
+// Example:
+//
+//	result, err := devcontainer.StartDevcontainerResult(ctx, devcontainer.WithConfigPath("./.devcontainer/devcontainer.json"))
+//
+// Similar: StartDevcontainer returns only the container ID.
+func StartDevcontainerResult(ctx context.Context, opts ...StartOption) (result *StartResult, err error) {
+	options, err := applyStartOptions(opts)
+	if err != nil {
+		return nil, err
 	}
 
 	if options.Timeout > 0 {
@@ -38,36 +88,56 @@ func StartDevcontainer(ctx context.Context, opts ...StartOption) (string, error)
 		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
 		defer cancel()
 	}
-
-	configPath, err := resolveConfigPath(options.ConfigPath, options.Config != nil)
-	if err != nil {
-		return "", err
-	}
-	baseCfg := options.Config
-	if baseCfg == nil {
-		baseCfg, err = LoadConfig(configPath)
+	if options.GitSource != nil {
+		cloneDir, configPath, err := cloneGitConfig(ctx, *options.GitSource, options.TempDir)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+		defer func() {
+			if result == nil {
+				_ = removeGitClone(cloneDir)
+			}
+		}()
+		options.ConfigPath = configPath
+		options.GitClone = cloneDir
 	}
-	cfg := MergeConfig(nil, baseCfg)
-	for _, overlay := range options.MergeConfigs {
-		cfg = MergeConfig(cfg, overlay)
+
+	configPath, cfg, err := loadEffectiveConfig(options)
+	if err != nil {
+		return nil, err
 	}
-	if err := validateConfig(cfg); err != nil {
-		return "", err
+	if err := preflightDocker(ctx, options); err != nil {
+		return nil, err
 	}
 	if isComposeConfig(cfg) {
 		return startComposeDevcontainer(ctx, configPath, cfg, options)
 	}
+	if len(options.HookServices) > 0 {
+		return nil, errors.New("lifecycle hook services require docker compose")
+	}
 
 	workspaceRoot, workspaceFolder, workspaceMount, vars, err := resolveWorkspacePaths(configPath, cfg)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	features, err := resolveFeatures(ctx, configPath, workspaceRoot, cfg)
+	overrideDevcontainerID(vars, options.DevcontainerID)
+	lock, err := acquireStartLock(ctx, vars["devcontainerId"], options.logger())
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	defer lock.release()
+	features, err := resolveStartFeatures(ctx, configPath, workspaceRoot, cfg, vars, options)
+	if err != nil {
+		return nil, err
+	}
+	defer features.removeTempDirs()
+	if err := verifyFeatureVersions(features, options.StrictFeatures); err != nil {
+		return nil, err
+	}
+	if options.NoPrivileged {
+		if err := checkPrivilegedConfig(cfg, features); err != nil {
+			return nil, err
+		}
 	}
 	if features != nil {
 		cfg.Privileged = cfg.Privileged || features.Privileged
@@ -75,6 +145,7 @@ func StartDevcontainer(ctx context.Context, opts ...StartOption) (string, error)
 			cfg.Init = features.Init
 		}
 		cfg.CapAdd = appendUnique(cfg.CapAdd, features.CapAdd...)
+		cfg.CapDrop = appendUnique(cfg.CapDrop, features.CapDrop...)
 		cfg.SecurityOpt = appendUnique(cfg.SecurityOpt, features.SecurityOpt...)
 		cfg.Mounts = append(append([]MountSpec{}, features.Mounts...), cfg.Mounts...)
 	}
@@ -83,141 +154,187 @@ func StartDevcontainer(ctx context.Context, opts ...StartOption) (string, error)
 	if features != nil && len(features.ContainerEnv) > 0 {
 		baseEnv, err = mergeEnvMaps(features.ContainerEnv, baseEnv, vars)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 	envMap, err := mergeEnvMaps(baseEnv, options.Env, vars)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if err := runLifecycleCommands(ctx, "initializeCommand", cfg.InitializeCommand, hostLifecycleRunner(workspaceRoot, vars, envMap)); err != nil {
-		return "", err
+	passthroughEnv(envMap, options.PassEnv, options.Env)
+	if err := runLifecycleCommands(ctx, "initializeCommand", cfg.InitializeCommand, observeLifecycle(redactLifecycle(hostLifecycleRunner(workspaceRoot, vars, envMap), options.secretRedactor()), options.OnLifecycle)); err != nil {
+		return nil, err
 	}
 
 	cli, err := newDockerClient()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer func() {
 		_ = cli.Close()
 	}()
 
-	imageRef, err := ensureImage(ctx, cli, cfg, configPath, workspaceRoot, vars["devcontainerId"])
+	platform, err := parsePlatform(options.Platform)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	imageRef, err := ensureImage(ctx, cli, cfg, configPath, workspaceRoot, vars["devcontainerId"], options)
+	if err != nil {
+		return nil, err
+	}
+	result = &StartResult{BaseImage: imageRef}
 	if features != nil {
-		baseUser, err := imageDefaultUser(ctx, cli, imageRef)
+		baseUser, err := imageDefaultUser(ctx, cli, imageRef, platform)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		imageRef, err = buildFeaturesImage(ctx, cli, imageRef, baseUser, workspaceRoot, vars["devcontainerId"], cfg, features.Order, vars)
+		imageRef, err = buildFeaturesImage(ctx, cli, imageRef, baseUser, workspaceRoot, vars["devcontainerId"], cfg, features.Order, vars, options)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+		features.removeTempDirs()
+		result.FeatureImage = imageRef
+	}
+	if err := publishImage(ctx, cli, imageRef, options); err != nil {
+		return nil, err
 	}
 
-	runArgOptions, err := parseRunArgs(append(cfg.RunArgs, options.RunArgs...))
+	runArgOptions, err := parseRestrictedRunArgs(append(cfg.RunArgs, options.RunArgs...), options.AllowedRunArgs, options.IgnoreRunArgs)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	for _, skipped := range runArgOptions.Skipped {
+		options.logger().Warn("skipping unsupported runArg", "arg", skipped)
+	}
+	if options.NoPrivileged {
+		if err := checkPrivilegedRunArgs(runArgOptions); err != nil {
+			return nil, err
+		}
 	}
 
-	portSpecs, err := collectPortSpecs(cfg.ForwardPorts, cfg.AppPort, options.ExtraPublish)
+	portSpecs, err := collectPortSpecs(cfg.ForwardPorts, cfg.AppPort, options.ExtraPublish, options.EphemeralPorts)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	exposedPorts, portBindings, err := parsePortSpecs(portSpecs)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	workspaceMount, err = workspaceMountSpec(options, cfg, workspaceMount)
+	if err != nil {
+		return nil, err
+	}
 	mounts, err := buildMounts(workspaceMount, cfg.Mounts, options.ExtraMounts, vars)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	labelNamedVolumes(mounts, configPath)
+	if options.ValidateBinds {
+		if err := validateBindSources(mounts); err != nil {
+			return nil, err
+		}
 	}
 
-	labels := mergeLabels(options.Labels, runArgOptions.Labels)
-	labels["devcontainer.config_path"] = configPath
+	labels, err := containerLabels(options, runArgOptions.Labels, configPath, workspaceRoot)
+	if err != nil {
+		return nil, err
+	}
 
 	workingDir := workspaceFolder
 	if options.Workdir != "" {
 		workingDir = options.Workdir
 	}
 
-	containerConfig := &container.Config{
-		Image:        imageRef,
-		Env:          envMapToSlice(envMap),
-		ExposedPorts: exposedPorts,
-		WorkingDir:   workingDir,
-		Tty:          options.TTY,
-		User:         cfg.ContainerUser,
-		Labels:       labels,
-	}
-
-	if runArgOptions.User != "" {
-		containerConfig.User = runArgOptions.User
-	}
+	containerConfig := newContainerConfig(cfg, options, runArgOptions, imageRef, envMap, exposedPorts, workingDir, labels)
 
 	overrideCommand := true
 	if cfg.OverrideCommand != nil {
 		overrideCommand = *cfg.OverrideCommand
 	}
+	keepAliveShell := ""
 	if overrideCommand {
-		containerConfig.Cmd = []string{"/bin/sh", "-c", "while sleep 1000; do :; done"}
+		containerConfig.Cmd = options.KeepAlive
+		if len(containerConfig.Cmd) == 0 {
+			keepAliveShell = imageShell(ctx, cli, imageRef, options.logger())
+			containerConfig.Cmd = keepAliveCommand(keepAliveShell)
+		}
 	}
-
-	hostConfig := &container.HostConfig{
-		PortBindings: portBindings,
-		Mounts:       mounts,
-		AutoRemove:   options.RemoveOnStop,
-		Privileged:   cfg.Privileged || runArgOptions.Privileged,
-		CapAdd:       append([]string{}, cfg.CapAdd...),
-		SecurityOpt:  append([]string{}, cfg.SecurityOpt...),
+	if features != nil && hasFeatureEntrypoints(features.Order) {
+		// The wrapper execs its arguments, so an entrypoint override moves in front of the command.
+		if override := containerConfig.Entrypoint; override != nil {
+			if len(override) == 1 && override[0] == "" {
+				override = nil
+			}
+			containerConfig.Cmd = append(append([]string{}, override...), containerConfig.Cmd...)
+		} else if !overrideCommand {
+			entrypoint, cmd, err := imageCommand(ctx, cli, imageRef)
+			if err != nil {
+				return nil, err
+			}
+			containerConfig.Cmd = append(append([]string{}, entrypoint...), cmd...)
+		}
+		containerConfig.Entrypoint = []string{featureEntrypointWrapper}
 	}
 
-	if runArgOptions.Init {
-		hostConfig.Init = &runArgOptions.Init
-	} else if cfg.Init != nil {
-		hostConfig.Init = cfg.Init
+	hostConfig, err := newHostConfig(cfg, options, runArgOptions, mounts, portBindings)
+	if err != nil {
+		return nil, err
+	}
+	hostConfig.SecurityOpt, err = loadSeccompProfiles(hostConfig.SecurityOpt, filepath.Dir(configPath))
+	if err != nil {
+		return nil, err
 	}
 
-	if len(runArgOptions.CapAdd) > 0 {
-		hostConfig.CapAdd = append(hostConfig.CapAdd, runArgOptions.CapAdd...)
+	configName := cfg.Name
+	if options.Name != "" {
+		configName = options.Name
 	}
-	if len(runArgOptions.SecurityOpt) > 0 {
-		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, runArgOptions.SecurityOpt...)
+	containerName := resolveContainerName(configName, workspaceRoot, vars["devcontainerId"])
+	created, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, newNetworkingConfig(hostConfig, runArgOptions), platform, containerName)
+	if err != nil {
+		return nil, err
 	}
-
-	if options.Network != "" {
-		hostConfig.NetworkMode = container.NetworkMode(options.Network)
-	} else if runArgOptions.Network != "" {
-		hostConfig.NetworkMode = container.NetworkMode(runArgOptions.Network)
+	result.ContainerID = created.ID
+	if keepAliveShell != "" {
+		if err := checkKeepAliveShell(ctx, cli, created.ID, keepAliveShell, options.logger()); err != nil {
+			return result, err
+		}
 	}
 
-	if options.Resources.CPUQuota != 0 {
-		hostConfig.CPUQuota = options.Resources.CPUQuota
-	}
-	if options.Resources.Memory != "" {
-		bytes, err := units.RAMInBytes(options.Resources.Memory)
+	var session *attachSession
+	if options.attachStdio() {
+		session, err = attachContainer(ctx, cli, created.ID, options)
 		if err != nil {
-			return "", err
+			return result, err
 		}
-		hostConfig.Memory = bytes
+		defer session.Close()
 	}
-
-	containerName := resolveContainerName(cfg.Name, workspaceRoot, vars["devcontainerId"])
-	created, err := cli.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
-	if err != nil {
-		return "", err
+	if err := cli.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return result, err
+	}
+	if session != nil {
+		session.resizeTTY(ctx, cli, created.ID)
+	}
+	if err := describeContainer(ctx, cli, result); err != nil {
+		return result, err
+	}
+	if options.HealthyTimeout > 0 {
+		if err := waitForHealthy(ctx, cli, created.ID, options.HealthyTimeout); err != nil {
+			return result, err
+		}
 	}
 
-	if err := cli.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
-		return created.ID, err
+	if options.WorkspaceWait > 0 {
+		if err := waitForWorkspace(ctx, cli, created.ID, workspaceFolder, options.WorkspaceMarker, options.WorkspaceWait); err != nil {
+			return result, err
+		}
 	}
 
-	lifecycleEnv, err := buildLifecycleEnv(envMap, cfg.RemoteEnv, vars)
+	runtimeEnv := seedImageEnv(ctx, cli, created.ID, envMap, options.logger())
+	lifecycleEnv, err := buildLifecycleEnv(runtimeEnv, cfg.RemoteEnv, vars)
 	if err != nil {
-		return created.ID, err
+		return result, err
 	}
 	remoteUser := cfg.RemoteUser
 	if remoteUser == "" {
@@ -227,13 +344,7 @@ func StartDevcontainer(ctx context.Context, opts ...StartOption) (string, error)
 			remoteUser = cfg.ContainerUser
 		}
 	}
-	runner := containerLifecycleRunner(cli, created.ID, workspaceFolder, remoteUser, vars, envMap, envMapToSlice(lifecycleEnv))
-	if features != nil {
-		rootRunner := containerLifecycleRunner(cli, created.ID, workspaceFolder, "root", vars, envMap, envMapToSlice(lifecycleEnv))
-		if err := runFeatureEntrypoints(ctx, features.Order, vars, rootRunner); err != nil {
-			return created.ID, err
-		}
-	}
+	runner := observeLifecycle(redactLifecycle(containerLifecycleRunner(cli, created.ID, workspaceFolder, remoteUser, options.StageUsers, vars, runtimeEnv, envMapToSlice(lifecycleEnv)), options.secretRedactor()), options.OnLifecycle)
 	userHooks := map[string]*LifecycleCommands{
 		"onCreateCommand":      cfg.OnCreateCommand,
 		"updateContentCommand": cfg.UpdateContentCommand,
@@ -241,29 +352,132 @@ func StartDevcontainer(ctx context.Context, opts ...StartOption) (string, error)
 		"postStartCommand":     cfg.PostStartCommand,
 		"postAttachCommand":    cfg.PostAttachCommand,
 	}
-	if err := runLifecycleWithFeatures(ctx, features, userHooks, runner); err != nil {
-		return created.ID, err
+	if err := runLifecycleWithFeatures(ctx, features, userHooks, runner, nil); err != nil {
+		return result, err
 	}
 
+	var watcher *portWatcher
+	if options.AutoForwardPorts {
+		watcher = newPortWatcher(cli, created.ID, exposedPorts, options.logger())
+		if err := watcher.scan(ctx); err != nil {
+			return result, err
+		}
+		result.DetectedPorts = watcher.ports()
+	}
+
+	lock.release()
 	if !options.Detach {
+		if watcher != nil {
+			watchCtx, stopWatch := context.WithCancel(ctx)
+			go watcher.watch(watchCtx)
+			defer func() {
+				stopWatch()
+				result.DetectedPorts = watcher.ports()
+			}()
+		}
 		statusCh, errCh := cli.ContainerWait(ctx, created.ID, container.WaitConditionNotRunning)
 		select {
 		case err := <-errCh:
 			if err != nil {
-				return created.ID, err
+				return result, err
 			}
 		case status := <-statusCh:
+			if session != nil {
+				session.drain()
+			}
 			if status.StatusCode != 0 {
-				return created.ID, fmt.Errorf("container exited with status %d", status.StatusCode)
+				return result, fmt.Errorf("container exited with status %d", status.StatusCode)
 			}
 		}
 	}
 
-	return created.ID, nil
+	return result, nil
+}
+
+// EffectiveConfig returns the devcontainer config that StartDevcontainer would use.
+// Impact: It loads devcontainer.json (or WithConfig), applies WithMergeConfig overlays, and validates the result without touching Docker.
+// Example:
+//
+//	cfg, err := devcontainer.EffectiveConfig(devcontainer.WithConfigPath("./.devcontainer/devcontainer.json"))
+//
+// Similar: LoadConfig only decodes a single file and does not apply overlays or validation.
+func EffectiveConfig(opts ...StartOption) (*DevcontainerConfig, error) {
+	options, err := applyStartOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	_, cfg, err := loadEffectiveConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadEffectiveConfig(options startOptions) (string, *DevcontainerConfig, error) {
+	configPath, cfg, err := loadMergedConfig(options)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := validateConfig(cfg); err != nil {
+		if !options.ComposeDiscovery || !errors.Is(err, errComposeFileRequired) {
+			return "", nil, err
+		}
+	}
+	return configPath, cfg, nil
+}
+
+// loadMergedConfig loads the config and applies WithMergeConfig overlays without validating the result.
+func loadMergedConfig(options startOptions) (string, *DevcontainerConfig, error) {
+	configPath, err := resolveConfigPath(options.ConfigPath, options.Config != nil)
+	if err != nil {
+		return "", nil, err
+	}
+	baseCfg := options.Config
+	if baseCfg == nil {
+		baseCfg, err = loadConfigWithLocal(configPath, options.NoLocalConfig)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	cfg := MergeConfig(nil, baseCfg)
+	for _, overlay := range options.MergeConfigs {
+		cfg = MergeConfig(cfg, overlay)
+	}
+	return configPath, cfg, nil
+}
+
+// VerifyPortPublished checks that a running devcontainer already publishes the port in spec, such as "3000" or
+// "8080:80"; it never publishes a port itself.
+// Impact: Docker fixes port bindings at create time, so a port that is not already published is rejected
+// with an error that points at recreating the container; nothing is changed on the container.
+// Example:
+//
+//	err := devcontainer.VerifyPortPublished(ctx, containerID, "3000")
+//
+// Similar: WithExtraPublish adds the port when the container is created.
+func VerifyPortPublished(ctx context.Context, containerID, spec string) error {
+	cli, err := newDockerClient()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Close()
+	}()
+
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	var bindings nat.PortMap
+	if inspect.HostConfig != nil {
+		bindings = inspect.HostConfig.PortBindings
+	}
+	return checkPortPublished(bindings, spec)
 }
 
 // StopDevcontainer stops the specified container.
-// Impact: It sends a stop request to Docker and uses the timeout as the grace period when provided.
+// Impact: It sends a stop request to Docker and uses the timeout as the grace period when provided; a container
+// that no longer exists counts as stopped. A workspace cloned by WithConfigFromGit is kept for a restart.
 // Example:
 //
 //	err := devcontainer.StopDevcontainer(ctx, containerID, 10*time.Second)
@@ -277,25 +491,38 @@ func StopDevcontainer(ctx context.Context, containerID string, timeout time.Dura
 	defer func() {
 		_ = cli.Close()
 	}()
+	if err := stopDevcontainer(ctx, cli, containerID, timeout); err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
 
+func stopDevcontainer(ctx context.Context, cli *client.Client, containerID string, timeout time.Duration) error {
 	target, ok, err := composeTargetFromContainer(ctx, cli, containerID)
 	if err != nil {
 		return err
 	}
-	if ok {
-		return composeStop(ctx, target.projectDir, target.projectName, target.composeFiles, timeout)
+	if ok && target.shutdownAction != shutdownActionStopContainer {
+		return composeStop(ctx, target.projectDir, target.projectName, target.composeFiles, target.overrideFile, timeout)
 	}
 	return stopContainer(ctx, cli, containerID, timeout)
 }
 
-// RemoveDevcontainer force-removes the specified container and its volumes.
-// Impact: The container and related volumes are deleted from Docker and cannot be restored.
+// RemoveDevcontainer force-removes the specified container.
+// Impact: The container is deleted from Docker; compose-backed containers run docker compose down, which keeps
+// named volumes unless WithRemoveVolumes is given. shutdownAction "stopContainer" limits removal to the primary container.
+// A container that no longer exists counts as removed unless WithRequireExisting is given, and a workspace cloned by
+// WithConfigFromGit is deleted.
 // Example:
 //
-//	err := devcontainer.RemoveDevcontainer(ctx, containerID)
+//	err := devcontainer.RemoveDevcontainer(ctx, containerID, devcontainer.WithRemoveVolumes())
 //
 // Similar: WithRemoveOnStop configures auto-removal on start rather than deleting existing containers.
-func RemoveDevcontainer(ctx context.Context, containerID string) error {
+func RemoveDevcontainer(ctx context.Context, containerID string, opts ...RemoveOption) error {
+	options := removeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
 	cli, err := newDockerClient()
 	if err != nil {
 		return err
@@ -303,21 +530,179 @@ func RemoveDevcontainer(ctx context.Context, containerID string) error {
 	defer func() {
 		_ = cli.Close()
 	}()
+	cloneDir := gitCloneDir(ctx, cli, containerID)
+	err = removeDevcontainer(ctx, cli, containerID, options)
+	if !options.RequireExists && cerrdefs.IsNotFound(err) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	return removeGitClone(cloneDir)
+}
 
+func removeDevcontainer(ctx context.Context, cli *client.Client, containerID string, options removeOptions) error {
 	target, ok, err := composeTargetFromContainer(ctx, cli, containerID)
 	if err != nil {
 		return err
 	}
-	if ok {
-		return composeDown(ctx, target.projectDir, target.projectName, target.composeFiles)
+	if ok && target.shutdownAction != shutdownActionStopContainer {
+		if err := composeDown(ctx, target.projectDir, target.projectName, target.composeFiles, target.overrideFile, options.RemoveVolumes); err != nil {
+			return err
+		}
+		if target.overrideFile != "" {
+			if err := os.Remove(target.overrideFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	}
+	if !options.RemoveVolumes {
+		return removeContainer(ctx, cli, containerID)
+	}
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if err := removeContainer(ctx, cli, containerID); err != nil {
+		return err
+	}
+	if inspect.Config == nil {
+		return nil
+	}
+	return removeLabeledVolumes(ctx, cli, inspect.Config.Labels["devcontainer.config_path"], inspect.Mounts)
+}
+
+// labelNamedVolumes labels named volume mounts with the config path; Docker applies the labels when it
+// creates a missing volume at container create, so removal can find the volumes later.
+func labelNamedVolumes(mounts []mount.Mount, configPath string) {
+	for i := range mounts {
+		if mounts[i].Type != mount.TypeVolume || mounts[i].Source == "" {
+			continue
+		}
+		if mounts[i].VolumeOptions == nil {
+			mounts[i].VolumeOptions = &mount.VolumeOptions{}
+		}
+		mounts[i].VolumeOptions.Labels = mergeLabels(mounts[i].VolumeOptions.Labels, map[string]string{"devcontainer.config_path": configPath})
+	}
+}
+
+// removeLabeledVolumes deletes the volumes godev created for configPath that the removed container mounted, so
+// volumes of sibling containers started from the same config are kept.
+func removeLabeledVolumes(ctx context.Context, cli *client.Client, configPath string, mounts []container.MountPoint) error {
+	mounted := make(map[string]bool)
+	for _, item := range mounts {
+		if item.Type == mount.TypeVolume && item.Name != "" {
+			mounted[item.Name] = true
+		}
+	}
+	if configPath == "" || len(mounted) == 0 {
+		return nil
+	}
+	volumes, err := cli.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "devcontainer.config_path="+configPath)),
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, item := range volumes.Volumes {
+		if !mounted[item.Name] {
+			continue
+		}
+		if err := cli.VolumeRemove(ctx, item.Name, false); err != nil {
+			errs = append(errs, fmt.Errorf("remove volume %s: %w", item.Name, err))
+		}
 	}
-	return removeContainer(ctx, cli, containerID)
+	return errors.Join(errs...)
 }
 
+// FindContainerID returns the running devcontainer started from configPath.
+// Impact: It lists running containers by the devcontainer.config_path label and, for compose configs,
+// falls back to docker compose ps for the primary service. It does not start or modify containers.
+// Example:
+//
+//	id, err := devcontainer.FindContainerID(ctx, "./.devcontainer/devcontainer.json")
+//
+// Similar: StartDevcontainer returns the ID when it creates the container.
+func FindContainerID(ctx context.Context, configPath string) (string, error) {
+	cli, err := newDockerClient()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = cli.Close()
+	}()
+	return findContainerID(ctx, cli, configPath)
+}
+
+func findContainerID(ctx context.Context, cli *client.Client, configPath string) (string, error) {
+	absConfig, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", err
+	}
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "devcontainer.config_path="+absConfig)),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(containers) > 0 {
+		return newestContainer(containers).ID, nil
+	}
+	cfg, err := LoadConfig(absConfig)
+	if err != nil {
+		return "", err
+	}
+	if isComposeConfig(cfg) {
+		workspaceRoot, _, vars, err := resolveComposeWorkspacePaths(absConfig, cfg)
+		if err != nil {
+			return "", err
+		}
+		composeFiles, err := resolveComposeFiles(absConfig, cfg)
+		if err != nil {
+			return "", err
+		}
+		projectName, err := composeTargetProjectName(nil, cfg, workspaceRoot, vars["devcontainerId"])
+		if err != nil {
+			return "", err
+		}
+		id, err := composePrimaryContainerID(ctx, workspaceRoot, projectName, composeFiles, "", cfg.Service)
+		if err != nil {
+			return "", fmt.Errorf("no running devcontainer found for %s: %w", absConfig, err)
+		}
+		return id, nil
+	}
+	return "", fmt.Errorf("no running devcontainer found for %s", absConfig)
+}
+
+// newestContainer picks one of several containers sharing a config path label: running ones first, then the most recently
+// created, with the ID as a final tie-break so the choice does not depend on daemon list order.
+func newestContainer(containers []container.Summary) container.Summary {
+	sorted := append([]container.Summary(nil), containers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		iRunning := sorted[i].State == container.StateRunning
+		jRunning := sorted[j].State == container.StateRunning
+		if iRunning != jRunning {
+			return iRunning
+		}
+		if sorted[i].Created != sorted[j].Created {
+			return sorted[i].Created > sorted[j].Created
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted[0]
+}
+
+// shutdownActionStopContainer limits compose stop/down to the primary service container.
+const shutdownActionStopContainer = "stopContainer"
+
 type composeTarget struct {
-	projectDir   string
-	projectName  string
-	composeFiles []string
+	projectDir     string
+	projectName    string
+	composeFiles   []string
+	overrideFile   string
+	shutdownAction string
 }
 
 func composeTargetFromContainer(ctx context.Context, cli *client.Client, containerID string) (*composeTarget, bool, error) {
@@ -332,27 +717,125 @@ func composeTargetFromContainer(ctx context.Context, cli *client.Client, contain
 	if configPath == "" {
 		return nil, false, nil
 	}
-	cfg, err := LoadConfig(configPath)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, false, nil
+	}
+	if !isComposeConfig(cfg) {
+		return nil, false, nil
+	}
+	workspaceRoot, _, vars, err := resolveComposeWorkspacePaths(configPath, cfg)
+	if err != nil {
+		return nil, false, err
+	}
+	composeFiles, err := composeFilesFromLabels(inspect.Config.Labels)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(composeFiles) == 0 {
+		composeFiles, err = resolveComposeFiles(configPath, cfg)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	projectName, err := composeTargetProjectName(inspect.Config.Labels, cfg, workspaceRoot, vars["devcontainerId"])
+	if err != nil {
+		return nil, false, err
+	}
+	return &composeTarget{
+		projectDir:     workspaceRoot,
+		projectName:    projectName,
+		composeFiles:   composeFiles,
+		overrideFile:   composeOverrideFromLabels(inspect.Config.Labels),
+		shutdownAction: cfg.ShutdownAction,
+	}, true, nil
+}
+
+// healthPollInterval is how often waitForHealthy inspects the container.
+var healthPollInterval = 500 * time.Millisecond
+
+// waitForHealthy polls until the container's HEALTHCHECK reports healthy.
+// Containers without a healthcheck are treated as ready immediately.
+func waitForHealthy(ctx context.Context, cli *client.Client, containerID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+	for {
+		inspect, err := cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("container %s did not become healthy within %s", containerID, timeout)
+			}
+			return err
+		}
+		if inspect.State == nil || inspect.State.Health == nil {
+			return nil
+		}
+		switch inspect.State.Health.Status {
+		case container.Healthy, container.NoHealthcheck:
+			return nil
+		case container.Unhealthy:
+			return fmt.Errorf("container %s is unhealthy", containerID)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container %s did not become healthy within %s", containerID, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// workspacePollInterval is how often waitForWorkspace re-checks the workspace folder.
+var workspacePollInterval = 500 * time.Millisecond
+
+// waitForWorkspace polls until workspaceFolder in the container holds marker, or any entry when marker is empty,
+// so create-time hooks do not run against a bind mount that is still being populated. The check needs test in the
+// container, or /bin/sh and ls when marker is empty.
+func waitForWorkspace(ctx context.Context, cli *client.Client, containerID, workspaceFolder, marker string, timeout time.Duration) error {
+	check := []string{"/bin/sh", "-c", `[ -n "$(ls -A "$1" 2>/dev/null)" ]`, "sh", workspaceFolder}
+	if marker != "" {
+		check = []string{"test", "-e", path.Join(workspaceFolder, marker)}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(workspacePollInterval)
+	defer ticker.Stop()
+	for {
+		exitCode, err := execExitCode(ctx, cli, containerID, check)
+		if err == nil && exitCode == 0 {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("check workspace %s: %w", workspaceFolder, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("workspace %s was not ready within %s", workspaceFolder, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// execExitCode runs cmd in the container, discarding its output, and returns its exit code.
+func execExitCode(ctx context.Context, cli *client.Client, containerID string, cmd []string) (int, error) {
+	execResp, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{Cmd: cmd, AttachStdout: true, AttachStderr: true})
 	if err != nil {
-		return nil, false, nil
-	}
-	if !isComposeConfig(cfg) {
-		return nil, false, nil
+		return 0, err
 	}
-	workspaceRoot, _, vars, err := resolveComposeWorkspacePaths(configPath, cfg)
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
 	if err != nil {
-		return nil, false, err
+		return 0, err
+	}
+	defer resp.Close()
+	if _, err := stdcopy.StdCopy(io.Discard, io.Discard, resp.Reader); err != nil {
+		return 0, err
 	}
-	composeFiles, err := resolveComposeFiles(configPath, cfg)
+	inspect, err := cli.ContainerExecInspect(ctx, execResp.ID)
 	if err != nil {
-		return nil, false, err
+		return 0, err
 	}
-	projectName := resolveComposeProjectName(cfg, workspaceRoot, vars["devcontainerId"])
-	return &composeTarget{
-		projectDir:   workspaceRoot,
-		projectName:  projectName,
-		composeFiles: composeFiles,
-	}, true, nil
+	return inspect.ExitCode, nil
 }
 
 func stopContainer(ctx context.Context, cli *client.Client, containerID string, timeout time.Duration) error {
@@ -374,11 +857,18 @@ func removeContainer(ctx context.Context, cli *client.Client, containerID string
 //	imageRef, err := devcontainer.BuildImageFromDevcontainer(ctx, "./.devcontainer/devcontainer.json")
 //
 // Similar: StartDevcontainer builds images and also starts containers and runs lifecycle hooks.
-func BuildImageFromDevcontainer(ctx context.Context, configPath string) (string, error) {
-	cfg, err := LoadConfig(configPath)
+func BuildImageFromDevcontainer(ctx context.Context, configPath string, opts ...StartOption) (string, error) {
+	options, err := applyStartOptions(opts)
 	if err != nil {
 		return "", err
 	}
+	cfg, err := loadConfigWithLocal(configPath, options.NoLocalConfig)
+	if err != nil {
+		return "", err
+	}
+	if err := preflightDocker(ctx, options); err != nil {
+		return "", err
+	}
 	if err := validateConfig(cfg); err != nil {
 		return "", err
 	}
@@ -389,10 +879,15 @@ func BuildImageFromDevcontainer(ctx context.Context, configPath string) (string,
 	if err != nil {
 		return "", err
 	}
-	features, err := resolveFeatures(ctx, configPath, workspaceRoot, cfg)
+	overrideDevcontainerID(vars, options.DevcontainerID)
+	features, err := resolveStartFeatures(ctx, configPath, workspaceRoot, cfg, vars, options)
 	if err != nil {
 		return "", err
 	}
+	defer features.removeTempDirs()
+	if err := verifyFeatureVersions(features, options.StrictFeatures); err != nil {
+		return "", err
+	}
 	cli, err := newDockerClient()
 	if err != nil {
 		return "", err
@@ -400,30 +895,59 @@ func BuildImageFromDevcontainer(ctx context.Context, configPath string) (string,
 	defer func() {
 		_ = cli.Close()
 	}()
-	imageRef, err := buildImage(ctx, cli, cfg, configPath, workspaceRoot, vars["devcontainerId"])
+	imageRef, err := buildImage(ctx, cli, cfg, configPath, workspaceRoot, vars["devcontainerId"], options)
 	if err != nil {
 		return "", err
 	}
 	if features == nil {
-		return imageRef, nil
+		return imageRef, publishImage(ctx, cli, imageRef, options)
 	}
-	baseUser, err := imageDefaultUser(ctx, cli, imageRef)
+	platform, err := parsePlatform(options.Platform)
 	if err != nil {
 		return "", err
 	}
-	return buildFeaturesImage(ctx, cli, imageRef, baseUser, workspaceRoot, vars["devcontainerId"], cfg, features.Order, vars)
-}
-
-func buildMounts(workspaceMount string, configMounts []MountSpec, extraMounts []Mount, vars map[string]string) ([]mount.Mount, error) {
-	expandedWorkspace, err := expandVariables(workspaceMount, vars, nil)
+	baseUser, err := imageDefaultUser(ctx, cli, imageRef, platform)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	workspaceParsed, err := parseMountString(expandedWorkspace)
+	imageRef, err = buildFeaturesImage(ctx, cli, imageRef, baseUser, workspaceRoot, vars["devcontainerId"], cfg, features.Order, vars, options)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return imageRef, publishImage(ctx, cli, imageRef, options)
+}
+
+// workspaceMountSpec returns the WithWorkspaceMount override, or the computed spec when none is set.
+// It returns "" under WithoutWorkspaceMount and rejects configurations that still expect a workspace mount.
+func workspaceMountSpec(options startOptions, cfg *DevcontainerConfig, computed string) (string, error) {
+	if options.NoWorkspaceMount {
+		if options.WorkspaceMount != "" {
+			return "", errors.New("WithoutWorkspaceMount cannot be combined with WithWorkspaceMount")
+		}
+		if cfg.WorkspaceMount != "" {
+			return "", errors.New("workspaceMount is set in devcontainer.json but the workspace mount is disabled")
+		}
+		return "", nil
+	}
+	if options.WorkspaceMount != "" {
+		return options.WorkspaceMount, nil
+	}
+	return computed, nil
+}
+
+func buildMounts(workspaceMount string, configMounts []MountSpec, extraMounts []Mount, vars map[string]string) ([]mount.Mount, error) {
+	var mounts []mount.Mount
+	if workspaceMount != "" {
+		expandedWorkspace, err := expandVariables(workspaceMount, vars, nil)
+		if err != nil {
+			return nil, err
+		}
+		workspaceParsed, err := parseMountString(expandedWorkspace)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, workspaceParsed)
 	}
-	mounts := []mount.Mount{workspaceParsed}
 
 	for _, spec := range configMounts {
 		if spec.Raw != "" {
@@ -435,6 +959,9 @@ func buildMounts(workspaceMount string, configMounts []MountSpec, extraMounts []
 			if err != nil {
 				return nil, err
 			}
+			if isMountShorthand(expanded) {
+				resolveShorthandSource(&parsed, vars)
+			}
 			mounts = append(mounts, parsed)
 			continue
 		}
@@ -450,11 +977,252 @@ func buildMounts(workspaceMount string, configMounts []MountSpec, extraMounts []
 		if err != nil {
 			return nil, err
 		}
+		if extra.shorthand {
+			resolveShorthandSource(&parsed, vars)
+		}
 		mounts = append(mounts, parsed)
 	}
 	return mounts, nil
 }
 
+// resolveShorthandSource resolves the relative bind source of a source:target mount against the local workspace
+// folder, so the shorthand means the same path in devcontainer.json and in WithMountSpec.
+func resolveShorthandSource(m *mount.Mount, vars map[string]string) {
+	if m.Type == mount.TypeBind && !filepath.IsAbs(m.Source) && vars["localWorkspaceFolder"] != "" {
+		m.Source = filepath.Join(vars["localWorkspaceFolder"], m.Source)
+	}
+}
+
+// describeContainer fills the result's container name and published ports from an inspect of the started container.
+// A container that already exited and was auto-removed leaves them empty rather than failing the start.
+func describeContainer(ctx context.Context, cli *client.Client, result *StartResult) error {
+	inspect, err := cli.ContainerInspect(ctx, result.ContainerID)
+	if cerrdefs.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.ContainerName = strings.TrimPrefix(inspect.Name, "/")
+	result.Ports = nil
+	if inspect.NetworkSettings == nil {
+		return nil
+	}
+	ports := make([]nat.Port, 0, len(inspect.NetworkSettings.Ports))
+	for port := range inspect.NetworkSettings.Ports {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Int() != ports[j].Int() {
+			return ports[i].Int() < ports[j].Int()
+		}
+		return ports[i].Proto() < ports[j].Proto()
+	})
+	for _, port := range ports {
+		for _, binding := range inspect.NetworkSettings.Ports[port] {
+			result.Ports = append(result.Ports, PublishedPort{ContainerPort: string(port), HostIP: binding.HostIP, HostPort: binding.HostPort})
+		}
+	}
+	return nil
+}
+
+// newContainerConfig assembles the Docker container Config; start options take precedence over runArgs.
+func newContainerConfig(cfg *DevcontainerConfig, options startOptions, runArgOptions runArgOptions, imageRef string, envMap map[string]string, exposedPorts nat.PortSet, workingDir string, labels map[string]string) *container.Config {
+	containerConfig := &container.Config{
+		Image:        imageRef,
+		Env:          envMapToSlice(envMap),
+		ExposedPorts: exposedPorts,
+		WorkingDir:   workingDir,
+		Tty:          options.TTY,
+		User:         cfg.ContainerUser,
+		Labels:       labels,
+		Hostname:     runArgOptions.Hostname,
+	}
+	if runArgOptions.User != "" {
+		containerConfig.User = runArgOptions.User
+	}
+	if options.Hostname != "" {
+		containerConfig.Hostname = options.Hostname
+	}
+	containerConfig.Entrypoint = entrypointOverride(options, runArgOptions)
+	if options.attachStdio() {
+		containerConfig.AttachStdin = true
+		containerConfig.AttachStdout = true
+		containerConfig.AttachStderr = true
+		containerConfig.OpenStdin = true
+		containerConfig.StdinOnce = true
+	}
+	containerConfig.StopSignal = options.StopSignal
+	if options.StopTimeout > 0 {
+		stopTimeout := int(options.StopTimeout.Seconds())
+		containerConfig.StopTimeout = &stopTimeout
+	}
+	return containerConfig
+}
+
+// entrypointOverride returns the entrypoint from WithEntrypoint or an --entrypoint runArg, or nil to keep the
+// image's. A cleared entrypoint is [""], which Docker takes as "no entrypoint" rather than "unset".
+func entrypointOverride(options startOptions, runArgOptions runArgOptions) []string {
+	entrypoint := runArgOptions.Entrypoint
+	if options.Entrypoint != nil {
+		entrypoint = options.Entrypoint
+	}
+	if entrypoint != nil && len(entrypoint) == 0 {
+		return []string{""}
+	}
+	return entrypoint
+}
+
+// newNetworkingConfig returns endpoint settings for --mac-address, --ip, and --ip6 on the container's
+// network, or nil when none were given. The network mode names the endpoint, as docker run does.
+func newNetworkingConfig(hostConfig *container.HostConfig, runArgOptions runArgOptions) *network.NetworkingConfig {
+	if runArgOptions.MacAddress == "" && runArgOptions.IPv4Address == "" && runArgOptions.IPv6Address == "" {
+		return nil
+	}
+	endpoint := &network.EndpointSettings{MacAddress: runArgOptions.MacAddress}
+	if runArgOptions.IPv4Address != "" || runArgOptions.IPv6Address != "" {
+		endpoint.IPAMConfig = &network.EndpointIPAMConfig{
+			IPv4Address: runArgOptions.IPv4Address,
+			IPv6Address: runArgOptions.IPv6Address,
+		}
+	}
+	networkName := string(hostConfig.NetworkMode)
+	if networkName == "" {
+		networkName = "default"
+	}
+	return &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{networkName: endpoint}}
+}
+
+// newHostConfig assembles the Docker HostConfig from devcontainer.json, start options, and parsed runArgs.
+func newHostConfig(cfg *DevcontainerConfig, options startOptions, runArgOptions runArgOptions, mounts []mount.Mount, portBindings nat.PortMap) (*container.HostConfig, error) {
+	hostConfig := &container.HostConfig{
+		PortBindings: portBindings,
+		Mounts:       mounts,
+		AutoRemove:   options.RemoveOnStop,
+		Privileged:   cfg.Privileged || runArgOptions.Privileged,
+		CapAdd:       append([]string{}, cfg.CapAdd...),
+		CapDrop:      append([]string{}, cfg.CapDrop...),
+		SecurityOpt:  appendUnique(nil, cfg.SecurityOpt...),
+	}
+
+	if options.ReadOnlyRootfs || runArgOptions.ReadOnly {
+		hostConfig.ReadonlyRootfs = true
+	}
+
+	if runArgOptions.Init {
+		hostConfig.Init = &runArgOptions.Init
+	} else if cfg.Init != nil {
+		hostConfig.Init = cfg.Init
+	}
+
+	if len(runArgOptions.CapAdd) > 0 {
+		hostConfig.CapAdd = append(hostConfig.CapAdd, runArgOptions.CapAdd...)
+	}
+	if len(runArgOptions.CapDrop) > 0 {
+		hostConfig.CapDrop = append(hostConfig.CapDrop, runArgOptions.CapDrop...)
+	}
+	if len(runArgOptions.SecurityOpt) > 0 {
+		hostConfig.SecurityOpt = appendUnique(hostConfig.SecurityOpt, runArgOptions.SecurityOpt...)
+	}
+	if options.NoNewPrivileges && !hasNoNewPrivileges(hostConfig.SecurityOpt) {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, noNewPrivilegesOpt)
+	}
+
+	if options.Network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(options.Network)
+	} else if runArgOptions.Network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(runArgOptions.Network)
+	}
+
+	hostConfig.MemoryReservation = runArgOptions.MemoryRes
+	hostConfig.CPUShares = runArgOptions.CPUShares
+	if options.Resources.CPUQuota != 0 {
+		hostConfig.CPUQuota = options.Resources.CPUQuota
+	}
+	if options.Resources.CPUShares != 0 {
+		hostConfig.CPUShares = options.Resources.CPUShares
+	}
+	if runArgOptions.PidsLimit != 0 {
+		hostConfig.PidsLimit = &runArgOptions.PidsLimit
+	}
+	if options.Resources.PidsLimit != 0 {
+		limit := options.Resources.PidsLimit
+		hostConfig.PidsLimit = &limit
+	}
+	if options.Resources.MemoryReservation != "" {
+		bytes, err := parseMemoryReservation(options.Resources.MemoryReservation)
+		if err != nil {
+			return nil, err
+		}
+		hostConfig.MemoryReservation = bytes
+	}
+	if options.Resources.Memory != "" {
+		bytes, err := units.RAMInBytes(options.Resources.Memory)
+		if err != nil {
+			return nil, err
+		}
+		hostConfig.Memory = bytes
+	}
+	return hostConfig, nil
+}
+
+// noNewPrivilegesOpt is the security option added by WithNoNewPrivileges.
+const noNewPrivilegesOpt = "no-new-privileges:true"
+
+// hasNoNewPrivileges reports whether opts already enable no-new-privileges in any accepted spelling.
+func hasNoNewPrivileges(opts []string) bool {
+	for _, opt := range opts {
+		switch opt {
+		case "no-new-privileges", "no-new-privileges:true", "no-new-privileges=true":
+			return true
+		}
+	}
+	return false
+}
+
+// loadSeccompProfiles inlines seccomp profiles given as file paths, since Docker expects the JSON itself.
+// Relative paths resolve against configDir; "unconfined", "builtin", and inline JSON pass through.
+func loadSeccompProfiles(opts []string, configDir string) ([]string, error) {
+	loaded := make([]string, 0, len(opts))
+	for _, opt := range opts {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			key, value, ok = strings.Cut(opt, ":")
+		}
+		if !ok || key != "seccomp" || value == "unconfined" || value == "builtin" || strings.HasPrefix(strings.TrimSpace(value), "{") {
+			loaded = append(loaded, opt)
+			continue
+		}
+		profilePath := value
+		if !filepath.IsAbs(profilePath) {
+			profilePath = filepath.Join(configDir, profilePath)
+		}
+		content, err := os.ReadFile(profilePath)
+		if err != nil {
+			return nil, fmt.Errorf("read seccomp profile %s: %w", value, err)
+		}
+		loaded = append(loaded, "seccomp="+strings.TrimSpace(string(content)))
+	}
+	return loaded, nil
+}
+
+// validateBindSources reports every bind mount whose host source path is missing.
+func validateBindSources(mounts []mount.Mount) error {
+	var missing []string
+	for _, m := range mounts {
+		if m.Type != mount.TypeBind {
+			continue
+		}
+		if _, err := os.Stat(m.Source); err != nil {
+			missing = append(missing, m.Source)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("bind mount source not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 func resolveConfigPath(path string, allowMissing bool) (string, error) {
 	if path != "" {
 		return filepath.Abs(path)
@@ -476,7 +1244,7 @@ func resolveConfigPath(path string, allowMissing bool) (string, error) {
 	return filepath.Join(cwd, "devcontainer.json"), nil
 }
 
-func ensureImage(ctx context.Context, cli *client.Client, cfg *DevcontainerConfig, configPath, workspaceRoot, devcontainerID string) (string, error) {
+func ensureImage(ctx context.Context, cli *client.Client, cfg *DevcontainerConfig, configPath, workspaceRoot, devcontainerID string, options startOptions) (string, error) {
 	if cfg.Image != "" && cfg.Build != nil {
 		return "", errors.New("both image and build are set in devcontainer.json")
 	}
@@ -484,22 +1252,22 @@ func ensureImage(ctx context.Context, cli *client.Client, cfg *DevcontainerConfi
 		return "", errors.New("devcontainer.json must specify image or build")
 	}
 	if cfg.Image != "" {
-		if err := pullImage(ctx, cli, cfg.Image); err != nil {
+		if err := pullImage(ctx, cli, cfg.Image, options.Platform, options); err != nil {
 			return "", err
 		}
 		return cfg.Image, nil
 	}
-	return buildImage(ctx, cli, cfg, configPath, workspaceRoot, devcontainerID)
+	return buildImage(ctx, cli, cfg, configPath, workspaceRoot, devcontainerID, options)
 }
 
-func buildImage(ctx context.Context, cli *client.Client, cfg *DevcontainerConfig, configPath, workspaceRoot, devcontainerID string) (string, error) {
+func buildImage(ctx context.Context, cli *client.Client, cfg *DevcontainerConfig, configPath, workspaceRoot, devcontainerID string, options startOptions) (string, error) {
 	if cfg.Build == nil {
 		return "", errors.New("build config is required")
 	}
 	if len(cfg.Build.Options) > 0 {
 		return "", errors.New("build.options is not supported yet")
 	}
-	contextDir, dockerfileRel, err := resolveBuildPaths(configPath, cfg.Build)
+	contextDir, dockerfileRel, err := resolveBuildPaths(configPath, cfg.Build, options.BuildContext)
 	if err != nil {
 		return "", err
 	}
@@ -512,32 +1280,87 @@ func buildImage(ctx context.Context, cli *client.Client, cfg *DevcontainerConfig
 	}()
 
 	tag := imageTagForBuild(workspaceRoot, devcontainerID)
-	buildArgs := make(map[string]*string, len(cfg.Build.Args))
-	for key, value := range cfg.Build.Args {
-		val := value
-		buildArgs[key] = &val
-	}
-
-	resp, err := cli.ImageBuild(ctx, buildContext, build.ImageBuildOptions{
-		Dockerfile: dockerfileRel,
-		Tags:       []string{tag},
-		Remove:     true,
-		BuildArgs:  buildArgs,
-		Target:     cfg.Build.Target,
-		CacheFrom:  []string(cfg.Build.CacheFrom),
-	})
+	resp, err := cli.ImageBuild(ctx, buildContext, newImageBuildOptions(cfg.Build, dockerfileRel, tag, options))
 	if err != nil {
 		return "", err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		return "", err
+	redactor := options.secretRedactor()
+	output, flush := redactWriter(options.BuildOutput, redactor)
+	err = consumeImageOutput(resp.Body, output)
+	flush()
+	if err != nil {
+		return "", redactError(fmt.Errorf("build image %s: %w", tag, err), redactor)
 	}
 	return tag, nil
 }
 
+// consumeImageOutput copies the build output and pull status lines of a docker response stream to w, when set,
+// skipping progress bars, and returns the error the stream reports.
+func consumeImageOutput(r io.Reader, w io.Writer) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var message buildMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		if w == nil {
+			continue
+		}
+		text := message.Stream
+		if message.Status != "" && message.Progress == "" {
+			text = message.Status + "\n"
+			if message.ID != "" {
+				text = message.ID + ": " + text
+			}
+		}
+		if _, err := io.WriteString(w, text); err != nil {
+			return err
+		}
+	}
+}
+
+func newImageBuildOptions(cfg *DevcontainerBuild, dockerfile, tag string, options startOptions) build.ImageBuildOptions {
+	buildArgs := make(map[string]*string, len(cfg.Args)+len(options.FileBuildArgs)+len(options.BuildArgs))
+	for key, value := range cfg.Args {
+		val := value
+		buildArgs[key] = &val
+	}
+	for key, value := range options.FileBuildArgs {
+		val := value
+		buildArgs[key] = &val
+	}
+	for key, value := range options.BuildArgs {
+		val := value
+		buildArgs[key] = &val
+	}
+	target := cfg.Target
+	if options.BuildTarget != "" {
+		target = options.BuildTarget
+	}
+	return build.ImageBuildOptions{
+		Dockerfile:  dockerfile,
+		Tags:        []string{tag},
+		Remove:      true,
+		BuildArgs:   buildArgs,
+		Target:      target,
+		CacheFrom:   []string(cfg.CacheFrom),
+		NoCache:     options.NoBuildCache,
+		PullParent:  options.PullBuildBase,
+		Platform:    options.Platform,
+		NetworkMode: options.BuildNetwork,
+		ExtraHosts:  options.BuildExtraHosts,
+	}
+}
+
 func tarDirectory(dir string) (io.ReadCloser, error) {
 	pipeReader, pipeWriter := io.Pipe()
 	tarWriter := tar.NewWriter(pipeWriter)
@@ -601,25 +1424,124 @@ func tarDirectory(dir string) (io.ReadCloser, error) {
 	return pipeReader, nil
 }
 
-func pullImage(ctx context.Context, cli *client.Client, imageRef string) error {
-	reader, err := cli.ImagePull(ctx, imageRef, image.PullOptions{})
+func pullImage(ctx context.Context, cli *client.Client, imageRef, platform string, options startOptions) error {
+	auth, err := imageRegistryAuth(imageRef, options)
 	if err != nil {
 		return err
 	}
+	reader, err := cli.ImagePull(ctx, imageRef, image.PullOptions{Platform: platform, RegistryAuth: auth})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	output, flush := redactWriter(options.BuildOutput, options.secretRedactor())
+	err = consumeImageOutput(reader, output)
+	flush()
+	if err != nil {
+		return fmt.Errorf("pull image %s: %w", imageRef, err)
+	}
+	return nil
+}
+
+// imageRegistryAuth returns the encoded X-Registry-Auth value for the registry hosting imageRef, taken from
+// WithRegistryAuth or the docker config, or "" when there are no credentials for it.
+func imageRegistryAuth(imageRef string, options startOptions) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return "", err
+	}
+	host := registryAuthKey(reference.Domain(named))
+	creds, ok := options.RegistryAuth[host]
+	if !ok {
+		creds = newRegistryClient(options.TempDir).lookupAuth(host)
+	}
+	if creds == (registryAuth{}) {
+		return "", nil
+	}
+	return registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      creds.username,
+		Password:      creds.password,
+		IdentityToken: creds.identityToken,
+		ServerAddress: host,
+	})
+}
+
+// registryAuthKey maps a registry host to its docker config key; Docker Hub is stored under its legacy index URL.
+func registryAuthKey(host string) string {
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return "https://index.docker.io/v1/"
+	}
+	return host
+}
+
+// publishImage applies WithImageTag to imageRef and, with WithPushImage, pushes the tag using the WithRegistryAuth
+// credentials for its registry, or config.json credentials when none are set.
+func publishImage(ctx context.Context, cli *client.Client, imageRef string, options startOptions) error {
+	if options.ImageTag == "" {
+		return nil
+	}
+	// A tag-less reference would push every local tag of the repository, so it is pinned to :latest first.
+	named, err := reference.ParseNormalizedNamed(options.ImageTag)
+	if err != nil {
+		return err
+	}
+	tag := reference.FamiliarString(reference.TagNameOnly(named))
+	if err := cli.ImageTag(ctx, imageRef, tag); err != nil {
+		return fmt.Errorf("tag image %s: %w", tag, err)
+	}
+	if !options.PushImage {
+		return nil
+	}
+	encoded, err := imageRegistryAuth(tag, options)
+	if err != nil {
+		return err
+	}
+	reader, err := cli.ImagePush(ctx, tag, image.PushOptions{RegistryAuth: encoded})
+	if err != nil {
+		return fmt.Errorf("push image %s: %w", tag, err)
+	}
 	defer func() {
 		_ = reader.Close()
 	}()
-	_, err = io.Copy(io.Discard, reader)
-	return err
+	decoder := json.NewDecoder(reader)
+	for {
+		var message buildMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if message.Error != "" {
+			return fmt.Errorf("push image %s: %s", tag, message.Error)
+		}
+	}
 }
 
-func resolveBuildPaths(configPath string, build *DevcontainerBuild) (string, string, error) {
+func resolveBuildPaths(configPath string, build *DevcontainerBuild, contextOverride string) (string, string, error) {
 	configDir := filepath.Dir(configPath)
 	contextPath := build.Context
 	if contextPath == "" {
 		contextPath = "."
 	}
 	contextDir := filepath.Clean(filepath.Join(configDir, contextPath))
+	if contextOverride != "" {
+		abs, err := filepath.Abs(contextOverride)
+		if err != nil {
+			return "", "", err
+		}
+		stat, err := os.Stat(abs)
+		if err != nil {
+			return "", "", fmt.Errorf("build context not found: %s", contextOverride)
+		}
+		if !stat.IsDir() {
+			return "", "", fmt.Errorf("build context is not a directory: %s", contextOverride)
+		}
+		contextDir = abs
+	}
 	if build.Dockerfile == "" {
 		return "", "", errors.New("build.dockerfile is required")
 	}
@@ -673,6 +1595,24 @@ func resolveContainerName(configName, workspaceRoot, devcontainerID string) stri
 	return fmt.Sprintf("godev-%s-%s", base, devcontainerID)
 }
 
+// containerLabels merges user labels with the labels godev relies on to find the config again.
+// WithToolingLabels adds the labels other devcontainer tooling uses to discover containers.
+func containerLabels(options startOptions, runArgLabels map[string]string, configPath, workspaceRoot string) (map[string]string, error) {
+	labels := mergeLabels(mergeLabels(options.FileLabels, options.Labels), runArgLabels)
+	if _, ok := labels[gitCloneLabel]; ok {
+		return nil, fmt.Errorf("label %s is reserved for WithConfigFromGit", gitCloneLabel)
+	}
+	if options.GitClone != "" {
+		labels[gitCloneLabel] = options.GitClone
+	}
+	labels["devcontainer.config_path"] = configPath
+	if options.ToolingLabels {
+		labels["devcontainer.local_folder"] = workspaceRoot
+		labels["devcontainer.config_file"] = configPath
+	}
+	return labels, nil
+}
+
 func mergeLabels(base, overlay map[string]string) map[string]string {
 	merged := make(map[string]string)
 	for key, value := range base {
@@ -687,6 +1627,37 @@ func mergeLabels(base, overlay map[string]string) map[string]string {
 	return merged
 }
 
+// newDockerClient connects using the DOCKER_* environment; ssh:// hosts are tunneled through the local ssh binary.
 func newDockerClient() (*client.Client, error) {
-	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if host := os.Getenv(client.EnvOverrideHost); strings.HasPrefix(host, "ssh://") {
+		sshOpts, err := sshClientOptions(host)
+		if err != nil {
+			return nil, classifyError(ErrDockerUnavailable, err)
+		}
+		opts = append(opts, sshOpts...)
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, classifyError(ErrDockerUnavailable, err)
+	}
+	return cli, nil
+}
+
+// preflightDocker pings the daemon so connection problems fail fast with the endpoint in the error.
+func preflightDocker(ctx context.Context, options startOptions) error {
+	if options.SkipPreflight {
+		return nil
+	}
+	cli, err := newDockerClient()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = cli.Close()
+	}()
+	if _, err := cli.Ping(ctx); err != nil {
+		return classifyError(ErrDockerUnavailable, fmt.Errorf("docker daemon unreachable at %s: %w", dockerEndpoint(cli), err))
+	}
+	return nil
 }
@@ -0,0 +1,1590 @@
+package godev
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+)
+
+func TestResolveBuildPaths_ContextOverride(t *testing.T) {
+	root := t.TempDir()
+	configDir := filepath.Join(root, ".devcontainer")
+	if err := os.MkdirAll(filepath.Join(root, "docker"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(configDir, "devcontainer.json")
+	build := &DevcontainerBuild{Dockerfile: "../docker/Dockerfile", Context: "."}
+
+	if _, _, err := resolveBuildPaths(configPath, build, ""); err == nil {
+		t.Fatalf("expected error for dockerfile outside default context")
+	}
+
+	contextDir, dockerfile, err := resolveBuildPaths(configPath, build, root)
+	if err != nil {
+		t.Fatalf("resolveBuildPaths: %v", err)
+	}
+	if contextDir != root {
+		t.Fatalf("unexpected context dir: %s", contextDir)
+	}
+	if dockerfile != "docker/Dockerfile" {
+		t.Fatalf("unexpected dockerfile: %s", dockerfile)
+	}
+
+	if _, _, err := resolveBuildPaths(configPath, build, filepath.Join(root, "docker")); err != nil {
+		t.Fatalf("resolveBuildPaths docker dir: %v", err)
+	}
+	if _, _, err := resolveBuildPaths(configPath, build, configDir); err == nil {
+		t.Fatalf("expected error for dockerfile outside overridden context")
+	}
+	if _, _, err := resolveBuildPaths(configPath, build, filepath.Join(root, "missing")); err == nil {
+		t.Fatalf("expected error for missing context")
+	}
+
+	t.Chdir(root)
+	contextDir, _, err = resolveBuildPaths(configPath, build, ".")
+	if err != nil {
+		t.Fatalf("resolveBuildPaths relative override: %v", err)
+	}
+	if contextDir != root {
+		t.Fatalf("expected relative override to resolve against the working directory, got %s", contextDir)
+	}
+}
+
+func TestNewImageBuildOptions_TargetOverride(t *testing.T) {
+	cfg := &DevcontainerBuild{
+		Args:      map[string]string{"FOO": "bar"},
+		Target:    "ci",
+		CacheFrom: StringSlice{"cache:latest"},
+	}
+	options := defaultStartOptions()
+	got := newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options)
+	if got.Target != "ci" {
+		t.Fatalf("expected config target, got %s", got.Target)
+	}
+	if got.BuildArgs["FOO"] == nil || *got.BuildArgs["FOO"] != "bar" {
+		t.Fatalf("unexpected build args: %#v", got.BuildArgs)
+	}
+	if len(got.CacheFrom) != 1 || got.CacheFrom[0] != "cache:latest" {
+		t.Fatalf("unexpected cache from: %#v", got.CacheFrom)
+	}
+
+	WithBuildTarget("dev")(&options)
+	got = newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options)
+	if got.Target != "dev" {
+		t.Fatalf("expected overridden target, got %s", got.Target)
+	}
+}
+
+func TestNewImageBuildOptions_BuildArgOverride(t *testing.T) {
+	cfg := &DevcontainerBuild{Args: map[string]string{"VERSION": "1.0", "BASE": "alpine"}}
+	options, err := applyStartOptions([]StartOption{WithBuildArg("VERSION", "2.0"), WithBuildArg("COMMIT_SHA", "abc123")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	got := newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options)
+	expected := map[string]string{"VERSION": "2.0", "BASE": "alpine", "COMMIT_SHA": "abc123"}
+	if len(got.BuildArgs) != len(expected) {
+		t.Fatalf("unexpected build args: %#v", got.BuildArgs)
+	}
+	for key, value := range expected {
+		if got.BuildArgs[key] == nil || *got.BuildArgs[key] != value {
+			t.Fatalf("expected %s=%s, got %#v", key, value, got.BuildArgs[key])
+		}
+	}
+	if cfg.Args["VERSION"] != "1.0" {
+		t.Fatalf("config args should not be modified: %#v", cfg.Args)
+	}
+}
+
+func TestNewImageBuildOptions_BuildArgFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.args")
+	content := "# generated by CI\nVERSION=3.0\nCOMMIT_SHA=abc123\nMIRROR=\"https://mirror.example\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write build arg file: %v", err)
+	}
+	cfg := &DevcontainerBuild{Args: map[string]string{"VERSION": "1.0", "BASE": "alpine"}}
+	options, err := applyStartOptions([]StartOption{WithBuildArg("COMMIT_SHA", "def456"), WithBuildArgFile(path)})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	got := newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options)
+	expected := map[string]string{"VERSION": "3.0", "BASE": "alpine", "COMMIT_SHA": "def456", "MIRROR": "https://mirror.example"}
+	if len(got.BuildArgs) != len(expected) {
+		t.Fatalf("unexpected build args: %#v", got.BuildArgs)
+	}
+	for key, value := range expected {
+		if got.BuildArgs[key] == nil || *got.BuildArgs[key] != value {
+			t.Fatalf("expected %s=%s, got %#v", key, value, got.BuildArgs[key])
+		}
+	}
+
+	if _, err := applyStartOptions([]StartOption{WithBuildArgFile(filepath.Join(t.TempDir(), "missing"))}); err == nil {
+		t.Fatal("expected missing build arg file to fail")
+	}
+}
+
+func TestNoBuildCache_AppliesToBothBuilds(t *testing.T) {
+	cfg := &DevcontainerBuild{CacheFrom: StringSlice{"cache:latest"}}
+	options := defaultStartOptions()
+	if newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options).NoCache || newFeatureImageBuildOptions("features:latest", options).NoCache {
+		t.Fatal("expected cache to be used by default")
+	}
+
+	WithNoBuildCache()(&options)
+	image := newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options)
+	if !image.NoCache {
+		t.Fatal("expected image build without cache")
+	}
+	if len(image.CacheFrom) != 1 || image.CacheFrom[0] != "cache:latest" {
+		t.Fatalf("cacheFrom should be unaffected: %#v", image.CacheFrom)
+	}
+	if !newFeatureImageBuildOptions("features:latest", options).NoCache {
+		t.Fatal("expected features build without cache")
+	}
+}
+
+func TestPullBuildBase_SetsPullParent(t *testing.T) {
+	cfg := &DevcontainerBuild{}
+	options := defaultStartOptions()
+	if newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options).PullParent {
+		t.Fatal("expected the base image not to be pulled by default")
+	}
+	WithPullBuildBase()(&options)
+	if !newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options).PullParent {
+		t.Fatal("expected the image build to pull its base")
+	}
+	if newFeatureImageBuildOptions("features:latest", options).PullParent {
+		t.Fatal("features build must not pull its locally built base")
+	}
+}
+
+func TestBuildNetwork_SetsNetworkMode(t *testing.T) {
+	cfg := &DevcontainerBuild{}
+	options := defaultStartOptions()
+	if mode := newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options).NetworkMode; mode != "" {
+		t.Fatalf("expected default build network, got %q", mode)
+	}
+	WithBuildNetwork("host")(&options)
+	if mode := newImageBuildOptions(cfg, "Dockerfile", "tag:latest", options).NetworkMode; mode != "host" {
+		t.Fatalf("expected image build network host, got %q", mode)
+	}
+	if mode := newFeatureImageBuildOptions("features:latest", options).NetworkMode; mode != "host" {
+		t.Fatalf("expected features build network host, got %q", mode)
+	}
+}
+
+func TestBuildExtraHost_ReachesBuildOptions(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{
+		WithBuildExtraHost("mirror.internal", "10.0.0.5"),
+		WithBuildExtraHost("host.docker.internal", "host-gateway"),
+	})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	want := []string{"mirror.internal:10.0.0.5", "host.docker.internal:host-gateway"}
+	if got := newImageBuildOptions(&DevcontainerBuild{}, "Dockerfile", "tag:latest", options).ExtraHosts; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected image build hosts: %v", got)
+	}
+	if got := newFeatureImageBuildOptions("features:latest", options).ExtraHosts; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected features build hosts: %v", got)
+	}
+	if _, err := applyStartOptions([]StartOption{WithBuildExtraHost("mirror.internal", "not-an-ip")}); err == nil {
+		t.Fatal("expected invalid address error")
+	}
+	if _, err := applyStartOptions([]StartOption{WithBuildExtraHost("", "10.0.0.5")}); err == nil {
+		t.Fatal("expected invalid host error")
+	}
+}
+
+func TestStartDevcontainer_InvalidOptionAbortsBeforeWork(t *testing.T) {
+	missingConfig := filepath.Join(t.TempDir(), "missing", "devcontainer.json")
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(missingConfig), WithMountSpec("type=bind,source=/tmp"))
+	if err == nil {
+		t.Fatal("expected invalid option error")
+	}
+	if !strings.Contains(err.Error(), "invalid start option") {
+		t.Fatalf("expected option error before config loading, got %v", err)
+	}
+	if _, err := EffectiveConfig(WithConfigPath(missingConfig), WithMountSpec("target")); err == nil || !strings.Contains(err.Error(), "invalid start option") {
+		t.Fatalf("expected option error from EffectiveConfig, got %v", err)
+	}
+}
+
+func TestPreflightDocker_UnreachableHost(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath))
+	if err == nil || !strings.Contains(err.Error(), "docker daemon unreachable at tcp://127.0.0.1:1") {
+		t.Fatalf("expected unreachable daemon error, got %v", err)
+	}
+	_, err = BuildImageFromDevcontainer(context.Background(), configPath)
+	if err == nil || !strings.Contains(err.Error(), "docker daemon unreachable") {
+		t.Fatalf("expected unreachable daemon error from build, got %v", err)
+	}
+
+	options, err := applyStartOptions([]StartOption{WithoutPreflight()})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	if err := preflightDocker(context.Background(), options); err != nil {
+		t.Fatalf("expected preflight to be skipped, got %v", err)
+	}
+}
+
+func TestValidateBindSources(t *testing.T) {
+	existing := t.TempDir()
+	missing := filepath.Join(existing, "missing")
+	mounts, err := buildMounts(
+		"type=bind,source=${localWorkspaceFolder},target=/workspaces/app",
+		[]MountSpec{{Raw: "type=bind,source=${localWorkspaceFolder}/missing,target=/data"}},
+		[]Mount{{Type: "volume", Source: "cache", Target: "/cache"}},
+		map[string]string{"localWorkspaceFolder": existing},
+	)
+	if err != nil {
+		t.Fatalf("buildMounts: %v", err)
+	}
+	err = validateBindSources(mounts)
+	if err == nil {
+		t.Fatal("expected missing bind source error")
+	}
+	if !strings.Contains(err.Error(), missing) {
+		t.Fatalf("expected error to list %s, got %v", missing, err)
+	}
+	if strings.Contains(err.Error(), "cache") {
+		t.Fatalf("volume mounts should be skipped: %v", err)
+	}
+	if err := validateBindSources(mounts[:1]); err != nil {
+		t.Fatalf("existing bind source: %v", err)
+	}
+}
+
+func TestWorkspaceMountSpec_Override(t *testing.T) {
+	computed := "type=bind,source=/src/app,target=/workspaces/app"
+	if spec, err := workspaceMountSpec(defaultStartOptions(), &DevcontainerConfig{}, computed); err != nil || spec != computed {
+		t.Fatalf("expected computed spec, got %q, %v", spec, err)
+	}
+
+	options, err := applyStartOptions([]StartOption{WithWorkspaceMount("type=volume,source=${devcontainerId}-ws,target=/workspaces/app")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	spec, err := workspaceMountSpec(options, &DevcontainerConfig{}, computed)
+	if err != nil {
+		t.Fatalf("workspaceMountSpec: %v", err)
+	}
+	mounts, err := buildMounts(spec, nil, nil, map[string]string{"devcontainerId": "abc"})
+	if err != nil {
+		t.Fatalf("buildMounts: %v", err)
+	}
+	expected := []mount.Mount{{Type: mount.TypeVolume, Source: "abc-ws", Target: "/workspaces/app"}}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("unexpected mounts: %#v", mounts)
+	}
+}
+
+func TestWorkspaceMountSpec_Disabled(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithoutWorkspaceMount(), WithMountSpec("type=volume,source=cache,target=/cache")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	spec, err := workspaceMountSpec(options, &DevcontainerConfig{}, "type=bind,source=/src/app,target=/workspaces/app")
+	if err != nil {
+		t.Fatalf("workspaceMountSpec: %v", err)
+	}
+	mounts, err := buildMounts(spec, nil, options.ExtraMounts, nil)
+	if err != nil {
+		t.Fatalf("buildMounts: %v", err)
+	}
+	expected := []mount.Mount{{Type: mount.TypeVolume, Source: "cache", Target: "/cache"}}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("expected no workspace mount: %#v", mounts)
+	}
+
+	cfg := &DevcontainerConfig{WorkspaceMount: "type=bind,source=/src,target=/src"}
+	if _, err := workspaceMountSpec(options, cfg, cfg.WorkspaceMount); err == nil {
+		t.Fatal("expected configured workspaceMount to conflict")
+	}
+	WithWorkspaceMount("type=volume,source=ws,target=/ws")(&options)
+	if _, err := workspaceMountSpec(options, &DevcontainerConfig{}, ""); err == nil {
+		t.Fatal("expected WithWorkspaceMount to conflict")
+	}
+}
+
+func TestNewContainerConfig_Hostname(t *testing.T) {
+	cfg := &DevcontainerConfig{}
+	runArgs, err := parseRunArgs([]string{"--hostname=from-run-args"})
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	containerConfig := newContainerConfig(cfg, defaultStartOptions(), runArgs, "alpine:3.19", nil, nil, "/workspaces/app", nil)
+	if containerConfig.Hostname != "from-run-args" {
+		t.Fatalf("expected runArgs hostname, got %q", containerConfig.Hostname)
+	}
+
+	options, err := applyStartOptions([]StartOption{WithHostname("devbox")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	containerConfig = newContainerConfig(cfg, options, runArgs, "alpine:3.19", nil, nil, "/workspaces/app", nil)
+	if containerConfig.Hostname != "devbox" {
+		t.Fatalf("expected option hostname to win, got %q", containerConfig.Hostname)
+	}
+}
+
+func TestNewContainerConfig_Entrypoint(t *testing.T) {
+	cfg := &DevcontainerConfig{}
+	containerConfig := newContainerConfig(cfg, defaultStartOptions(), runArgOptions{}, "alpine:3.19", nil, nil, "/workspaces/app", nil)
+	if containerConfig.Entrypoint != nil {
+		t.Fatalf("expected the image entrypoint to be kept, got %#v", containerConfig.Entrypoint)
+	}
+	runArgs, err := parseRunArgs([]string{"--entrypoint", "/docker-init"})
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	containerConfig = newContainerConfig(cfg, defaultStartOptions(), runArgs, "alpine:3.19", nil, nil, "/workspaces/app", nil)
+	if !reflect.DeepEqual([]string(containerConfig.Entrypoint), []string{"/docker-init"}) {
+		t.Fatalf("expected runArgs entrypoint, got %#v", containerConfig.Entrypoint)
+	}
+	options, err := applyStartOptions([]StartOption{WithEntrypoint(nil)})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	containerConfig = newContainerConfig(cfg, options, runArgs, "alpine:3.19", nil, nil, "/workspaces/app", nil)
+	if !reflect.DeepEqual([]string(containerConfig.Entrypoint), []string{""}) {
+		t.Fatalf("expected an empty WithEntrypoint to clear the entrypoint, got %#v", containerConfig.Entrypoint)
+	}
+}
+
+func TestStartDevcontainer_EntrypointWithOverrideCommand(t *testing.T) {
+	var created container.Config
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", &created)
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithEntrypoint([]string{"/usr/bin/tini", "--"}))
+	if err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	if !reflect.DeepEqual([]string(created.Entrypoint), []string{"/usr/bin/tini", "--"}) {
+		t.Fatalf("unexpected entrypoint: %#v", created.Entrypoint)
+	}
+	if len(created.Cmd) == 0 || created.Cmd[0] != "/bin/sh" {
+		t.Fatalf("expected the keep-alive command as entrypoint arguments, got %#v", created.Cmd)
+	}
+}
+
+func TestNewContainerConfig_StopSignal(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithStopSignal("int"), WithStopTimeout(45 * time.Second)})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	containerConfig := newContainerConfig(&DevcontainerConfig{}, options, runArgOptions{}, "alpine:3.19", nil, nil, "/workspaces/app", nil)
+	if containerConfig.StopSignal != "SIGINT" {
+		t.Fatalf("expected SIGINT to be recorded, got %q", containerConfig.StopSignal)
+	}
+	if containerConfig.StopTimeout == nil || *containerConfig.StopTimeout != 45 {
+		t.Fatalf("expected stop timeout 45, got %v", containerConfig.StopTimeout)
+	}
+
+	if _, err := applyStartOptions([]StartOption{WithStopSignal("SIGNOPE")}); err == nil {
+		t.Fatal("expected invalid stop signal to be rejected")
+	}
+}
+
+func TestStartDevcontainer_MacAddressReachesCreate(t *testing.T) {
+	var created struct {
+		NetworkingConfig network.NetworkingConfig
+		HostConfig       container.HostConfig
+	}
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", &created)
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	id, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithNetwork("testnet"), WithRunArg("--mac-address=02:42:ac:11:00:02"), WithRunArg("--ip=172.20.0.5"))
+	if err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	if id != "container-123" {
+		t.Fatalf("unexpected id: %s", id)
+	}
+	endpoint := created.NetworkingConfig.EndpointsConfig["testnet"]
+	if endpoint == nil || endpoint.MacAddress != "02:42:ac:11:00:02" {
+		t.Fatalf("expected MAC address on testnet endpoint: %#v", created.NetworkingConfig)
+	}
+	if endpoint.IPAMConfig == nil || endpoint.IPAMConfig.IPv4Address != "172.20.0.5" {
+		t.Fatalf("expected IPv4 address on testnet endpoint: %#v", endpoint)
+	}
+	if created.HostConfig.NetworkMode != "testnet" {
+		t.Fatalf("unexpected network mode: %s", created.HostConfig.NetworkMode)
+	}
+}
+
+func TestStartDevcontainerResult_DescribesContainer(t *testing.T) {
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","Name":"/app-devcontainer","NetworkSettings":{"Ports":{
+		"8080/tcp":[{"HostIp":"0.0.0.0","HostPort":"49153"}],
+		"443/tcp":[{"HostIp":"127.0.0.1","HostPort":"8443"}],
+		"9000/tcp":null}}}`)
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	result, err := StartDevcontainerResult(context.Background(), WithConfigPath(configPath), WithExtraPublish("8080"), WithExtraPublish("8443:443"))
+	if err != nil {
+		t.Fatalf("StartDevcontainerResult: %v", err)
+	}
+	want := &StartResult{
+		ContainerID:   "container-123",
+		ContainerName: "app-devcontainer",
+		BaseImage:     "alpine:3.19",
+		Ports: []PublishedPort{
+			{ContainerPort: "443/tcp", HostIP: "127.0.0.1", HostPort: "8443"},
+			{ContainerPort: "8080/tcp", HostIP: "0.0.0.0", HostPort: "49153"},
+		},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("unexpected result:\n got %#v\nwant %#v", result, want)
+	}
+}
+
+func TestStartDevcontainer_InteractiveAttachesStdio(t *testing.T) {
+	var created container.Config
+	var calls []string
+	var stdin string
+	attached := make(chan struct{})
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", &created)
+	daemon.handle(http.MethodPost, "/containers/container-123/attach", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "attach")
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+		_, _ = conn.Write([]byte("hello from container\r\n"))
+		input, _ := io.ReadAll(buf)
+		stdin = string(input)
+		close(attached)
+	})
+	daemon.handle(http.MethodPost, "/containers/container-123/start", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "start")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	daemon.handle(http.MethodPost, "/containers/container-123/wait", func(w http.ResponseWriter, r *http.Request) {
+		<-attached
+		_, _ = w.Write([]byte(`{"StatusCode":0}`))
+	})
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	var out bytes.Buffer
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithInteractive(), WithStdio(strings.NewReader("exit\n"), &out, &out))
+	if err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"attach", "start"}) {
+		t.Fatalf("expected attach before start, got %v", calls)
+	}
+	if !created.OpenStdin || !created.AttachStdin || !created.Tty {
+		t.Fatalf("expected stdin to be opened on create: %#v", created)
+	}
+	if stdin != "exit\n" {
+		t.Fatalf("unexpected stdin forwarded: %q", stdin)
+	}
+	if out.String() != "hello from container\r\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestStartDevcontainer_LabelsNamedVolumes(t *testing.T) {
+	var created struct {
+		HostConfig container.HostConfig
+	}
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", &created)
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithMountSpec("type=volume,source=myvol,target=/data"), WithMountSpec("type=tmpfs,target=/tmp"))
+	if err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	var volumeMount *mount.Mount
+	for i, item := range created.HostConfig.Mounts {
+		switch item.Type {
+		case mount.TypeVolume:
+			volumeMount = &created.HostConfig.Mounts[i]
+		case mount.TypeTmpfs:
+			if item.VolumeOptions != nil {
+				t.Fatalf("expected tmpfs mount to stay unlabeled: %#v", item)
+			}
+		}
+	}
+	if volumeMount == nil || volumeMount.VolumeOptions == nil || volumeMount.VolumeOptions.Labels["devcontainer.config_path"] != configPath {
+		t.Fatalf("expected named volume to carry the config path label: %#v", created.HostConfig.Mounts)
+	}
+}
+
+func TestRemoveDevcontainer_RemovesLabeledVolumes(t *testing.T) {
+	var filter string
+	var removed []string
+	daemon := newFakeDaemon(t)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","Config":{"Labels":{"devcontainer.config_path":"/missing/devcontainer.json"}},"Mounts":[{"Type":"volume","Name":"myvol","Destination":"/data"}]}`)
+	daemon.respond(http.MethodDelete, "/containers/container-123", http.StatusNoContent, "")
+	daemon.handle(http.MethodGet, "/volumes", func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filters")
+		_, _ = w.Write([]byte(`{"Volumes":[{"Name":"myvol"},{"Name":"siblingvol"}]}`))
+	})
+	for _, name := range []string{"myvol", "siblingvol"} {
+		daemon.handle(http.MethodDelete, "/volumes/"+name, func(w http.ResponseWriter, r *http.Request) {
+			removed = append(removed, name)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	daemon.useAsDockerHost()
+
+	if err := RemoveDevcontainer(context.Background(), "container-123", WithRemoveVolumes()); err != nil {
+		t.Fatalf("RemoveDevcontainer: %v", err)
+	}
+	if !strings.Contains(filter, "devcontainer.config_path=/missing/devcontainer.json") {
+		t.Fatalf("expected volumes to be listed by config path label, got %s", filter)
+	}
+	if !reflect.DeepEqual(removed, []string{"myvol"}) {
+		t.Fatalf("unexpected removed volumes: %v", removed)
+	}
+}
+
+func TestStartDevcontainer_RemovesExtractedFeatureDirs(t *testing.T) {
+	featureURL := serveFeatureTarball(t, map[string]string{
+		"devcontainer-feature.json": `{"id":"remote","version":"1.0.0","name":"Remote"}`,
+		"install.sh":                "#!/bin/sh\n",
+	})
+	built := false
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.respond(http.MethodGet, "/images/alpine:3.19/json", http.StatusOK, `{"Id":"sha256:base","Config":{}}`)
+	daemon.handle(http.MethodPost, "/build", func(w http.ResponseWriter, r *http.Request) {
+		built = true
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte(`{"stream":"Successfully built\n"}`))
+	})
+	daemon.useAsDockerHost()
+
+	root := t.TempDir()
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	config := `{"image":"alpine:3.19","features":{"` + featureURL + `":{}}}`
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	tempDir := t.TempDir()
+	result, err := StartDevcontainerResult(context.Background(), WithConfigPath(configPath), WithTempDir(tempDir))
+	if err != nil {
+		t.Fatalf("StartDevcontainerResult: %v", err)
+	}
+	if !built || result.FeatureImage == "" {
+		t.Fatalf("expected the features image to be built: %#v", result)
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected extracted feature dirs to be removed, found %v", entries)
+	}
+}
+
+func TestAttachStdio_RequiresInteractive(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithDetachValue(false), WithTTY()})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	if options.attachStdio() {
+		t.Fatal("expected a non-detached TTY start to leave stdio alone")
+	}
+	options, err = applyStartOptions([]StartOption{WithInteractive()})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	if !options.attachStdio() {
+		t.Fatal("expected WithInteractive to attach stdio")
+	}
+}
+
+func TestAttachSession_CloseStopsStdinCopy(t *testing.T) {
+	local, remote := net.Pipe()
+	t.Cleanup(func() { _ = remote.Close() })
+	stdin, stdinWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = stdin.Close()
+		_ = stdinWriter.Close()
+	})
+	session := &attachSession{
+		resp:    types.NewHijackedResponse(local, ""),
+		stdin:   stdin,
+		output:  make(chan struct{}),
+		restore: func() {},
+		stop:    make(chan struct{}),
+		input:   make(chan struct{}),
+	}
+	go session.copyStdin()
+
+	if _, err := stdinWriter.Write([]byte("ls\n")); err != nil {
+		t.Fatalf("write stdin: %v", err)
+	}
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(remote, buf); err != nil || string(buf) != "ls\n" {
+		t.Fatalf("expected stdin forwarded, got %q (%v)", buf, err)
+	}
+
+	session.Close()
+	select {
+	case <-session.input:
+	case <-time.After(time.Second):
+		t.Fatal("expected the stdin copy to stop on close")
+	}
+	if _, err := stdinWriter.Write([]byte("x")); err != nil {
+		t.Fatalf("write stdin: %v", err)
+	}
+	if _, err := stdin.Read(buf); err != nil {
+		t.Fatalf("expected stdin to stay readable after close: %v", err)
+	}
+}
+
+func TestNewHostConfig_ReadOnlyRootfsWithTmpfs(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithReadOnlyRootfs(), WithMountSpec("type=tmpfs,target=/tmp")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	mounts, err := buildMounts("type=bind,source=/src,target=/workspaces/app", nil, options.ExtraMounts, nil)
+	if err != nil {
+		t.Fatalf("buildMounts: %v", err)
+	}
+	hostConfig, err := newHostConfig(&DevcontainerConfig{}, options, runArgOptions{}, mounts, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if !hostConfig.ReadonlyRootfs {
+		t.Fatal("expected read-only rootfs")
+	}
+	if len(hostConfig.Mounts) != 2 || hostConfig.Mounts[1].Type != mount.TypeTmpfs || hostConfig.Mounts[1].Target != "/tmp" {
+		t.Fatalf("expected tmpfs mount alongside read-only rootfs: %#v", hostConfig.Mounts)
+	}
+
+	runArgs, err := parseRunArgs([]string{"--read-only"})
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	hostConfig, err = newHostConfig(&DevcontainerConfig{}, defaultStartOptions(), runArgs, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if !hostConfig.ReadonlyRootfs {
+		t.Fatal("expected --read-only runArg to set read-only rootfs")
+	}
+}
+
+func TestNewHostConfig_MemoryReservationAndCPUShares(t *testing.T) {
+	runArgs, err := parseRunArgs([]string{"--memory-reservation", "256m", "--cpu-shares=256"})
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	hostConfig, err := newHostConfig(&DevcontainerConfig{}, defaultStartOptions(), runArgs, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if hostConfig.MemoryReservation != 256*1024*1024 || hostConfig.CPUShares != 256 {
+		t.Fatalf("expected runArg limits, got reservation=%d shares=%d", hostConfig.MemoryReservation, hostConfig.CPUShares)
+	}
+
+	options, err := applyStartOptions([]StartOption{WithMemoryReservation("1g"), WithCPUShares(2048)})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	hostConfig, err = newHostConfig(&DevcontainerConfig{}, options, runArgs, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if hostConfig.MemoryReservation != 1024*1024*1024 || hostConfig.CPUShares != 2048 {
+		t.Fatalf("expected options to override runArgs, got reservation=%d shares=%d", hostConfig.MemoryReservation, hostConfig.CPUShares)
+	}
+
+	if _, err := applyStartOptions([]StartOption{WithMemoryReservation("lots")}); err == nil {
+		t.Fatal("expected invalid memory reservation to fail")
+	}
+	if _, err := applyStartOptions([]StartOption{WithCPUShares(-1)}); err == nil {
+		t.Fatal("expected negative cpu shares to fail")
+	}
+}
+
+func TestNewHostConfig_PidsLimit(t *testing.T) {
+	hostConfig, err := newHostConfig(&DevcontainerConfig{}, defaultStartOptions(), runArgOptions{}, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if hostConfig.PidsLimit != nil {
+		t.Fatalf("expected no pids limit by default, got %d", *hostConfig.PidsLimit)
+	}
+	runArgs, err := parseRunArgs([]string{"--pids-limit=200"})
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	hostConfig, err = newHostConfig(&DevcontainerConfig{}, defaultStartOptions(), runArgs, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if hostConfig.PidsLimit == nil || *hostConfig.PidsLimit != 200 {
+		t.Fatalf("expected runArg pids limit 200, got %v", hostConfig.PidsLimit)
+	}
+	options, err := applyStartOptions([]StartOption{WithPidsLimit(64)})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	hostConfig, err = newHostConfig(&DevcontainerConfig{}, options, runArgs, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if hostConfig.PidsLimit == nil || *hostConfig.PidsLimit != 64 {
+		t.Fatalf("expected WithPidsLimit to override runArgs, got %v", hostConfig.PidsLimit)
+	}
+	if _, err := applyStartOptions([]StartOption{WithPidsLimit(0)}); err == nil {
+		t.Fatal("expected zero pids limit to fail")
+	}
+}
+
+func TestNewHostConfig_MergesCapDropWithCapAdd(t *testing.T) {
+	cfg := &DevcontainerConfig{CapAdd: []string{"SYS_PTRACE"}, CapDrop: []string{"NET_RAW"}}
+	features := aggregateFeatureConfig([]*ResolvedFeature{{
+		Metadata: FeatureMetadata{ID: "hardened", CapDrop: []string{"MKNOD", "NET_RAW"}},
+	}})
+	cfg.CapDrop = appendUnique(cfg.CapDrop, features.capDrop...)
+	runArgs, err := parseRunArgs([]string{"--cap-add=NET_ADMIN", "--cap-drop=ALL"})
+	if err != nil {
+		t.Fatalf("parseRunArgs: %v", err)
+	}
+	hostConfig, err := newHostConfig(cfg, defaultStartOptions(), runArgs, nil, nil)
+	if err != nil {
+		t.Fatalf("newHostConfig: %v", err)
+	}
+	if !reflect.DeepEqual([]string(hostConfig.CapAdd), []string{"SYS_PTRACE", "NET_ADMIN"}) {
+		t.Fatalf("unexpected CapAdd: %#v", hostConfig.CapAdd)
+	}
+	if !reflect.DeepEqual([]string(hostConfig.CapDrop), []string{"NET_RAW", "MKNOD", "ALL"}) {
+		t.Fatalf("unexpected CapDrop: %#v", hostConfig.CapDrop)
+	}
+}
+
+func TestNewHostConfig_NoNewPrivilegesOnce(t *testing.T) {
+	options, err := applyStartOptions([]StartOption{WithNoNewPrivileges()})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	tests := []struct {
+		name   string
+		cfg    *DevcontainerConfig
+		args   []string
+		expect []string
+	}{
+		{
+			name:   "option only",
+			cfg:    &DevcontainerConfig{SecurityOpt: []string{"seccomp=unconfined"}},
+			expect: []string{"seccomp=unconfined", "no-new-privileges:true"},
+		},
+		{
+			name:   "config already set",
+			cfg:    &DevcontainerConfig{SecurityOpt: []string{"no-new-privileges:true"}},
+			args:   []string{"--security-opt=no-new-privileges:true"},
+			expect: []string{"no-new-privileges:true"},
+		},
+		{
+			name:   "alternate spelling",
+			cfg:    &DevcontainerConfig{SecurityOpt: []string{"no-new-privileges"}},
+			expect: []string{"no-new-privileges"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runArgs, err := parseRunArgs(tt.args)
+			if err != nil {
+				t.Fatalf("parseRunArgs: %v", err)
+			}
+			hostConfig, err := newHostConfig(tt.cfg, options, runArgs, nil, nil)
+			if err != nil {
+				t.Fatalf("newHostConfig: %v", err)
+			}
+			if !reflect.DeepEqual(hostConfig.SecurityOpt, tt.expect) {
+				t.Fatalf("unexpected SecurityOpt: %#v", hostConfig.SecurityOpt)
+			}
+		})
+	}
+}
+
+func TestLoadSeccompProfiles(t *testing.T) {
+	configDir := testcasePath(t, "seccomp", ".devcontainer")
+	content, err := os.ReadFile(filepath.Join(configDir, "profile.json"))
+	if err != nil {
+		t.Fatalf("read profile: %v", err)
+	}
+	got, err := loadSeccompProfiles([]string{"seccomp=./profile.json", "seccomp=unconfined", "label=disable"}, configDir)
+	if err != nil {
+		t.Fatalf("loadSeccompProfiles: %v", err)
+	}
+	expected := []string{"seccomp=" + strings.TrimSpace(string(content)), "seccomp=unconfined", "label=disable"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected security opts: %#v", got)
+	}
+	if _, err := loadSeccompProfiles([]string{"seccomp=missing.json"}, configDir); err == nil {
+		t.Fatal("expected error for missing seccomp profile")
+	}
+}
+
+// newFakeDockerClient returns a client talking to handler instead of a Docker daemon.
+func newFakeDockerClient(t *testing.T, handler http.HandlerFunc) *client.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")), client.WithVersion("1.49"))
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cli.Close()
+	})
+	return cli
+}
+
+// fakeDaemon is a fake Docker API built on newFakeDockerClient that dispatches requests to registered routes;
+// requests no route matches get 404, which the client reports as a missing object.
+type fakeDaemon struct {
+	t      *testing.T     // t owns the daemon's cleanup.
+	cli    *client.Client // cli talks to the daemon.
+	mu     sync.Mutex     // mu guards routes.
+	routes []fakeRoute    // routes are tried newest first.
+}
+
+// fakeRoute answers requests whose path ends with suffix.
+type fakeRoute struct {
+	method  string           // method limits the route to one HTTP method; "" matches any.
+	suffix  string           // suffix is matched against the end of the request path.
+	handler http.HandlerFunc // handler writes the response.
+}
+
+// newFakeDaemon starts a fake daemon that answers _ping; tests register the other routes they need.
+func newFakeDaemon(t *testing.T) *fakeDaemon {
+	t.Helper()
+	d := &fakeDaemon{t: t}
+	d.cli = newFakeDockerClient(t, d.serveHTTP)
+	d.handle("", "/_ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.49")
+	})
+	return d
+}
+
+// handle registers handler for method and path suffix; a later route for the same request wins, so tests can
+// replace the defaults of handleStart.
+func (d *fakeDaemon) handle(method, suffix string, handler http.HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.routes = append(d.routes, fakeRoute{method: method, suffix: suffix, handler: handler})
+}
+
+// respond registers a route that replies with status and body.
+func (d *fakeDaemon) respond(method, suffix string, status int, body string) {
+	d.handle(method, suffix, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+}
+
+// handleStart registers the routes a single-container start needs and creates containers as containerID, whose
+// filesystem has every path asked about; created, when non-nil, receives the decoded create request body.
+func (d *fakeDaemon) handleStart(containerID string, created any) {
+	d.respond(http.MethodPost, "/images/create", http.StatusOK, "")
+	d.handle(http.MethodPost, "/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		if created != nil {
+			if err := json.NewDecoder(r.Body).Decode(created); err != nil {
+				d.t.Errorf("decode create body: %v", err)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Id":"` + containerID + `"}`))
+	})
+	d.handle(http.MethodHead, "/containers/"+containerID+"/archive", func(w http.ResponseWriter, r *http.Request) {
+		stat, _ := json.Marshal(container.PathStat{Name: path.Base(r.URL.Query().Get("path")), Mode: 0o755})
+		w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString(stat))
+	})
+	d.respond(http.MethodPost, "/containers/"+containerID+"/start", http.StatusNoContent, "")
+	d.respond(http.MethodGet, "/containers/"+containerID+"/json", http.StatusOK, `{"Id":"`+containerID+`","Name":"/devcontainer"}`)
+}
+
+// useAsDockerHost points DOCKER_HOST at the daemon for code that opens its own client.
+func (d *fakeDaemon) useAsDockerHost() {
+	d.t.Setenv("DOCKER_HOST", d.cli.DaemonHost())
+}
+
+func (d *fakeDaemon) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	var handler http.HandlerFunc
+	for i := len(d.routes) - 1; i >= 0; i-- {
+		route := d.routes[i]
+		if (route.method == "" || route.method == r.Method) && strings.HasSuffix(r.URL.Path, route.suffix) {
+			handler = route.handler
+			break
+		}
+	}
+	d.mu.Unlock()
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler(w, r)
+}
+
+func TestDescribeContainer_RemovedContainer(t *testing.T) {
+	cli := newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"No such container: gone"}`, http.StatusNotFound)
+	})
+	result := &StartResult{ContainerID: "gone"}
+	if err := describeContainer(context.Background(), cli, result); err != nil {
+		t.Fatalf("describeContainer: %v", err)
+	}
+	if result.ContainerName != "" || len(result.Ports) != 0 {
+		t.Fatalf("expected no runtime details, got %#v", result)
+	}
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	original := healthPollInterval
+	healthPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { healthPollInterval = original })
+
+	tests := []struct {
+		name     string
+		statuses []container.HealthStatus
+		wantErr  string
+	}{
+		{name: "becomes healthy", statuses: []container.HealthStatus{container.Starting, container.Starting, container.Healthy}},
+		{name: "no healthcheck", statuses: nil},
+		{name: "unhealthy", statuses: []container.HealthStatus{container.Starting, container.Unhealthy}, wantErr: "unhealthy"},
+		{name: "timeout", statuses: []container.HealthStatus{container.Starting}, wantErr: "did not become healthy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			cli := newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+				state := &container.State{Running: true}
+				if len(tt.statuses) > 0 {
+					index := min(calls, len(tt.statuses)-1)
+					state.Health = &container.Health{Status: tt.statuses[index]}
+				}
+				calls++
+				_ = json.NewEncoder(w).Encode(container.InspectResponse{
+					ContainerJSONBase: &container.ContainerJSONBase{ID: "abc", State: state},
+				})
+			})
+			err := waitForHealthy(context.Background(), cli, "abc", 200*time.Millisecond)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("waitForHealthy: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestContainerLabels_ToolingLabels(t *testing.T) {
+	configPath := "/work/app/.devcontainer/devcontainer.json"
+	labels, err := containerLabels(defaultStartOptions(), map[string]string{"team": "dev"}, configPath, "/work/app")
+	if err != nil {
+		t.Fatalf("containerLabels: %v", err)
+	}
+	if _, ok := labels["devcontainer.local_folder"]; ok {
+		t.Fatalf("tooling labels should be opt-in: %#v", labels)
+	}
+
+	options, err := applyStartOptions([]StartOption{WithToolingLabels(), WithLabel("owner", "me")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	labels, err = containerLabels(options, map[string]string{"team": "dev"}, configPath, "/work/app")
+	if err != nil {
+		t.Fatalf("containerLabels: %v", err)
+	}
+	expected := map[string]string{
+		"owner":                     "me",
+		"team":                      "dev",
+		"devcontainer.config_path":  configPath,
+		"devcontainer.local_folder": "/work/app",
+		"devcontainer.config_file":  configPath,
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("unexpected labels: %#v", labels)
+	}
+}
+
+func TestContainerLabels_GitCloneLabelReserved(t *testing.T) {
+	options := defaultStartOptions()
+	options.GitClone = "/tmp/godev-git-123"
+	labels, err := containerLabels(options, nil, "/tmp/godev-git-123/.devcontainer/devcontainer.json", "/tmp/godev-git-123")
+	if err != nil {
+		t.Fatalf("containerLabels: %v", err)
+	}
+	if labels[gitCloneLabel] != "/tmp/godev-git-123" {
+		t.Fatalf("expected clone label, got %#v", labels)
+	}
+
+	options.Labels = map[string]string{gitCloneLabel: "/tmp/godev-git-other"}
+	if _, err := containerLabels(options, nil, "/work/.devcontainer/devcontainer.json", "/work"); err == nil {
+		t.Fatal("expected a user label to be rejected")
+	}
+	options.Labels = nil
+	if _, err := containerLabels(options, map[string]string{gitCloneLabel: "/tmp/godev-git-other"}, "/work/.devcontainer/devcontainer.json", "/work"); err == nil {
+		t.Fatal("expected a runArg label to be rejected")
+	}
+}
+
+func TestContainerLabels_LabelFileBeneathExplicitLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.labels")
+	content := "# injected by CI\nCOMMIT_SHA=abc123\nteam=ci\nBUILD_URL=\"https://ci.example/1\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write label file: %v", err)
+	}
+
+	options, err := applyStartOptions([]StartOption{WithLabel("team", "dev"), WithLabelFile(path)})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	labels, err := containerLabels(options, nil, "/work/.devcontainer/devcontainer.json", "/work")
+	if err != nil {
+		t.Fatalf("containerLabels: %v", err)
+	}
+	expected := map[string]string{
+		"COMMIT_SHA":               "abc123",
+		"BUILD_URL":                "https://ci.example/1",
+		"team":                     "dev",
+		"devcontainer.config_path": "/work/.devcontainer/devcontainer.json",
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("unexpected labels: %#v", labels)
+	}
+
+	if _, err := applyStartOptions([]StartOption{WithLabelFile(filepath.Join(t.TempDir(), "missing"))}); err == nil {
+		t.Fatal("expected missing label file to fail")
+	}
+}
+
+func TestFindContainerID(t *testing.T) {
+	listContainers := func(ids ...string) (http.HandlerFunc, *string) {
+		var filter string
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/containers/json") {
+				http.NotFound(w, r)
+				return
+			}
+			filter = r.URL.Query().Get("filters")
+			summaries := make([]container.Summary, 0, len(ids))
+			for _, id := range ids {
+				summaries = append(summaries, container.Summary{ID: id})
+			}
+			_ = json.NewEncoder(w).Encode(summaries)
+		}, &filter
+	}
+
+	t.Run("single", func(t *testing.T) {
+		configPath := testcasePath(t, "docker-engine-image", ".devcontainer", "devcontainer.json")
+		handler, filter := listContainers("single-id")
+		id, err := findContainerID(context.Background(), newFakeDockerClient(t, handler), configPath)
+		if err != nil {
+			t.Fatalf("findContainerID: %v", err)
+		}
+		if id != "single-id" {
+			t.Fatalf("unexpected container ID: %s", id)
+		}
+		if !strings.Contains(*filter, "devcontainer.config_path="+configPath) {
+			t.Fatalf("expected config path label filter, got %s", *filter)
+		}
+	})
+
+	t.Run("several prefers running then newest", func(t *testing.T) {
+		configPath := testcasePath(t, "docker-engine-image", ".devcontainer", "devcontainer.json")
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode([]container.Summary{
+				{ID: "exited-newest", State: container.StateExited, Created: 300},
+				{ID: "running-old", State: container.StateRunning, Created: 100},
+				{ID: "running-new", State: container.StateRunning, Created: 200},
+			})
+		}
+		id, err := findContainerID(context.Background(), newFakeDockerClient(t, handler), configPath)
+		if err != nil {
+			t.Fatalf("findContainerID: %v", err)
+		}
+		if id != "running-new" {
+			t.Fatalf("expected the newest running container, got %s", id)
+		}
+	})
+
+	t.Run("compose", func(t *testing.T) {
+		installDockerStub(t, "case \"$*\" in *\"ps -q app\"*) echo compose-id ;; *) exit 1 ;; esac\n")
+		configPath := testcasePath(t, "compose", "defaults", ".devcontainer", "devcontainer.json")
+		handler, _ := listContainers()
+		id, err := findContainerID(context.Background(), newFakeDockerClient(t, handler), configPath)
+		if err != nil {
+			t.Fatalf("findContainerID: %v", err)
+		}
+		if id != "compose-id" {
+			t.Fatalf("unexpected container ID: %s", id)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		configPath := testcasePath(t, "docker-engine-image", ".devcontainer", "devcontainer.json")
+		handler, _ := listContainers()
+		_, err := findContainerID(context.Background(), newFakeDockerClient(t, handler), configPath)
+		if err == nil || !strings.Contains(err.Error(), "no running devcontainer found") {
+			t.Fatalf("expected not found error, got %v", err)
+		}
+	})
+}
+
+func TestStartDevcontainer_KeepAliveCommand(t *testing.T) {
+	start := func(imageConfig string, opts ...StartOption) []string {
+		var created container.Config
+		daemon := newFakeDaemon(t)
+		daemon.handleStart("container-123", &created)
+		daemon.respond(http.MethodGet, "/images/alpine:3.19/json", http.StatusOK, `{"Id":"sha256:abc","Config":`+imageConfig+`}`)
+		daemon.useAsDockerHost()
+
+		configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+		writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+		if _, err := StartDevcontainer(context.Background(), append([]StartOption{WithConfigPath(configPath)}, opts...)...); err != nil {
+			t.Fatalf("StartDevcontainer: %v", err)
+		}
+		return created.Cmd
+	}
+
+	if got := start(`{}`, WithKeepAliveCommand([]string{"/pause"})); !reflect.DeepEqual(got, []string{"/pause"}) {
+		t.Fatalf("expected custom keep-alive command, got %#v", got)
+	}
+	if got := start(`{"Cmd":["/bin/bash"]}`); !reflect.DeepEqual(got, keepAliveCommand("/bin/bash")) {
+		t.Fatalf("expected bash keep-alive loop, got %#v", got)
+	}
+	if got := start(`{}`); !reflect.DeepEqual(got, keepAliveCommand("/bin/sh")) {
+		t.Fatalf("expected default keep-alive loop, got %#v", got)
+	}
+
+	started := false
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.respond(http.MethodHead, "/containers/container-123/archive", http.StatusNotFound, "")
+	daemon.handle(http.MethodPost, "/containers/container-123/start", func(w http.ResponseWriter, r *http.Request) {
+		started = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	daemon.useAsDockerHost()
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath)); err == nil || !strings.Contains(err.Error(), "no /bin/sh to run the keep-alive loop") {
+		t.Fatalf("expected a missing shell to fail the start, got %v", err)
+	}
+	if started {
+		t.Fatal("expected a container without a shell not to be started")
+	}
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithKeepAliveCommand([]string{"/pause"})); err != nil || !started {
+		t.Fatalf("expected a custom keep-alive command to skip the shell check, got %v", err)
+	}
+	if _, err := applyStartOptions([]StartOption{WithKeepAliveCommand(nil)}); err == nil {
+		t.Fatal("expected empty keep-alive command to be rejected")
+	}
+}
+
+func TestStartDevcontainer_EnvPassthrough(t *testing.T) {
+	var created container.Config
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", &created)
+	daemon.useAsDockerHost()
+	t.Setenv("GODEV_TEST_PASS", "from-${host}")
+	t.Setenv("GODEV_TEST_EXPLICIT", "from-host")
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath),
+		WithEnvPassthrough("GODEV_TEST_PASS"),
+		WithEnvPassthrough("GODEV_TEST_EXPLICIT"), WithEnv("GODEV_TEST_EXPLICIT", "explicit"),
+		WithEnvPassthrough("GODEV_TEST_UNSET_ON_HOST"))
+	if err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	want := []string{"GODEV_TEST_EXPLICIT=explicit", "GODEV_TEST_PASS=from-${host}"}
+	if !reflect.DeepEqual(created.Env, want) {
+		t.Fatalf("unexpected container env: %#v", created.Env)
+	}
+}
+
+func TestStartDevcontainerResult_EphemeralAppPorts(t *testing.T) {
+	var created struct {
+		HostConfig container.HostConfig
+	}
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", &created)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","Name":"/devcontainer","NetworkSettings":{"Ports":{"3000/tcp":[{"HostIp":"0.0.0.0","HostPort":"49153"}],"8080/tcp":[{"HostIp":"0.0.0.0","HostPort":"8081"}]}}}`)
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"image":"alpine:3.19","appPort":[3000,"8081:8080"]}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	result, err := StartDevcontainerResult(context.Background(), WithConfigPath(configPath), WithEphemeralAppPorts())
+	if err != nil {
+		t.Fatalf("StartDevcontainerResult: %v", err)
+	}
+	bindings := created.HostConfig.PortBindings
+	if got := bindings["3000/tcp"]; len(got) != 1 || got[0].HostPort != "" {
+		t.Fatalf("expected an ephemeral host port for 3000, got %#v", got)
+	}
+	if got := bindings["8080/tcp"]; len(got) != 1 || got[0].HostPort != "8081" {
+		t.Fatalf("expected explicit host port 8081 for 8080, got %#v", got)
+	}
+	want := []PublishedPort{
+		{ContainerPort: "3000/tcp", HostIP: "0.0.0.0", HostPort: "49153"},
+		{ContainerPort: "8080/tcp", HostIP: "0.0.0.0", HostPort: "8081"},
+	}
+	if !reflect.DeepEqual(result.Ports, want) {
+		t.Fatalf("unexpected published ports: %#v", result.Ports)
+	}
+}
+
+func TestRemoveAndStopDevcontainer_MissingContainer(t *testing.T) {
+	daemon := newFakeDaemon(t)
+	for _, suffix := range []string{"/containers/gone-123", "/containers/gone-123/json", "/containers/gone-123/stop"} {
+		daemon.handle("", suffix, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"No such container: gone-123"}`))
+		})
+	}
+	daemon.useAsDockerHost()
+
+	if err := RemoveDevcontainer(context.Background(), "gone-123"); err != nil {
+		t.Fatalf("expected removing a missing container to succeed, got %v", err)
+	}
+	if err := StopDevcontainer(context.Background(), "gone-123", 0); err != nil {
+		t.Fatalf("expected stopping a missing container to succeed, got %v", err)
+	}
+	if err := RemoveDevcontainer(context.Background(), "gone-123", WithRequireExisting()); err == nil || !strings.Contains(err.Error(), "No such container") {
+		t.Fatalf("expected not-found error with WithRequireExisting, got %v", err)
+	}
+}
+
+func TestStartDevcontainer_TagsAndPushesImage(t *testing.T) {
+	dockerConfig := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte("ci:secret"))
+	if err := os.WriteFile(filepath.Join(dockerConfig, "config.json"), []byte(`{"auths":{"registry.example.com":{"auth":"`+auth+`"}}}`), 0o600); err != nil {
+		t.Fatalf("write docker config: %v", err)
+	}
+	t.Setenv("DOCKER_CONFIG", dockerConfig)
+
+	var tagQuery, pushPath string
+	var pushAuth registry.AuthConfig
+	pushResponse := `{"status":"Pushed"}`
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.handle(http.MethodPost, "/images/alpine:3.19/tag", func(w http.ResponseWriter, r *http.Request) {
+		tagQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusCreated)
+	})
+	daemon.handle(http.MethodPost, "/push", func(w http.ResponseWriter, r *http.Request) {
+		pushPath = r.URL.Path + "?" + r.URL.RawQuery
+		data, err := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+		if err != nil {
+			t.Errorf("decode registry auth: %v", err)
+		}
+		_ = json.Unmarshal(data, &pushAuth)
+		_, _ = w.Write([]byte(pushResponse))
+	})
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithImageTag("registry.example.com/team/dev:ci")); err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	if tagQuery != "repo=registry.example.com%2Fteam%2Fdev&tag=ci" {
+		t.Fatalf("unexpected tag request: %q", tagQuery)
+	}
+	if pushPath != "" {
+		t.Fatalf("expected no push without WithPushImage, got %s", pushPath)
+	}
+
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithImageTag("registry.example.com/team/dev:ci"), WithPushImage()); err != nil {
+		t.Fatalf("StartDevcontainer with push: %v", err)
+	}
+	if !strings.HasSuffix(pushPath, "/images/registry.example.com/team/dev/push?tag=ci") {
+		t.Fatalf("unexpected push request: %s", pushPath)
+	}
+	if pushAuth.Username != "ci" || pushAuth.Password != "secret" || pushAuth.ServerAddress != "registry.example.com" {
+		t.Fatalf("unexpected push auth: %#v", pushAuth)
+	}
+
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithImageTag("app-dev"), WithPushImage()); err != nil {
+		t.Fatalf("StartDevcontainer with untagged push: %v", err)
+	}
+	if tagQuery != "repo=docker.io%2Flibrary%2Fapp-dev&tag=latest" {
+		t.Fatalf("expected an untagged reference to be tagged latest, got %q", tagQuery)
+	}
+	if !strings.HasSuffix(pushPath, "/images/docker.io/library/app-dev/push?tag=latest") {
+		t.Fatalf("expected only the latest tag to be pushed, got %s", pushPath)
+	}
+
+	pushResponse = `{"status":"Preparing"}` + "\n" + `{"errorDetail":{"message":"denied"},"error":"denied: requested access to the resource is denied"}`
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithImageTag("registry.example.com/team/dev:ci"), WithPushImage()); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Fatalf("expected push error, got %v", err)
+	}
+	if _, err := applyStartOptions([]StartOption{WithPushImage()}); err == nil {
+		t.Fatal("expected WithPushImage without WithImageTag to fail")
+	}
+}
+
+func TestStartDevcontainer_PullsWithRegistryAuth(t *testing.T) {
+	dockerConfig := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte("reader:from-config"))
+	if err := os.WriteFile(filepath.Join(dockerConfig, "config.json"), []byte(`{"auths":{"registry.example.com":{"auth":"`+auth+`"}}}`), 0o600); err != nil {
+		t.Fatalf("write docker config: %v", err)
+	}
+	t.Setenv("DOCKER_CONFIG", dockerConfig)
+
+	var pullAuth registry.AuthConfig
+	var pullHeader string
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.handle(http.MethodPost, "/images/create", func(w http.ResponseWriter, r *http.Request) {
+		pullHeader = r.Header.Get("X-Registry-Auth")
+		pullAuth = registry.AuthConfig{}
+		if pullHeader != "" {
+			data, err := base64.URLEncoding.DecodeString(pullHeader)
+			if err != nil {
+				t.Errorf("decode registry auth: %v", err)
+			}
+			_ = json.Unmarshal(data, &pullAuth)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"image":"registry.example.com/team/base:1"}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath)); err != nil {
+		t.Fatalf("StartDevcontainer: %v", err)
+	}
+	if pullAuth.Username != "reader" || pullAuth.Password != "from-config" || pullAuth.ServerAddress != "registry.example.com" {
+		t.Fatalf("expected docker config credentials on the pull, got %#v", pullAuth)
+	}
+
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithRegistryAuth("registry.example.com", "ci", "token")); err != nil {
+		t.Fatalf("StartDevcontainer with registry auth: %v", err)
+	}
+	if pullAuth.Username != "ci" || pullAuth.Password != "token" {
+		t.Fatalf("expected WithRegistryAuth to take precedence, got %#v", pullAuth)
+	}
+
+	publicPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, publicPath, "config", "basic", "devcontainer.json")
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(publicPath), WithRegistryAuth("registry.example.com", "ci", "token")); err != nil {
+		t.Fatalf("StartDevcontainer public image: %v", err)
+	}
+	if pullHeader != "" {
+		t.Fatalf("expected no credentials for another registry, got %q", pullHeader)
+	}
+}
+
+func TestStartDevcontainer_LifecycleEnvIncludesImageEnv(t *testing.T) {
+	var execConfig container.ExecOptions
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.respond(http.MethodGet, "/images/sha256:tool/json", http.StatusOK, `{"Id":"sha256:tool","Config":{"Env":["PATH=/opt/tool/bin:/usr/bin","FOO=image","TOOL_HOME=/opt/tool"]}}`)
+	daemon.respond(http.MethodGet, "/containers/container-123/json", http.StatusOK, `{"Id":"container-123","Name":"/devcontainer","Image":"sha256:tool"}`)
+	daemon.handle(http.MethodPost, "/containers/container-123/exec", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&execConfig); err != nil {
+			t.Errorf("decode exec body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"message":"stop after exec create"}`))
+	})
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	config := `{"image":"alpine:3.19","containerEnv":{"FOO":"config"},"remoteEnv":{"PATH":"${containerEnv:PATH}:/extra"},"postCreateCommand":"tool --version"}`
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(configPath)); err == nil || !strings.Contains(err.Error(), "stop after exec create") {
+		t.Fatalf("expected exec create error, got %v", err)
+	}
+	env := make(map[string]string)
+	for _, item := range execConfig.Env {
+		key, value, _ := strings.Cut(item, "=")
+		env[key] = value
+	}
+	if env["PATH"] != "/opt/tool/bin:/usr/bin:/extra" {
+		t.Fatalf("expected remoteEnv PATH built on the image PATH, got %q", env["PATH"])
+	}
+	if env["FOO"] != "config" || env["TOOL_HOME"] != "/opt/tool" {
+		t.Fatalf("expected config to override image env, got %#v", env)
+	}
+}
+
+func TestStartDevcontainer_WorkspaceReadyCheck(t *testing.T) {
+	previous := workspacePollInterval
+	workspacePollInterval = time.Millisecond
+	t.Cleanup(func() { workspacePollInterval = previous })
+
+	var execCmds [][]string
+	checks := 0
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.handle(http.MethodPost, "/containers/container-123/exec", func(w http.ResponseWriter, r *http.Request) {
+		var execConfig container.ExecOptions
+		if err := json.NewDecoder(r.Body).Decode(&execConfig); err != nil {
+			t.Errorf("decode exec body: %v", err)
+		}
+		execCmds = append(execCmds, execConfig.Cmd)
+		if execConfig.Cmd[0] != "test" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"message":"hook reached"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Id":"check"}`))
+	})
+	daemon.handle(http.MethodPost, "/exec/check/start", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n"))
+		_ = conn.Close()
+	})
+	daemon.handle(http.MethodGet, "/exec/check/json", func(w http.ResponseWriter, r *http.Request) {
+		checks++
+		exitCode := 1
+		if checks == 2 {
+			exitCode = 0
+		}
+		_, _ = fmt.Fprintf(w, `{"ID":"check","Running":false,"ExitCode":%d}`, exitCode)
+	})
+	daemon.useAsDockerHost()
+
+	configPath := filepath.Join(t.TempDir(), "devcontainer.json")
+	config := `{"image":"alpine:3.19","workspaceFolder":"/workspaces/app","postCreateCommand":"make setup"}`
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	_, err := StartDevcontainer(context.Background(), WithConfigPath(configPath), WithWorkspaceReadyCheck("go.mod", time.Minute))
+	if err == nil || !strings.Contains(err.Error(), "hook reached") {
+		t.Fatalf("expected the hook to run after the check, got %v", err)
+	}
+	want := [][]string{
+		{"test", "-e", "/workspaces/app/go.mod"},
+		{"test", "-e", "/workspaces/app/go.mod"},
+		{"/bin/sh", "-c", "make setup"},
+	}
+	if !reflect.DeepEqual(execCmds, want) {
+		t.Fatalf("unexpected exec sequence: %#v", execCmds)
+	}
+
+	if _, err := applyStartOptions([]StartOption{WithWorkspaceReadyCheck("../outside", time.Second)}); err == nil {
+		t.Fatal("expected a marker outside the workspace to fail")
+	}
+}
+
+func TestStartDevcontainer_StreamsBuildAndPullOutput(t *testing.T) {
+	daemon := newFakeDaemon(t)
+	daemon.handleStart("container-123", nil)
+	daemon.respond(http.MethodPost, "/images/create", http.StatusOK, `{"status":"Pulling from library/alpine","id":"3.19"}`+"\n"+
+		`{"status":"Downloading","id":"abc","progress":"[=>   ]"}`+"\n"+`{"status":"Pull complete","id":"abc"}`)
+	buildResponse := `{"stream":"Step 1/2 : FROM alpine:3.19\n"}` + "\n" + `{"stream":"Step 2/2 : RUN make\n"}`
+	daemon.handle(http.MethodPost, "/build", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte(buildResponse))
+	})
+	daemon.useAsDockerHost()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM alpine:3.19\nRUN make\n"), 0o644); err != nil {
+		t.Fatalf("write Dockerfile: %v", err)
+	}
+	buildPath := filepath.Join(dir, "devcontainer.json")
+	if err := os.WriteFile(buildPath, []byte(`{"build":{"dockerfile":"Dockerfile"}}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	var output bytes.Buffer
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(buildPath), WithBuildOutput(&output)); err != nil {
+		t.Fatalf("StartDevcontainer build: %v", err)
+	}
+	if output.String() != "Step 1/2 : FROM alpine:3.19\nStep 2/2 : RUN make\n" {
+		t.Fatalf("unexpected build output: %q", output.String())
+	}
+
+	imagePath := filepath.Join(t.TempDir(), "devcontainer.json")
+	writeTestcaseFile(t, imagePath, "config", "basic", "devcontainer.json")
+	output.Reset()
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(imagePath), WithBuildOutput(&output)); err != nil {
+		t.Fatalf("StartDevcontainer pull: %v", err)
+	}
+	if output.String() != "3.19: Pulling from library/alpine\nabc: Pull complete\n" {
+		t.Fatalf("unexpected pull output: %q", output.String())
+	}
+
+	buildResponse = `{"stream":"Step 2/2 : RUN make\n"}` + "\n" + `{"errorDetail":{"message":"exit 2"},"error":"The command '/bin/sh -c make' returned a non-zero code: 2"}`
+	if _, err := StartDevcontainer(context.Background(), WithConfigPath(buildPath)); err == nil || !strings.Contains(err.Error(), "returned a non-zero code: 2") {
+		t.Fatalf("expected the build error from the stream, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package godev
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// startLockPollInterval is how often a start waiting on another start of the same devcontainer retries the lock.
+var startLockPollInterval = 100 * time.Millisecond
+
+// startLock serializes starts that share a devcontainerId, across goroutines and processes.
+type startLock struct {
+	file *os.File  // file holds the OS lock while open.
+	once sync.Once // once guards release.
+}
+
+// acquireStartLock locks godev-start-<devcontainerID>.lock in the OS temp directory, waiting while another start
+// holds it until ctx is done. WithTempDir does not move the lock, so processes with different temp dirs still serialize.
+func acquireStartLock(ctx context.Context, devcontainerID string, logger *slog.Logger) (*startLock, error) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("godev-start-%s.lock", devcontainerID))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	waiting := false
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		if locked {
+			return &startLock{file: file}, nil
+		}
+		if !waiting {
+			logger.Info("waiting for another start of this devcontainer", "lock", path)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			_ = file.Close()
+			return nil, fmt.Errorf("start already in progress for devcontainer %s: %w", devcontainerID, ctx.Err())
+		case <-time.After(startLockPollInterval):
+		}
+	}
+}
+
+// release unlocks the lock file; the file itself is kept so later starts lock the same inode.
+func (l *startLock) release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		_ = unlockFile(l.file)
+		_ = l.file.Close()
+	})
+}
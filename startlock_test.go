@@ -0,0 +1,66 @@
+package godev
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireStartLock_SerializesStarts(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	logger := slog.New(slog.DiscardHandler)
+	var active, maxActive atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := acquireStartLock(context.Background(), "abc123", logger)
+			if err != nil {
+				errs <- err
+				return
+			}
+			current := active.Add(1)
+			if current > maxActive.Load() {
+				maxActive.Store(current)
+			}
+			time.Sleep(150 * time.Millisecond)
+			active.Add(-1)
+			lock.release()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("acquireStartLock: %v", err)
+	}
+	if maxActive.Load() != 1 {
+		t.Fatalf("expected starts to run one at a time, saw %d at once", maxActive.Load())
+	}
+}
+
+func TestAcquireStartLock_ReportsStartInProgress(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	logger := slog.New(slog.DiscardHandler)
+	held, err := acquireStartLock(context.Background(), "abc123", logger)
+	if err != nil {
+		t.Fatalf("acquireStartLock: %v", err)
+	}
+	defer held.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := acquireStartLock(ctx, "abc123", logger); err == nil || !strings.Contains(err.Error(), "start already in progress") {
+		t.Fatalf("expected start in progress error, got %v", err)
+	}
+	other, err := acquireStartLock(context.Background(), "def456", logger)
+	if err != nil {
+		t.Fatalf("expected other devcontainers to lock independently: %v", err)
+	}
+	other.release()
+}
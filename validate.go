@@ -0,0 +1,106 @@
+package godev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// ValidateConfig checks a devcontainer config without contacting Docker or feature registries.
+// Impact: It loads devcontainer.json (or WithConfig) with WithMergeConfig overlays and reports every problem it
+// finds in the config, runArgs, ports, mounts, and local or OCI layout features as one joined error; remote features
+// are only checked for a well-formed reference.
+// Example:
+//
+//	err := devcontainer.ValidateConfig(ctx, devcontainer.WithConfigPath("./.devcontainer/devcontainer.json"))
+//
+// Similar: EffectiveConfig stops at the first problem and returns the merged config.
+func ValidateConfig(ctx context.Context, opts ...StartOption) error {
+	options, err := applyStartOptions(opts)
+	if err != nil {
+		return err
+	}
+	configPath, cfg, err := loadMergedConfig(options)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if err := validateConfig(cfg); err != nil {
+		if !options.ComposeDiscovery || !errors.Is(err, errComposeFileRequired) {
+			errs = append(errs, err)
+		}
+	}
+	var workspaceRoot string
+	var vars map[string]string
+	if isComposeConfig(cfg) {
+		workspaceRoot, _, vars, err = resolveComposeWorkspacePaths(configPath, cfg)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	} else {
+		var workspaceMount string
+		workspaceRoot, _, workspaceMount, vars, err = resolveWorkspacePaths(configPath, cfg)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if _, err := parseRestrictedRunArgs(append(cfg.RunArgs, options.RunArgs...), options.AllowedRunArgs, options.IgnoreRunArgs); err != nil {
+			errs = append(errs, err)
+		}
+		portSpecs, err := collectPortSpecs(cfg.ForwardPorts, cfg.AppPort, options.ExtraPublish, options.EphemeralPorts)
+		if err == nil {
+			_, _, err = parsePortSpecs(portSpecs)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := buildMounts(workspaceMount, cfg.Mounts, options.ExtraMounts, vars); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, validateFeaturesOffline(ctx, configPath, workspaceRoot, cfg, vars, options.FeatureOrder)...)
+	return errors.Join(errs...)
+}
+
+// validateFeaturesOffline resolves local and OCI layout features and their dependsOn closure, skipping remote features.
+// Install order is only checked when nothing was skipped, since overrides may name the skipped features.
+func validateFeaturesOffline(ctx context.Context, configPath, workspaceRoot string, cfg *DevcontainerConfig, vars map[string]string, strategy FeatureOrderStrategy) []error {
+	if len(cfg.Features) == 0 {
+		return nil
+	}
+	resolver := &featureResolver{
+		configDir:       filepath.Dir(configPath),
+		devcontainerDir: filepath.Join(workspaceRoot, ".devcontainer"),
+		resolving:       make(map[string]int),
+		resolved:        make(map[string]*ResolvedFeature),
+		registry:        newRegistryClient(""),
+		offline:         true,
+		vars:            vars,
+		workspaceRoot:   workspaceRoot,
+	}
+	defer func() {
+		removeFeatureDirs(resolver.registry.extracted)
+	}()
+	ids := make([]string, 0, len(cfg.Features))
+	for id := range cfg.Features {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if err := validateFeatureIDCollisions(ids); err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, id := range ids {
+		_, err := resolver.resolveRequest(ctx, id, cfg.Features[id])
+		if err != nil && !errors.Is(err, errFeatureOffline) {
+			errs = append(errs, fmt.Errorf("feature %s: %w", id, err))
+		}
+	}
+	if len(errs) == 0 && !resolver.skipped {
+		if _, err := orderFeatures(resolver.features, cfg.OverrideFeatureInstallOrder, strategy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
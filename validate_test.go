@@ -0,0 +1,82 @@
+package godev
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig_SkipsRemoteFeaturesOffline(t *testing.T) {
+	root := t.TempDir()
+	featureDir := filepath.Join(root, ".devcontainer", "local")
+	if err := os.MkdirAll(featureDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	metadata := `{"id": "local", "version": "1.0.0", "name": "Local", "dependsOn": {"https://127.0.0.1:1/feature.tgz": {}}}`
+	if err := os.WriteFile(filepath.Join(featureDir, "devcontainer-feature.json"), []byte(metadata), 0o644); err != nil {
+		t.Fatalf("write metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(featureDir, "install.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write install.sh: %v", err)
+	}
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	config := `{"image": "alpine:3.19", "features": {"./local": {}, "ghcr.io/devcontainers/features/go:1": {}}, "overrideFeatureInstallOrder": ["ghcr.io/devcontainers/features/go"]}`
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := ValidateConfig(context.Background(), WithConfigPath(configPath)); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+}
+
+func TestValidateConfig_ReportsEveryProblem(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "devcontainer.json")
+	config := `{"image": "alpine:3.19", "runArgs": ["--no-such-flag"], "forwardPorts": ["not-a-port"], "features": {"./missing": {}}}`
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	err := ValidateConfig(context.Background(), WithConfigPath(configPath))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 problems, got %d: %v", len(lines), err)
+	}
+}
+
+func TestValidateConfig_PathFeatureOption(t *testing.T) {
+	root := t.TempDir()
+	featureDir := filepath.Join(root, ".devcontainer", "local")
+	if err := os.MkdirAll(featureDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	metadata := `{"id": "local", "version": "1.0.0", "name": "Local", "options": {
+		"config": {"type": "path", "default": "${localWorkspaceFolder}/defaults/app.yml"}}}`
+	if err := os.WriteFile(filepath.Join(featureDir, "devcontainer-feature.json"), []byte(metadata), 0o644); err != nil {
+		t.Fatalf("write metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(featureDir, "install.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write install.sh: %v", err)
+	}
+	configPath := filepath.Join(root, ".devcontainer", "devcontainer.json")
+	if err := os.WriteFile(configPath, []byte(`{"image": "alpine:3.19", "features": {"./local": {}}}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := ValidateConfig(context.Background(), WithConfigPath(configPath)); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	escaping := `{"image": "alpine:3.19", "features": {"./local": {"config": "../outside.yml"}}}`
+	if err := os.WriteFile(configPath, []byte(escaping), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := ValidateConfig(context.Background(), WithConfigPath(configPath)); err == nil {
+		t.Fatal("expected a path option outside the workspace to be rejected")
+	}
+}
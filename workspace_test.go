@@ -3,6 +3,7 @@ package godev
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -33,3 +34,78 @@ func TestResolveWorkspacePaths_Defaults(t *testing.T) {
 		t.Fatalf("unexpected vars: %#v", vars)
 	}
 }
+
+func TestWithDevcontainerID_FlowsIntoNames(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "demo")
+	configDir := filepath.Join(root, ".devcontainer")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(configDir, "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+
+	options, err := applyStartOptions([]StartOption{WithDevcontainerID("ci-main")})
+	if err != nil {
+		t.Fatalf("applyStartOptions: %v", err)
+	}
+	workspaceRoot, _, _, vars, err := resolveWorkspacePaths(configPath, &DevcontainerConfig{})
+	if err != nil {
+		t.Fatalf("resolveWorkspacePaths: %v", err)
+	}
+	if vars["devcontainerId"] != devcontainerID(workspaceRoot, configPath) {
+		t.Fatalf("expected default devcontainerId, got %q", vars["devcontainerId"])
+	}
+	overrideDevcontainerID(vars, options.DevcontainerID)
+	id := vars["devcontainerId"]
+	if id != "ci-main" {
+		t.Fatalf("unexpected devcontainerId: %q", id)
+	}
+	if got := resolveContainerName("", workspaceRoot, id); got != "godev-demo-ci-main" {
+		t.Fatalf("unexpected container name: %s", got)
+	}
+	if got := imageTagForBuild(workspaceRoot, id); got != "godev-demo-ci-main:latest" {
+		t.Fatalf("unexpected image tag: %s", got)
+	}
+	if got := featuresImageTag(workspaceRoot, id, nil); !strings.HasPrefix(got, "godev-demo-ci-main-features-") {
+		t.Fatalf("unexpected features image tag: %s", got)
+	}
+	if got := resolveComposeProjectName(&DevcontainerConfig{}, workspaceRoot, id, ""); got != "godev-demo-ci-main" {
+		t.Fatalf("unexpected compose project name: %s", got)
+	}
+
+	if _, err := applyStartOptions([]StartOption{WithDevcontainerID("Not Valid")}); err == nil {
+		t.Fatal("expected invalid devcontainer id error")
+	}
+}
+
+func TestResolveWorkspacePaths_SpacesAndCommas(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "My Project, v2")
+	configDir := filepath.Join(root, ".devcontainer")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(configDir, "devcontainer.json")
+	writeTestcaseFile(t, configPath, "config", "basic", "devcontainer.json")
+
+	workspaceRoot, workspaceFolder, workspaceMount, vars, err := resolveWorkspacePaths(configPath, &DevcontainerConfig{})
+	if err != nil {
+		t.Fatalf("resolveWorkspacePaths: %v", err)
+	}
+	if workspaceFolder != "/workspaces/My Project, v2" {
+		t.Fatalf("unexpected workspaceFolder: %q", workspaceFolder)
+	}
+	if vars["containerWorkspaceFolderBasename"] != "My Project, v2" {
+		t.Fatalf("unexpected basename var: %q", vars["containerWorkspaceFolderBasename"])
+	}
+	mounts, err := buildMounts(workspaceMount, nil, nil, vars)
+	if err != nil {
+		t.Fatalf("buildMounts: %v", err)
+	}
+	if len(mounts) != 1 || mounts[0].Source != workspaceRoot || mounts[0].Target != workspaceFolder {
+		t.Fatalf("unexpected workspace mount: %#v", mounts)
+	}
+	name := resolveContainerName("", workspaceRoot, vars["devcontainerId"])
+	if !strings.HasPrefix(name, "godev-My-Project--v2-") || strings.ContainsAny(name, " ,") {
+		t.Fatalf("unexpected container name: %q", name)
+	}
+}